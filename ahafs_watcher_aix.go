@@ -0,0 +1,285 @@
+//go:build aix
+// +build aix
+
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// ahafsRoot is where the AIX Event Infrastructure is usually mounted.
+	ahafsRoot = "/aha"
+
+	// ahafsMonitor is written to each monitor file to be told about
+	// every change, waiting with poll, without the extra detail.
+	ahafsMonitor = "CHANGED=YES;WAIT_TYPE=WAIT_IN_SELECT;INFO_LVL=1"
+)
+
+// Filesystem types from sys/vmount.h that ahafs can't see every change
+// on, since they're made by other machines.
+const (
+	aixVfsNFS   = 2
+	aixVfsNFS3  = 18
+	aixVfsNFS4  = 35
+	aixVfsCIFS  = 37
+	aixVfsSTNFS = 41
+)
+
+// ahafsWatcher is a pollWatcher that polls as soon as the AIX Event
+// Infrastructure reports a change instead of waiting for the next
+// Interval.
+type ahafsWatcher struct {
+	*pollWatcher
+
+	// path and dir are absolute, as monitors are named after them.
+	path string
+	dir  string
+
+	// dirMon and fileMon are the monitors for the directory and the
+	// file at the path, or -1 if there isn't one.
+	dirMon  int
+	fileMon int
+
+	// stop is a pipe whose read end is polled along with the monitors,
+	// so closing the write end interrupts waiting for events. done is
+	// closed once watch has released every descriptor. The descriptors
+	// are guarded by mu.
+	stop [2]int
+	done chan struct{}
+	mu   sync.Mutex
+}
+
+// NewInotifyWatcher configures a Watcher like NewPollingWatcher, but uses
+// the AIX Event Infrastructure (ahafs), the closest to inotify on AIX, to
+// check for more data as soon as the file at the path is written to, or
+// the directory's entries change. Monitors are by name, so writes to a
+// file after it's rotated away are only found by polling. Interval is
+// still used as a fallback, such as for PartialLineGrace. If ahafs isn't
+// mounted at /aha or can't be used, it falls back to only polling.
+func NewInotifyWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+	return startAhafs(p), nil
+}
+
+// NewAutoWatcher is like NewInotifyWatcher, but only polls if the path is
+// on a filesystem where ahafs doesn't see every change, such as writes
+// from other machines to NFS or SMB shares. Its NotifyStatus method
+// reports which was chosen.
+func NewAutoWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason := unreliableNotify(filepath.Dir(c.Path)); reason != "" {
+		p.notify.Reason = reason
+		p.start()
+		return p, nil
+	}
+	return startAhafs(p), nil
+}
+
+// startAhafs starts polling with p, using ahafs if it can.
+func startAhafs(p *pollWatcher) Watcher {
+	w, err := newAhafsWatcher(p)
+	if err != nil {
+		p.notify.Reason = fmt.Sprintf("ahafs failed: %v", err)
+		p.start()
+		return p
+	}
+
+	p.notify.Notify = true
+	go w.watch()
+	p.start()
+	return w
+}
+
+// unreliableNotify returns why ahafs can't be relied on in dir, or an
+// empty string if it can. It's assumed to work if the filesystem can't
+// be checked.
+func unreliableNotify(dir string) string {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(dir, &fs); err != nil {
+		return ""
+	}
+
+	switch fs.Vfstype {
+	case aixVfsNFS, aixVfsNFS3, aixVfsNFS4, aixVfsSTNFS:
+		return "nfs filesystem"
+	case aixVfsCIFS:
+		return "smb filesystem"
+	}
+	return ""
+}
+
+func newAhafsWatcher(p *pollWatcher) (*ahafsWatcher, error) {
+	if _, err := os.Stat(filepath.Join(ahafsRoot, "fs", "modFile.monFactory")); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("not mounted at %s", ahafsRoot)
+		}
+		return nil, err
+	}
+
+	path, err := filepath.Abs(p.c.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &ahafsWatcher{
+		pollWatcher: p,
+		path:        path,
+		dir:         filepath.Dir(path),
+		dirMon:      -1,
+		fileMon:     -1,
+		stop:        [2]int{-1, -1},
+		done:        make(chan struct{}),
+	}
+
+	if err = unix.Pipe(w.stop[:]); err != nil {
+		return nil, err
+	}
+	unix.CloseOnExec(w.stop[0])
+	unix.CloseOnExec(w.stop[1])
+
+	if w.dirMon, err = monitor("modDir.monFactory", w.dir); err != nil {
+		w.release()
+		return nil, err
+	}
+
+	p.wake = make(chan struct{}, 1)
+	w.watchFile()
+	return w, nil
+}
+
+// monitor starts watching name with the ahafs factory, returning the
+// monitor's descriptor, which is readable once there's an event.
+func monitor(factory, name string) (int, error) {
+	mon := filepath.Join(ahafsRoot, "fs", factory, name) + ".mon"
+	if err := os.MkdirAll(filepath.Dir(mon), 0700); err != nil {
+		return -1, err
+	}
+
+	fd, err := unix.Open(mon, unix.O_CREAT|unix.O_RDWR|unix.O_CLOEXEC, 0600)
+	if err != nil {
+		return -1, err
+	}
+	if _, err = unix.Write(fd, []byte(ahafsMonitor)); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// consume reads the event from a monitor, which it waits for before
+// reporting the next one.
+func consume(fd int) error {
+	if _, err := unix.Seek(fd, 0, 0); err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	_, err := unix.Read(fd, buf)
+	return err
+}
+
+// watchFile replaces the monitor for the file at the path, since the
+// path may name a different file now.
+func (w *ahafsWatcher) watchFile() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fileMon >= 0 {
+		unix.Close(w.fileMon)
+	}
+	// Errors are ignored, since the file may not exist yet and polling
+	// will still notice changes.
+	w.fileMon, _ = monitor("modFile.monFactory", w.path)
+}
+
+func (w *ahafsWatcher) watch() {
+	defer close(w.done)
+	defer w.release()
+
+	for {
+		w.mu.Lock()
+		fds := []unix.PollFd{
+			{Fd: int32(w.stop[0]), Events: unix.POLLIN},
+			{Fd: int32(w.dirMon), Events: unix.POLLIN},
+			{Fd: int32(w.fileMon), Events: unix.POLLIN},
+		}
+		w.mu.Unlock()
+
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return
+		}
+
+		if fds[0].Revents != 0 {
+			return
+		}
+
+		changed := false
+		if fds[1].Revents != 0 {
+			if consume(w.dirMon) != nil {
+				return
+			}
+			w.watchFile()
+			changed = true
+		}
+		if fds[2].Revents != 0 && fds[2].Fd >= 0 {
+			// The monitor is broken once the file is removed, so try
+			// to replace it.
+			if consume(int(fds[2].Fd)) != nil {
+				w.watchFile()
+			}
+			changed = true
+		}
+
+		if changed {
+			select {
+			case w.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// release closes every descriptor the watcher opened.
+func (w *ahafsWatcher) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, fd := range []int{w.fileMon, w.dirMon, w.stop[0], w.stop[1]} {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+	}
+	w.fileMon, w.dirMon, w.stop = -1, -1, [2]int{-1, -1}
+}
+
+func (w *ahafsWatcher) Close() error {
+	err := w.pollWatcher.Close()
+
+	// Closing the write end makes the read end readable, which stops
+	// watch, and it's the only one using the descriptors by now.
+	w.mu.Lock()
+	if w.stop[1] >= 0 {
+		unix.Close(w.stop[1])
+		w.stop[1] = -1
+	}
+	w.mu.Unlock()
+
+	<-w.done
+	return err
+}
@@ -0,0 +1,146 @@
+package tail
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SkippedRange is part of a file that wasn't read because of
+// Config.LazyBackfill, which can be read later with OpenSkipped.
+type SkippedRange struct {
+	// Path is where the file was when it was skipped. It may have
+	// been rotated to a different name since.
+	Path string
+
+	// State identifies the file, and its Position is the start
+	// of the skipped range.
+	State FileState
+
+	// End is the offset the skipped range ends at, exclusive.
+	End int64
+}
+
+// skipHistory records everything before the end of f as skipped and seeks
+// to the end. If StartState is set and f isn't that file, the rest of
+// that file and any files rotated after it are recorded too, if they
+// can be found using the numbered naming scheme.
+func (p *pollWatcher) skipHistory(f *os.File) error {
+	state, err := NewFileState(f)
+	if err != nil {
+		return err
+	}
+
+	start := int64(0)
+	if old := p.c.StartState; old != nil {
+		if old.sameIdentity(state) && old.Position <= state.Size {
+			start = old.Position
+		} else {
+			p.skipped = append(p.skipped, p.skipRotated(*old)...)
+		}
+	}
+
+	if start < state.Size {
+		state.Position = start
+		p.skipped = append(p.skipped, SkippedRange{
+			Path:  p.c.Path,
+			State: state,
+			End:   state.Size,
+		})
+	}
+
+	_, err = f.Seek(0, io.SeekEnd)
+	return err
+}
+
+// skipRotated finds where the file old describes was rotated to and
+// returns the rest of it along with every file rotated after it.
+func (p *pollWatcher) skipRotated(old FileState) []SkippedRange {
+	name := findRenamed(filepath.Dir(p.c.Path), old)
+	if name == "" {
+		return nil
+	}
+
+	var skipped []SkippedRange
+	if state, err := NewFileStateFromPath(name); err == nil && old.Position < state.Size {
+		state.Position = old.Position
+		skipped = append(skipped, SkippedRange{Path: name, State: *state, End: state.Size})
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(name, p.c.Path+"."))
+	if err != nil {
+		return skipped
+	}
+
+	for i := n - 1; i > 0; i-- {
+		name := fmt.Sprintf("%s.%v", p.c.Path, i)
+		if state, err := NewFileStateFromPath(name); err == nil && state.Size > 0 {
+			skipped = append(skipped, SkippedRange{Path: name, State: *state, End: state.Size})
+		}
+	}
+	return skipped
+}
+
+// Skipped returns the ranges that weren't read because of LazyBackfill.
+func (p *pollWatcher) Skipped() []SkippedRange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]SkippedRange(nil), p.skipped...)
+}
+
+// Skipped returns the ranges of files that were skipped to start reading
+// new data right away with Config.LazyBackfill, oldest first. It's empty
+// until the first file is opened. The ranges can be read with OpenSkipped.
+func (l *LineReader) Skipped() []SkippedRange {
+	if s, ok := l.r.(interface{ Skipped() []SkippedRange }); ok {
+		return s.Skipped()
+	}
+	return nil
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+	io.Closer
+}
+
+// OpenSkipped opens the file for r and returns a reader limited to the
+// skipped range. If the file was rotated since it was skipped, it looks
+// for it by its identity in the same directory.
+func OpenSkipped(r SkippedRange) (io.ReadCloser, error) {
+	f, err := openFile(r.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if f != nil {
+		state, err := NewFileState(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		if !r.State.sameIdentity(state) {
+			f.Close()
+			f = nil
+		}
+	}
+
+	if f == nil {
+		name := findRenamed(filepath.Dir(r.Path), r.State)
+		if name == "" {
+			return nil, &FileRemovedError{Path: r.Path, State: r.State}
+		}
+
+		if f, err = openFile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return sectionReadCloser{
+		SectionReader: io.NewSectionReader(f, r.State.Position, r.End-r.State.Position),
+		Closer:        f,
+	}, nil
+}
@@ -0,0 +1,83 @@
+package tail
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func readSkipped(t *testing.T, r SkippedRange) string {
+	t.Helper()
+	rc, err := OpenSkipped(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestLineReaderLazyBackfill(t *testing.T) {
+
+	h := NewWatcherHarness(t, "lazy-backfill-test")
+
+	writer := h.Create()
+	writeString(t, writer, "a\nb\n")
+	writer.Close()
+
+	// Save a state partway through the first file, then rotate it
+	// so resuming has to skip the rest of it.
+	first, err := NewFileStateFromPath(h.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Position = 2
+
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "c\n")
+
+	c := Config{
+		Path:         h.Path(),
+		Interval:     time.Millisecond * 10,
+		StartState:   first,
+		LazyBackfill: true,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		writeString(t, writer, "d\n")
+	}()
+
+	readLine(t, r, "d")
+
+	skipped := r.Skipped()
+	if len(skipped) != 2 {
+		t.Fatalf("expected 2 skipped ranges, got %+v", skipped)
+	}
+
+	if skipped[0].Path != h.Path()+".1" || readSkipped(t, skipped[0]) != "b\n" {
+		t.Fatalf("unexpected first skipped range %+v", skipped[0])
+	}
+
+	// The live file can be rotated before reading what was skipped.
+	h.Rotate()
+
+	if skipped[1].Path != h.Path() || readSkipped(t, skipped[1]) != "c\n" {
+		t.Fatalf("unexpected second skipped range %+v", skipped[1])
+	}
+}
@@ -0,0 +1,577 @@
+package tail
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackfillConfig configures reading the files Path was rotated to after
+// StartState was saved, before tailing Path itself, so lines written while
+// nothing was reading aren't lost. See Config.Backfill.
+type BackfillConfig struct {
+	// Siblings returns the names of the files path has been rotated to,
+	// oldest first. Names ending in .gz are decompressed. If nil,
+	// NumberedSiblings is used.
+	Siblings func(path string) ([]string, error)
+
+	// All reads every sibling from the start when there's no StartState,
+	// such as the first time a consumer runs, instead of only the file
+	// at Path from Whence.
+	All bool
+
+	// Archive is optional and is searched for the file StartState was
+	// saved for when it isn't among the siblings, such as when it was
+	// uploaded to object storage and removed long ago. Archived files
+	// can only be recognized by their Fingerprint, like compressed ones,
+	// and are read before the siblings.
+	Archive Archive
+}
+
+// Archive holds the files a path was rotated to once they're no longer
+// kept beside it, for BackfillConfig.Archive.
+type Archive interface {
+	// List returns the names of the files path was rotated to that are
+	// only in the archive, oldest first.
+	List(path string) ([]string, error)
+
+	// Fetch returns the contents of the file name from List. Names
+	// ending in .gz are decompressed.
+	Fetch(name string) (io.ReadCloser, error)
+}
+
+// NumberedSiblings returns the files path was rotated to using the numbered
+// naming scheme, path.N through path.1, oldest first. Each of them can also
+// be compressed, like path.2.gz.
+func NumberedSiblings(path string) ([]string, error) {
+	var names []string
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s.%v", path, i)
+		_, err := os.Stat(name)
+		if os.IsNotExist(err) {
+			name += ".gz"
+			_, err = os.Stat(name)
+		}
+
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names, nil
+}
+
+// GlobSiblings returns a function for BackfillConfig.Siblings that finds
+// the rotated files with pattern, a filepath.Glob pattern, instead of
+// the numbered naming scheme, such as path + "-*" for date-stamped ones.
+// They're ordered by modification time, since not every date format
+// sorts by name, and a file at the path itself is left out.
+func GlobSiblings(pattern string) func(path string) ([]string, error) {
+	return func(path string) ([]string, error) {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		type sibling struct {
+			name    string
+			modTime time.Time
+		}
+
+		var siblings []sibling
+		for _, name := range matches {
+			if filepath.Clean(name) == filepath.Clean(path) {
+				continue
+			}
+
+			info, err := os.Stat(name)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			if info.Mode().IsRegular() {
+				siblings = append(siblings, sibling{name, info.ModTime()})
+			}
+		}
+
+		sort.SliceStable(siblings, func(i, j int) bool {
+			return siblings[i].modTime.Before(siblings[j].modTime)
+		})
+
+		names := make([]string, len(siblings))
+		for i, s := range siblings {
+			names[i] = s.name
+		}
+		return names, nil
+	}
+}
+
+func isCompressed(name string) bool {
+	return strings.HasSuffix(name, ".gz")
+}
+
+// backfillFile is a rotated file to read before the live one.
+type backfillFile struct {
+	f *os.File
+	// archived is the name of the file in BackfillConfig.Archive instead
+	// of f, which is only fetched once it's read.
+	archived string
+	// start is the offset to start reading from, which is after
+	// decompressing if the file is compressed.
+	start int64
+}
+
+// backfillWatcher returns each file in queue once, then waits on the
+// live Watcher. The files are opened when it's created, so they can
+// still be read if they're rotated again.
+type backfillWatcher struct {
+	Watcher
+	c Config
+
+	mu     sync.Mutex
+	queue  []backfillFile
+	cur    io.Closer
+	closed bool
+	// started is set once the first file was returned, since every one
+	// after it replaces the one before.
+	started bool
+}
+
+// newBackfillWatcher returns a polling Watcher for c that first reads the
+// files Path was rotated to since StartState, if any.
+func newBackfillWatcher(c Config) (Watcher, error) {
+	if c.LazyBackfill {
+		return nil, errors.New("config values for backfill and lazy backfill cannot both be set")
+	}
+
+	queue, err := planBackfill(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(queue) == 0 {
+		return NewPollingWatcher(c)
+	}
+
+	// The live file is newer than everything in the queue, so it's read
+	// from the start.
+	live := c
+	live.StartState = nil
+	live.Whence = io.SeekStart
+	live.Offset = 0
+	live.TailLines = 0
+
+	w, err := NewPollingWatcher(live)
+	if err != nil {
+		closeBackfill(queue)
+		return nil, err
+	}
+	return &backfillWatcher{Watcher: w, c: c, queue: queue}, nil
+}
+
+// planBackfill finds the sibling StartState was saved for, and opens it
+// and every sibling rotated after it. Uncompressed files are found by
+// their identity, and compressed ones by their Fingerprint.
+func planBackfill(c Config) ([]backfillFile, error) {
+	siblings := NumberedSiblings
+	if c.Backfill.Siblings != nil {
+		siblings = c.Backfill.Siblings
+	}
+
+	start := c.StartState
+	if start == nil && c.Backfill.All {
+		names, err := siblings(c.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		var queue []backfillFile
+		for _, name := range names {
+			f, err := openFile(name)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				closeBackfill(queue)
+				return nil, err
+			}
+			queue = append(queue, backfillFile{f: f})
+		}
+		return queue, nil
+	}
+
+	// An archived file has no identity, but can still be found by its
+	// Fingerprint.
+	if start == nil || (start.ID().IsZero() && start.FingerprintSize == 0) {
+		return nil, nil
+	}
+
+	// Nothing was rotated if the live file is still the same one.
+	if f, err := openFile(c.Path); err == nil {
+		matches, err := matchBackfill(f, *start)
+		f.Close()
+		if err != nil {
+			return nil, err
+		} else if matches {
+			return nil, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	names, err := siblings(c.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var queue []backfillFile
+	for i := len(names) - 1; i >= 0; i-- {
+		f, err := openFile(names[i])
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			closeBackfill(queue)
+			return nil, err
+		}
+
+		matches, err := matchBackfill(f, *start)
+		if err != nil {
+			f.Close()
+			closeBackfill(queue)
+			return nil, err
+		}
+
+		if matches {
+			queue = append(queue, backfillFile{f: f, start: start.Position})
+			// Found newest first, but read oldest first.
+			for i, j := 0, len(queue)-1; i < j; i, j = i+1, j-1 {
+				queue[i], queue[j] = queue[j], queue[i]
+			}
+			return queue, nil
+		}
+		queue = append(queue, backfillFile{f: f})
+	}
+
+	archived, err := planArchive(c.Backfill.Archive, c.Path, *start)
+	if err != nil || archived == nil {
+		// The file wasn't found, so there's no way to know which of the
+		// siblings were already read.
+		closeBackfill(queue)
+		return nil, err
+	}
+
+	for i, j := 0, len(queue)-1; i < j; i, j = i+1, j-1 {
+		queue[i], queue[j] = queue[j], queue[i]
+	}
+	return append(archived, queue...), nil
+}
+
+// planArchive finds the file start was saved for in a, and returns it and
+// every file archived after it, oldest first.
+func planArchive(a Archive, path string, start FileState) ([]backfillFile, error) {
+	if a == nil || start.FingerprintSize == 0 {
+		return nil, nil
+	}
+
+	names, err := a.List(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		matches, err := matchArchived(a, names[i], start)
+		if err != nil {
+			return nil, err
+		}
+
+		if matches {
+			queue := []backfillFile{{archived: names[i], start: start.Position}}
+			for _, name := range names[i+1:] {
+				queue = append(queue, backfillFile{archived: name})
+			}
+			return queue, nil
+		}
+	}
+	return nil, nil
+}
+
+// matchArchived reports whether the file name in a is the one start was
+// saved for, by fetching enough of it to compare its Fingerprint.
+func matchArchived(a Archive, name string, start FileState) (bool, error) {
+	rc, err := a.Fetch(name)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+
+	r, err := decompress(name, rc)
+	if err != nil {
+		return false, err
+	}
+
+	sum, size, err := hashPrefix(r, start.FingerprintSize)
+	if err != nil {
+		return false, err
+	}
+	return size == start.FingerprintSize && sum == start.Fingerprint, nil
+}
+
+func closeBackfill(queue []backfillFile) {
+	for _, b := range queue {
+		if b.f != nil {
+			b.f.Close()
+		}
+	}
+}
+
+// matchBackfill reports whether f is the file start was saved for.
+func matchBackfill(f *os.File, start FileState) (bool, error) {
+	if !isCompressed(f.Name()) {
+		state, err := NewFileState(f)
+		if err != nil || !state.ID().Equal(start.ID()) || start.Position > state.Size {
+			return false, err
+		}
+
+		// The inode may have been reused.
+		if start.FingerprintSize == 0 {
+			return true, nil
+		}
+		sum, ok, err := fingerprint(f, start.FingerprintSize)
+		return ok && sum == start.Fingerprint, err
+	}
+
+	if start.FingerprintSize == 0 {
+		return false, nil
+	}
+
+	zr, err := newGzipReader(f)
+	if err != nil {
+		return false, err
+	}
+
+	sum, size, err := hashPrefix(zr, start.FingerprintSize)
+	if err != nil {
+		return false, err
+	}
+	return size == start.FingerprintSize && sum == start.Fingerprint, nil
+}
+
+func (w *backfillWatcher) Wait() (WaitStatus, bool, error) {
+	return w.WaitContext(context.Background())
+}
+
+func (w *backfillWatcher) WaitContext(ctx context.Context) (WaitStatus, bool, error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return WaitStatus{}, true, nil
+	}
+
+	// The LineReader only waits once it has read the previous file
+	// to the end.
+	if w.cur != nil {
+		w.cur.Close()
+		w.cur = nil
+	}
+
+	if len(w.queue) > 0 {
+		b := w.queue[0]
+		w.queue = w.queue[1:]
+		event := Created
+		if w.started {
+			event = Rotated
+		}
+		w.started = true
+
+		if b.archived != "" {
+			w.mu.Unlock()
+			s, closed, err := w.openArchived(b)
+			s.Event = event
+			return s, closed, err
+		}
+
+		w.cur = b.f
+		w.mu.Unlock()
+
+		s, err := w.open(b)
+		s.Event = event
+		return s, false, err
+	}
+	w.mu.Unlock()
+
+	var s WaitStatus
+	var closed bool
+	var err error
+	if cw, ok := w.Watcher.(ContextWatcher); ok {
+		s, closed, err = cw.WaitContext(ctx)
+	} else {
+		s, closed, err = w.Watcher.Wait()
+	}
+
+	// The live file replaced the last one backfilled.
+	if s.Event == Created {
+		s.Event = Rotated
+	}
+	return s, closed, err
+}
+
+// open returns the status for reading b from its start.
+func (w *backfillWatcher) open(b backfillFile) (WaitStatus, error) {
+	state, err := NewFileState(b.f)
+	if err != nil {
+		return WaitStatus{}, err
+	}
+
+	s := WaitStatus{File: b.f, ReOpened: true}
+	if !isCompressed(b.f.Name()) {
+		if w.c.FingerprintBytes > 0 && state.Size > 0 {
+			size := state.Size
+			if size > w.c.FingerprintBytes {
+				size = w.c.FingerprintBytes
+			}
+
+			state.FingerprintSize = size
+			if state.Fingerprint, _, err = fingerprint(b.f, size); err != nil {
+				return WaitStatus{}, err
+			}
+		}
+
+		if state.Position, err = b.f.Seek(b.start, io.SeekStart); err != nil {
+			return WaitStatus{}, err
+		}
+		s.State = state
+		return s, nil
+	}
+
+	// Positions in a compressed file are after decompressing it, and
+	// its fingerprint is of the decompressed data, so it still matches
+	// the file it was compressed from.
+	if w.c.FingerprintBytes > 0 {
+		zr, err := newGzipReader(b.f)
+		if err != nil {
+			return WaitStatus{}, err
+		}
+
+		state.Fingerprint, state.FingerprintSize, err = hashPrefix(zr, w.c.FingerprintBytes)
+		if err != nil {
+			return WaitStatus{}, err
+		}
+	}
+
+	zr, err := newGzipReader(b.f)
+	if err != nil {
+		return WaitStatus{}, err
+	}
+
+	if _, err = io.CopyN(ioutil.Discard, zr, b.start); err != nil {
+		return WaitStatus{}, fmt.Errorf("skipping to %v in %s: %w", b.start, b.f.Name(), err)
+	}
+
+	state.Position = b.start
+	s.State = state
+	s.Reader = zr
+	return s, nil
+}
+
+// openArchived fetches b from the Archive and returns the status for
+// reading it from its start, or closed if w was closed while fetching it.
+func (w *backfillWatcher) openArchived(b backfillFile) (WaitStatus, bool, error) {
+	rc, err := w.c.Backfill.Archive.Fetch(b.archived)
+	if err != nil {
+		return WaitStatus{}, false, err
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		rc.Close()
+		return WaitStatus{}, true, nil
+	}
+	w.cur = rc
+	w.mu.Unlock()
+
+	r, err := decompress(b.archived, rc)
+	if err != nil {
+		return WaitStatus{}, false, err
+	}
+
+	// An archived file has no identity or known size, but its
+	// fingerprint still matches the file it was archived from.
+	var state FileState
+	if w.c.FingerprintBytes > 0 {
+		var prefix bytes.Buffer
+		state.Fingerprint, state.FingerprintSize, err = hashPrefix(io.TeeReader(r, &prefix), w.c.FingerprintBytes)
+		if err != nil {
+			return WaitStatus{}, false, err
+		}
+		r = io.MultiReader(&prefix, r)
+	}
+
+	if _, err = io.CopyN(ioutil.Discard, r, b.start); err != nil {
+		return WaitStatus{}, false, fmt.Errorf("skipping to %v in %s: %w", b.start, b.archived, err)
+	}
+
+	state.Position = b.start
+	state.Size = b.start
+	return WaitStatus{State: state, Reader: r, ReOpened: true}, false, nil
+}
+
+// decompress returns r decompressed if name ends in .gz.
+func decompress(name string, r io.Reader) (io.Reader, error) {
+	if !isCompressed(name) {
+		return r, nil
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err == io.EOF {
+		// The file is empty.
+		return r, nil
+	}
+	return zr, err
+}
+
+// newGzipReader decompresses f from its start.
+func newGzipReader(f *os.File) (io.Reader, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(f)
+	if err == io.EOF {
+		// The file is empty.
+		return f, nil
+	}
+	return zr, err
+}
+
+func (w *backfillWatcher) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		if w.cur != nil {
+			w.cur.Close()
+		}
+		closeBackfill(w.queue)
+		w.queue = nil
+	}
+	w.mu.Unlock()
+
+	return w.Watcher.Close()
+}
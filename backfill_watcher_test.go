@@ -0,0 +1,255 @@
+package tail
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func gzipFile(t *testing.T, name string) {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(name + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := gzip.NewWriter(f)
+	if _, err = w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLineReaderBackfill(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := ioutil.WriteFile(path, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{
+		Path:             path,
+		Interval:         time.Millisecond * 10,
+		FingerprintBytes: 16,
+		Backfill:         &BackfillConfig{},
+	}
+
+	r, err := NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "a")
+	state, _, _ := r.CloseAndState()
+
+	// While nothing is reading, the file is rotated and compressed,
+	// and the next one is rotated too.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("c\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".1", path+".2"); err != nil {
+		t.Fatal(err)
+	}
+	gzipFile(t, path+".2")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("d\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c.StartState = &state
+	r, err = NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "b")
+	readLine(t, r, "c")
+	readLine(t, r, "d")
+
+	// A state from before the first line finds the compressed file too.
+	r.Close()
+	c.StartState = &state
+	c.StartState.Position = 0
+	r, err = NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "a")
+}
+
+func TestLineReaderBackfillAll(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	now := time.Now()
+	for i, name := range []string{"app.log-31012024", "app.log-01022024.gz", "app.log"} {
+		name = filepath.Join(dir, name)
+		plain := strings.TrimSuffix(name, ".gz")
+		if err := ioutil.WriteFile(plain, []byte(string(rune('a'+i))+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if plain != name {
+			gzipFile(t, plain)
+		}
+
+		// The names don't sort by date, but the modification times do.
+		modTime := now.Add(time.Duration(i-2) * time.Hour)
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewLineReader(Config{
+		Path:     path,
+		Interval: time.Millisecond * 10,
+		Whence:   io.SeekEnd,
+		Backfill: &BackfillConfig{
+			Siblings: GlobSiblings(path + "-*"),
+			All:      true,
+		},
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "a")
+	readLine(t, r, "b")
+	readLine(t, r, "c")
+}
+
+// dirArchive is an Archive of the files in a directory, named by their
+// path in it.
+type dirArchive struct {
+	dir     string
+	fetched []string
+}
+
+func (a *dirArchive) List(path string) ([]string, error) {
+	return filepath.Glob(filepath.Join(a.dir, filepath.Base(path)+"-*"))
+}
+
+func (a *dirArchive) Fetch(name string) (io.ReadCloser, error) {
+	a.fetched = append(a.fetched, filepath.Base(name))
+	return os.Open(name)
+}
+
+func TestLineReaderBackfillArchive(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	archive := &dirArchive{dir: t.TempDir()}
+
+	if err := ioutil.WriteFile(path, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{
+		Path:             path,
+		Interval:         time.Millisecond * 10,
+		FingerprintBytes: 16,
+		Backfill:         &BackfillConfig{Archive: archive},
+	}
+
+	r, err := NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "a")
+	state, _, _ := r.CloseAndState()
+
+	// While nothing is reading, the file is compressed and moved to the
+	// archive, followed by the next one, and a third is rotated but kept.
+	for i, s := range []string{"c\n", "d\n"} {
+		name := filepath.Join(archive.dir, fmt.Sprintf("app.log-%d", i+1))
+		if err := os.Rename(path, name); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			gzipFile(t, name)
+		}
+		if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("e\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c.StartState = &state
+	r, err = NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "b")
+	readLine(t, r, "c")
+
+	// A state saved in an archived file finds it again.
+	archived, _, _ := r.CloseAndState()
+	if !archived.ID().IsZero() || archived.FingerprintSize != 2 {
+		t.Fatalf("expected an archived state without an identity, got %+v", archived)
+	}
+	archive.fetched = nil
+
+	c.StartState = &archived
+	r, err = NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "d")
+	readLine(t, r, "e")
+
+	// Only the archived files are fetched, newest first until the one
+	// the state was saved in, then again to read it.
+	expected := []string{"app.log-2", "app.log-2"}
+	if !reflect.DeepEqual(archive.fetched, expected) {
+		t.Fatalf("expected fetches %v, got %v", expected, archive.fetched)
+	}
+}
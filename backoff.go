@@ -0,0 +1,38 @@
+package tail
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff returns how long to wait before trying again after retries
+// failed attempts in a row, starting at 0 after the first failure.
+type Backoff func(retries int) time.Duration
+
+// ConstantBackoff always waits d.
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff waits min after the first failure, doubling with each
+// one after it up to max. Jitter is the fraction, from 0 to 1, that each
+// wait is randomly shortened by, so many readers that failed at the same
+// time don't all retry at once.
+func ExponentialBackoff(min, max time.Duration, jitter float64) Backoff {
+	return func(retries int) time.Duration {
+		d := min
+		for i := 0; i < retries && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+
+		if jitter > 0 {
+			d -= time.Duration(jitter * rand.Float64() * float64(d))
+		}
+		return d
+	}
+}
@@ -0,0 +1,57 @@
+package tail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+
+	b := ExponentialBackoff(time.Millisecond, time.Millisecond*10, 0)
+	for retries, expected := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		if actual := b(retries); actual != expected*time.Millisecond {
+			t.Errorf("retry %v: expected %v, got %v", retries, expected*time.Millisecond, actual)
+		}
+	}
+
+	b = ExponentialBackoff(time.Millisecond*10, time.Millisecond*10, 0.5)
+	for i := 0; i < 100; i++ {
+		if d := b(i); d < time.Millisecond*5 || d > time.Millisecond*10 {
+			t.Fatalf("expected a wait between 5ms and 10ms, got %v", d)
+		}
+	}
+}
+
+func TestLineReaderBackoffMissingFile(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-backoff-test")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		Backoff:  ConstantBackoff(time.Millisecond * 300),
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// The first poll doesn't find the file, so it isn't checked for
+	// again until the backoff passes.
+	if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+		t.Fatalf("expected a timeout, got %v, %v", ok, err)
+	}
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\n")
+
+	if ok, err := r.NextTimeout(time.Millisecond * 100); ok || err != ErrTimeout {
+		t.Fatalf("expected a timeout during the backoff, got %v, %v", ok, err)
+	}
+
+	readLine(t, r, "a")
+}
@@ -0,0 +1,75 @@
+package tail
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// NextBatch blocks until a line is available, then keeps reading lines
+// until it has max of them or flushAfter has passed since the first, and
+// returns them. Each Line.Bytes is a copy, so it stays valid after the
+// next call. If flushAfter is 0, only the lines that are available
+// without waiting are added to the first. Any part of a line read when
+// flushAfter passes is kept for the next call. If the LineReader stops,
+// the lines read so far are returned with Err(), or io.EOF if it was
+// closed without an error.
+func (l *LineReader) NextBatch(max int, flushAfter time.Duration) ([]Line, error) {
+	if max <= 0 {
+		return nil, errors.New("batch size must be greater than 0")
+	}
+
+	if !l.Next() {
+		return nil, l.stopErr()
+	}
+
+	lines := make([]Line, 0, max)
+	lines = append(lines, l.line())
+
+	if flushAfter <= 0 {
+		for len(lines) < max {
+			ok, more := l.TryNext()
+			if !ok {
+				if !more {
+					return lines, l.stopErr()
+				}
+				break
+			}
+			lines = append(lines, l.line())
+		}
+		return lines, nil
+	}
+
+	clock := l.c.clock()
+	ctx, cancel := withDeadline(context.Background(), clock, clock.Now().Add(flushAfter))
+	defer cancel()
+
+	for len(lines) < max {
+		ok, err := l.nextContext(ctx)
+		if err != nil {
+			break
+		}
+		if !ok {
+			return lines, l.stopErr()
+		}
+		lines = append(lines, l.line())
+	}
+	return lines, nil
+}
+
+// line returns a copy of the line last returned by Next.
+func (l *LineReader) line() Line {
+	line := l.Line()
+	line.Bytes = append([]byte(nil), line.Bytes...)
+	l.reuse = true
+	return line
+}
+
+// stopErr returns the reason Next returned false for NextBatch.
+func (l *LineReader) stopErr() error {
+	if l.err != nil {
+		return l.err
+	}
+	return io.EOF
+}
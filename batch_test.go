@@ -0,0 +1,70 @@
+package tail
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLineReaderNextBatch(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-batch-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb\nc\n")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := r.NextBatch(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || string(lines[0].Bytes) != "a" || string(lines[1].Bytes) != "b" {
+		t.Fatalf("expected a and b, got %q", lineStrings(lines))
+	}
+	if lines[1].State.Position != 4 || lines[1].Number != 2 {
+		t.Fatalf("expected position 4 and line 2, got %v and %v", lines[1].State.Position, lines[1].Number)
+	}
+
+	// The batch is flushed once flushAfter passes, keeping the part of
+	// the line read so far.
+	writeString(t, writer, "d")
+	lines, err = r.NextBatch(10, time.Millisecond*50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || string(lines[0].Bytes) != "c" {
+		t.Fatalf("expected c, got %q", lineStrings(lines))
+	}
+
+	writeString(t, writer, "\ne\n")
+	lines, err = r.NextBatch(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || string(lines[0].Bytes) != "d" || string(lines[1].Bytes) != "e" {
+		t.Fatalf("expected d and e, got %q", lineStrings(lines))
+	}
+
+	r.Close()
+	if lines, err = r.NextBatch(10, 0); len(lines) != 0 || err != io.EOF {
+		t.Fatalf("expected io.EOF, got %q, %v", lineStrings(lines), err)
+	}
+}
+
+func lineStrings(lines []Line) []string {
+	s := make([]string, len(lines))
+	for i, l := range lines {
+		s[i] = string(l.Bytes)
+	}
+	return s
+}
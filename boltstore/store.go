@@ -0,0 +1,140 @@
+//go:build !js && !wasip1
+// +build !js,!wasip1
+
+// Package boltstore provides a tail.CheckpointStore backed by a bbolt
+// database, so the states of many files can be kept in one place and
+// saved without rewriting all of them each time. bbolt doesn't support
+// js/wasm or wasip1, so it's empty there.
+package boltstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+	bolt "go.etcd.io/bbolt"
+)
+
+var _ tail.CheckpointStore = (*Store)(nil)
+
+// bucket holds a state for each path.
+var bucket = []byte("checkpoints")
+
+// Store is a tail.CheckpointStore that saves each state in a bbolt
+// database. States are keyed by path, and include the inode and device of
+// the file, which Load compares with the file now at the path so a
+// different one isn't mistaken for it.
+type Store struct {
+	db *bolt.DB
+	// owned is set if db was opened by Open and is closed with the Store.
+	owned bool
+}
+
+// Open opens or creates the database file name. Only one process can have
+// it open at a time, and Open waits up to timeout for another one to close
+// it, or forever if timeout is 0.
+func Open(name string, mode os.FileMode, timeout time.Duration) (*Store, error) {
+	db, err := bolt.Open(name, mode, &bolt.Options{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db, owned: true}, nil
+}
+
+// New returns a Store that uses an already open database. Closing the
+// Store doesn't close db.
+func New(db *bolt.DB) (*Store, error) {
+	if db == nil {
+		return nil, errors.New("database cannot be nil")
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Load returns the state saved for path, or nil if there isn't one or
+// a different file is at path now. A state is still returned if there's
+// no file at path, or its identity isn't known on either side, so it can
+// be found after a rotation or resumed by position.
+func (s *Store) Load(path string) (*tail.FileState, error) {
+	var state *tail.FileState
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket).Get([]byte(path))
+		if b == nil {
+			return nil
+		}
+
+		state = &tail.FileState{}
+		return json.Unmarshal(b, state)
+	})
+	if err != nil || state == nil {
+		return nil, err
+	}
+
+	named, err := tail.NewFileStateFromPath(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !named.ID().IsZero() && !state.ID().IsZero() && !named.ID().Equal(state.ID()) {
+		return nil, nil
+	}
+	return state, nil
+}
+
+func (s *Store) Save(path string, state tail.FileState) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(path), b)
+	})
+}
+
+// Delete removes the state for path, such as when it's no longer tailed.
+func (s *Store) Delete(path string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(path))
+	})
+}
+
+// Paths returns every path with a saved state.
+func (s *Store) Paths() ([]string, error) {
+	var paths []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, _ []byte) error {
+			paths = append(paths, string(k))
+			return nil
+		})
+	})
+	return paths, err
+}
+
+// Close closes the database if it was opened by Open.
+func (s *Store) Close() error {
+	if !s.owned {
+		return nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,144 @@
+//go:build !js && !wasip1
+// +build !js,!wasip1
+
+package boltstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+func TestStore(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "checkpoints.db")
+
+	s, err := Open(name, 0600, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state, err := s.Load("a.log"); err != nil || state != nil {
+		t.Fatalf("expected no state, got %v, %v", state, err)
+	}
+
+	for i := 0; i < 100; i++ {
+		path := filepath.Join("logs", string(rune('a'+i%26)), "app.log")
+		if err := s.Save(path, tail.FileState{Position: int64(i), Inode: uint64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Delete(filepath.Join("logs", "a", "app.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening reads back what was saved last for each path.
+	s, err = Open(name, 0600, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	state, err := s.Load(filepath.Join("logs", "b", "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (&tail.FileState{Position: 79, Inode: 79}); !reflect.DeepEqual(expected, state) {
+		t.Fatalf("expected %v, got %v", expected, state)
+	}
+
+	paths, err := s.Paths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 25 {
+		t.Fatalf("expected 25 paths, got %v", len(paths))
+	}
+}
+
+func TestStoreLineReader(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(path, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(filepath.Join(dir, "checkpoints.db"), 0600, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c := tail.Config{
+		Path:       path,
+		Interval:   time.Millisecond * 10,
+		Checkpoint: &tail.CheckpointConfig{Store: s},
+	}
+
+	r, err := tail.NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Next() || string(r.Bytes()) != "a" {
+		t.Fatalf("expected line 'a', got '%s'", r.Bytes())
+	}
+	r.Close()
+
+	// Resumes after the line that was read.
+	r, err = tail.NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if !r.Next() || string(r.Bytes()) != "b" {
+		t.Fatalf("expected line 'b', got '%s'", r.Bytes())
+	}
+}
+
+func TestStoreReplaced(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(path, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(filepath.Join(dir, "checkpoints.db"), 0600, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	state, err := tail.NewFileStateFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(path, *state); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded, err := s.Load(path); err != nil || loaded == nil {
+		t.Fatalf("expected the saved state, got %v, %v", loaded, err)
+	}
+
+	// Keeping the old file around keeps its inode from being reused.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded, err := s.Load(path); err != nil || loaded != nil {
+		t.Fatalf("expected no state for a different file, got %v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,246 @@
+package tail
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CheckpointStore saves the FileState each path was read up to, so a
+// LineReader can resume from it after a restart. Implementations must be
+// safe to call from multiple goroutines.
+type CheckpointStore interface {
+	// Load returns the state saved for path, or nil if there isn't one.
+	Load(path string) (*FileState, error)
+	// Save replaces the state saved for path.
+	Save(path string, s FileState) error
+}
+
+// CheckpointConfig configures a LineReader to save its FileState.
+type CheckpointConfig struct {
+	// Store is where states are loaded from and saved to.
+	Store CheckpointStore
+
+	// Interval is the least amount of time between saves while lines
+	// are being returned. The state is always saved before waiting for
+	// more data and when the LineReader is closed. 0 saves after every
+	// line.
+	Interval time.Duration
+}
+
+// FileCheckpointStore is a CheckpointStore that keeps the states of every
+// path in a single JSON file. Each save replaces the file atomically, so
+// it's never left partially written if the process crashes. It's also a
+// LeaseStore, so a Standby can share the file with the process it takes
+// over from.
+type FileCheckpointStore struct {
+	name string
+
+	mu     sync.Mutex
+	states map[string]FileState
+	// owner is who the lease was acquired for, if it was.
+	owner string
+}
+
+// fileLease is what's in the lease file of a FileCheckpointStore.
+type fileLease struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewFileCheckpointStore returns a FileCheckpointStore that saves to the
+// file name, loading the states already in it if it exists.
+func NewFileCheckpointStore(name string) (*FileCheckpointStore, error) {
+	s := &FileCheckpointStore{
+		name:   name,
+		states: make(map[string]FileState),
+	}
+
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// read replaces the states with the ones in the file.
+func (s *FileCheckpointStore) read() error {
+	b, err := ioutil.ReadFile(s.name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	states := make(map[string]FileState)
+	if err = json.Unmarshal(b, &states); err != nil {
+		return err
+	}
+	s.states = states
+	return nil
+}
+
+func (s *FileCheckpointStore) Load(path string) (*FileState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[path]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+func (s *FileCheckpointStore) Save(path string, state FileState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owner != "" {
+		lease, err := s.readLease()
+		if err != nil {
+			return err
+		} else if lease.Owner != s.owner {
+			return ErrLeaseLost
+		}
+	}
+
+	prev, existed := s.states[path]
+	s.states[path] = state
+
+	if err := s.write(); err != nil {
+		// Keep what is on disk.
+		if existed {
+			s.states[path] = prev
+		} else {
+			delete(s.states, path)
+		}
+		return err
+	}
+	return nil
+}
+
+// Acquire takes or renews the lease kept in a file next to the states,
+// named like it with .lease added. When owner takes it from another, the
+// states are read again to resume from the last ones saved, and once
+// another owner takes it, Save returns ErrLeaseLost. Taking it isn't
+// atomic between processes, so only one should be waiting for it, like
+// the Standby of an active and standby pair.
+func (s *FileCheckpointStore) Acquire(owner string, now time.Time, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lease, err := s.readLease()
+	if err != nil {
+		return false, err
+	}
+	if lease.Owner != "" && lease.Owner != owner && now.Before(lease.Expires) {
+		return false, nil
+	}
+
+	b, err := json.Marshal(fileLease{Owner: owner, Expires: now.Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+	if err = writeFile(s.leaseName(), b); err != nil {
+		return false, err
+	}
+
+	if s.owner != owner {
+		if err = s.read(); err != nil {
+			return false, err
+		}
+		s.owner = owner
+	}
+	return true, nil
+}
+
+// Release removes the lease file if owner holds the lease.
+func (s *FileCheckpointStore) Release(owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owner == owner {
+		s.owner = ""
+	}
+
+	lease, err := s.readLease()
+	if err != nil || lease.Owner != owner {
+		return err
+	}
+
+	if err = os.Remove(s.leaseName()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileCheckpointStore) leaseName() string {
+	return s.name + ".lease"
+}
+
+// readLease returns the lease in the lease file, which is empty if there
+// isn't one.
+func (s *FileCheckpointStore) readLease() (fileLease, error) {
+	var lease fileLease
+	b, err := ioutil.ReadFile(s.leaseName())
+	if os.IsNotExist(err) {
+		return lease, nil
+	} else if err != nil {
+		return lease, err
+	}
+
+	err = json.Unmarshal(b, &lease)
+	return lease, err
+}
+
+// write replaces the file with the states, with mu held.
+func (s *FileCheckpointStore) write() error {
+	b, err := json.Marshal(s.states)
+	if err != nil {
+		return err
+	}
+	return writeFile(s.name, b)
+}
+
+// writeFile replaces the file name with b. It's written to a temporary
+// file in the same directory and synced before it's renamed over name,
+// and the directory is synced so the rename itself is durable.
+func writeFile(name string, b []byte) error {
+	dir := filepath.Dir(name)
+	f, err := ioutil.TempFile(dir, filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(b); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Rename(f.Name(), name); err != nil {
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the entries of dir to disk. Errors are ignored, since
+// not every platform supports syncing a directory, and the rename has
+// already happened either way.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,122 @@
+package tail
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "checkpoints.json")
+
+	s, err := NewFileCheckpointStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state, err := s.Load("a.log"); err != nil || state != nil {
+		t.Fatalf("expected no state, got %v, %v", state, err)
+	}
+
+	if err := s.Save("a.log", FileState{Position: 5, Inode: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save("b.log", FileState{Position: 7, Inode: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening reads back what was saved.
+	s, err = NewFileCheckpointStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := s.Load("a.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state == nil || state.Position != 5 || state.Inode != 1 {
+		t.Fatalf("expected position 5 and inode 1, got %v", state)
+	}
+
+	// Only the store itself is left, without temporary files.
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 file, got %v", len(infos))
+	}
+}
+
+func TestLineReaderCheckpoint(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-checkpoint-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb\n")
+
+	store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{
+		Path:       h.Path(),
+		Interval:   time.Millisecond * 10,
+		Checkpoint: &CheckpointConfig{Store: store, Interval: time.Hour},
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readLine(t, r, "a")
+
+	// The first line is saved right away, but the second one isn't
+	// until it catches up, since Interval hasn't passed.
+	state, err := store.Load(h.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state == nil || state.Position != 2 {
+		t.Fatalf("expected position 2, got %v", state)
+	}
+
+	readLine(t, r, "b")
+	if state, _ = store.Load(h.Path()); state.Position != 2 {
+		t.Fatalf("expected position 2, got %v", state.Position)
+	}
+
+	if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+		t.Fatalf("expected a timeout, got %v, %v", ok, err)
+	}
+	if state, _ = store.Load(h.Path()); state.Position != 4 {
+		t.Fatalf("expected position 4, got %v", state.Position)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new LineReader resumes from the saved state.
+	writeString(t, writer, "c\n")
+
+	r, err = NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "c")
+}
@@ -0,0 +1,251 @@
+package tail
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is how the polling Watcher and LineReader tell time and wait,
+// which can be replaced with Config.Clock to control time in tests, such
+// as with a ManualClock.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a Timer that sends the time on its channel once
+	// d has passed.
+	NewTimer(d time.Duration) Timer
+	// NewTicker returns a Ticker that sends the time on its channel
+	// every d, dropping ticks if they aren't received in time.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a time.Timer from a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker is a time.Ticker from a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// clock returns the Clock to use for c.
+func (c Config) clock() Clock {
+	if c.Clock == nil {
+		return realClock{}
+	}
+	return c.Clock
+}
+
+// realClock is the default Clock, using the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+func (t realTimer) Stop() bool          { return t.t.Stop() }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// withDeadline is context.WithDeadline for deadline on clock.
+func withDeadline(ctx context.Context, clock Clock, deadline time.Time) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(realClock); ok {
+		return context.WithDeadline(ctx, deadline)
+	}
+
+	c := &clockContext{Context: ctx, deadline: deadline, done: make(chan struct{})}
+	cancel := make(chan struct{})
+	var once sync.Once
+
+	timer := clock.NewTimer(deadline.Sub(clock.Now()))
+	go func() {
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			c.finish(ctx.Err())
+		case <-timer.C():
+			c.finish(context.DeadlineExceeded)
+		case <-cancel:
+			c.finish(context.Canceled)
+		}
+	}()
+	return c, func() { once.Do(func() { close(cancel) }) }
+}
+
+// clockContext is a context with a deadline on a Clock other than the
+// real one.
+type clockContext struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *clockContext) finish(err error) {
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *clockContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *clockContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// ManualClock is a Clock that only moves when Advance is called, so
+// tests can decide exactly when polls and timeouts happen.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*manualWaiter
+	changed chan struct{}
+}
+
+// NewManualClock returns a ManualClock starting at now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now, changed: make(chan struct{})}
+}
+
+// manualWaiter is a Timer, or a Ticker if period is set.
+type manualWaiter struct {
+	c      *ManualClock
+	ch     chan time.Time
+	at     time.Time
+	period time.Duration
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	return c.add(d, 0)
+}
+
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+	return manualTicker{c.add(d, d)}
+}
+
+func (c *ManualClock) add(d, period time.Duration) *manualWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &manualWaiter{c: c, ch: make(chan time.Time, 1), at: c.now.Add(d), period: period}
+	if d <= 0 && period == 0 {
+		w.ch <- c.now
+		return w
+	}
+	c.waiters = append(c.waiters, w)
+	c.notify()
+	return w
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers
+// that are due by then.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+
+		select {
+		case w.ch <- w.at:
+		default:
+		}
+
+		if w.period > 0 {
+			for !w.at.After(c.now) {
+				w.at = w.at.Add(w.period)
+			}
+			waiters = append(waiters, w)
+		}
+	}
+	c.waiters = waiters
+	c.notify()
+}
+
+// BlockUntil blocks until there are n timers and tickers waiting on the
+// clock, which is how a test knows that what it's testing is waiting
+// before calling Advance.
+func (c *ManualClock) BlockUntil(n int) {
+	c.mu.Lock()
+	for len(c.waiters) != n {
+		changed := c.changed
+		c.mu.Unlock()
+		<-changed
+		c.mu.Lock()
+	}
+	c.mu.Unlock()
+}
+
+// notify wakes BlockUntil, with mu held.
+func (c *ManualClock) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (w *manualWaiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *manualWaiter) Stop() bool {
+	c := w.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, other := range c.waiters {
+		if other == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.notify()
+			return true
+		}
+	}
+	return false
+}
+
+// manualTicker is a manualWaiter with a Stop that doesn't return
+// anything, like a Ticker's.
+type manualTicker struct{ *manualWaiter }
+
+func (t manualTicker) Stop() { t.manualWaiter.Stop() }
@@ -0,0 +1,132 @@
+package tail
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManualClock(start)
+
+	timer := c.NewTimer(time.Second * 2)
+	ticker := c.NewTicker(time.Second)
+	c.BlockUntil(2)
+
+	c.Advance(time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+	if tick := <-ticker.C(); !tick.Equal(start.Add(time.Second)) {
+		t.Fatalf("expected a tick at 1s, got %v", tick)
+	}
+
+	// Ticks that aren't received are dropped.
+	c.Advance(time.Second * 3)
+	if fired := <-timer.C(); !fired.Equal(start.Add(time.Second * 2)) {
+		t.Fatalf("expected the timer at 2s, got %v", fired)
+	}
+	if tick := <-ticker.C(); !tick.Equal(start.Add(time.Second * 2)) {
+		t.Fatalf("expected a tick at 2s, got %v", tick)
+	}
+	select {
+	case tick := <-ticker.C():
+		t.Fatalf("unexpected tick at %v", tick)
+	default:
+	}
+
+	if timer.Stop() {
+		t.Fatal("expected a fired timer not to be stopped")
+	}
+	ticker.Stop()
+	c.BlockUntil(0)
+	if now := c.Now(); !now.Equal(start.Add(time.Second * 4)) {
+		t.Fatalf("expected it to be 4s later, got %v", now)
+	}
+}
+
+func TestLineReaderManualClock(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "clock.log")
+	if err := ioutil.WriteFile(p, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewManualClock(start)
+
+	r, err := NewLineReader(Config{
+		Path:               p,
+		Interval:           time.Second,
+		PartialLineTimeout: time.Minute,
+		Clock:              c,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	done := make(chan bool)
+	go func() { done <- r.Next() }()
+
+	// The first poll opens the file, then the partial line is waited on
+	// until a minute after it was read.
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	c.BlockUntil(2)
+	c.Advance(time.Minute)
+
+	if !<-done {
+		t.Fatal(r.Err())
+	}
+	line := r.Line()
+	if string(line.Bytes) != "abc" || !line.Partial || !line.ReadTime.Equal(start.Add(time.Minute+time.Second)) {
+		t.Fatalf("expected partial line abc after a minute, got %+v", line)
+	}
+
+	// NextTimeout is measured on the clock as well.
+	result := make(chan error)
+	go func() {
+		_, err := r.NextTimeout(time.Hour)
+		result <- err
+	}()
+	c.BlockUntil(2)
+	c.Advance(time.Hour)
+	if err := <-result; err != ErrTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestWatcherManualClock(t *testing.T) {
+	c := NewManualClock(time.Now())
+
+	w, err := NewPollingWatcher(Config{
+		Path:             filepath.Join(t.TempDir(), "missing.log"),
+		Interval:         time.Second,
+		MustExist:        true,
+		MustExistTimeout: time.Hour,
+		Clock:            c,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	result := make(chan error)
+	go func() {
+		_, _, err := w.Wait()
+		result <- err
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Hour)
+
+	var notFound *FileNotFoundError
+	if err := <-result; !errors.As(err, &notFound) || notFound.Waited != time.Hour {
+		t.Fatalf("expected the file not to be found after an hour, got %v", err)
+	}
+}
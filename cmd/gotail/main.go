@@ -0,0 +1,302 @@
+// Command gotail follows files like tail -F, printing lines as they're
+// written and following each file by name across rotations.
+//
+// Usage:
+//
+//	gotail [flags] file...
+//
+// With more than one file, each run of lines is preceded by a header with
+// the name of the file it's from, unless -q is set. With -state, how far
+// each file was printed is saved to a file, and the next run resumes from
+// there instead of printing the last lines again.
+//
+// With -files-from, the files to follow are also read from a manifest with
+// a path or glob on each line, ignoring blank lines and ones starting with
+// #. It's read again every -s, and files are followed or stopped as they're
+// added to or removed from it, or as they come to match or stop matching
+// its globs, such as when another process keeps it up to date. It should
+// be replaced by renaming a new one over it, so it's never read half
+// written.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+func main() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	stop := make(chan struct{})
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, stop))
+}
+
+// run follows the files in args until stop is closed, and returns the
+// exit code.
+func run(args []string, stdout, stderr io.Writer, stop <-chan struct{}) int {
+	flags := flag.NewFlagSet("gotail", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gotail [flags] file...")
+		flags.PrintDefaults()
+	}
+
+	lines := flags.Int("n", 10, "print the last `N` lines of each file before following it")
+	quiet := flags.Bool("q", false, "never print headers with file names")
+	verbose := flags.Bool("v", false, "always print headers with file names")
+	state := flags.String("state", "", "save how far each file was printed to `FILE`, and resume from it")
+	interval := flags.Duration("s", time.Second, "how often to check files for changes")
+	filesFrom := flags.String("files-from", "", "also follow the paths and globs listed in `FILE`, as it changes")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (flags.NArg() == 0 && *filesFrom == "") || *lines < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var store tail.CheckpointStore
+	if *state != "" {
+		s, err := tail.NewFileCheckpointStore(*state)
+		if err != nil {
+			fmt.Fprintf(stderr, "gotail: %v\n", err)
+			return 1
+		}
+		store = s
+	}
+
+	t := &tails{
+		p: &printer{
+			w:       stdout,
+			headers: *verbose || ((flags.NArg() > 1 || *filesFrom != "") && !*quiet),
+		},
+		lines:    *lines,
+		interval: *interval,
+		store:    store,
+		stderr:   stderr,
+		readers:  make(map[string]*tail.LineReader),
+	}
+
+	names := flags.Args()
+	if *filesFrom != "" {
+		listed, err := readManifest(*filesFrom)
+		if err != nil {
+			fmt.Fprintf(stderr, "gotail: %v\n", err)
+			return 1
+		}
+		names = append(names, listed...)
+	}
+
+	if err := t.set(names); err != nil {
+		fmt.Fprintf(stderr, "gotail: %v\n", err)
+		t.close()
+		return 1
+	}
+
+	if *filesFrom != "" {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+
+	Reload:
+		for {
+			select {
+			case <-stop:
+				break Reload
+			case <-ticker.C:
+			}
+
+			// The files being followed are kept if the manifest can't be
+			// read, since it may only be missing while it's replaced.
+			listed, err := readManifest(*filesFrom)
+			if err != nil {
+				fmt.Fprintf(stderr, "gotail: %v\n", err)
+				continue
+			}
+
+			// Only the files that can't be opened are skipped, and
+			// they're tried again on the next reload.
+			if err := t.set(append(flags.Args(), listed...)); err != nil {
+				fmt.Fprintf(stderr, "gotail: %v\n", err)
+			}
+		}
+	} else {
+		<-stop
+	}
+
+	return t.close()
+}
+
+// readManifest returns the files listed in the manifest name, with its
+// globs expanded to the files that match them now.
+func readManifest(name string) ([]string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !strings.ContainsAny(line, "*?[") {
+			names = append(names, line)
+			continue
+		}
+
+		matches, err := filepath.Glob(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: %w", name, line, err)
+		}
+		names = append(names, matches...)
+	}
+	return names, nil
+}
+
+// tails are the files being followed, each printed by a goroutine of its
+// own.
+type tails struct {
+	p        *printer
+	lines    int
+	interval time.Duration
+	store    tail.CheckpointStore
+	stderr   io.Writer
+
+	readers map[string]*tail.LineReader
+	wg      sync.WaitGroup
+}
+
+// set follows the files in names and stops following any others. Files
+// that can't be opened are skipped, and the first error is returned.
+func (t *tails) set(names []string) error {
+	keep := make(map[string]bool)
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	for name, r := range t.readers {
+		if keep[name] {
+			continue
+		}
+		// Closing saves the state of the last line printed.
+		if err := r.Close(); err != nil {
+			fmt.Fprintf(t.stderr, "gotail: %v\n", err)
+		}
+		delete(t.readers, name)
+	}
+
+	var first error
+	for _, name := range names {
+		if _, ok := t.readers[name]; ok {
+			continue
+		}
+
+		r, err := open(name, t.lines, t.interval, t.store, t.stderr)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		t.readers[name] = r
+
+		t.wg.Add(1)
+		go func(name string, r *tail.LineReader) {
+			defer t.wg.Done()
+			for r.Next() {
+				t.p.print(name, r.Bytes())
+			}
+		}(name, r)
+	}
+	return first
+}
+
+// close stops following every file, and returns the exit code.
+func (t *tails) close() int {
+	code := 0
+	for _, r := range t.readers {
+		// Closing saves the state of the last line printed.
+		if err := r.Close(); err != nil {
+			fmt.Fprintf(t.stderr, "gotail: %v\n", err)
+			code = 1
+		}
+	}
+	t.wg.Wait()
+	return code
+}
+
+// open returns a LineReader for the file name, which starts with its last
+// lines, or where it was left off if it's in store.
+func open(name string, lines int, interval time.Duration, store tail.CheckpointStore, stderr io.Writer) (*tail.LineReader, error) {
+	// The state is saved by the absolute path, so it's found again from
+	// another directory.
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := tail.Config{
+		Path:      path,
+		Interval:  interval,
+		TailLines: lines,
+	}
+	if lines == 0 {
+		c.Whence = io.SeekEnd
+	}
+	if store != nil {
+		c.Checkpoint = &tail.CheckpointConfig{Store: store, Interval: time.Second}
+	}
+
+	// Errors are reported but not fatal, since the file may become
+	// readable again, like with tail -F.
+	return tail.NewLineReader(c, func(err error) error {
+		fmt.Fprintf(stderr, "gotail: %s: %v\n", name, err)
+		return nil
+	})
+}
+
+// printer writes lines from each file, with a header whenever the file
+// they're from changes.
+type printer struct {
+	w       io.Writer
+	headers bool
+
+	mu   sync.Mutex
+	last string
+}
+
+func (p *printer) print(name string, line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.headers && name != p.last {
+		if p.last != "" {
+			fmt.Fprintln(p.w)
+		}
+		fmt.Fprintf(p.w, "==> %s <==\n", name)
+		p.last = name
+	}
+
+	p.w.Write(line)
+	p.w.Write([]byte{'\n'})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer that can be read while run writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// follow runs gotail with args until it has printed expected.
+func follow(t *testing.T, args []string, expected string, write func()) {
+	t.Helper()
+
+	var stdout, stderr syncBuffer
+	stop := make(chan struct{})
+	code := make(chan int)
+	go func() {
+		code <- run(args, &stdout, &stderr, stop)
+	}()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for written := false; stdout.String() != expected; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %q, got %q", expected, stdout.String())
+		}
+
+		// Only write once the existing lines were printed.
+		if !written && write != nil && strings.HasPrefix(expected, stdout.String()) && stdout.String() != "" {
+			write()
+			written = true
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	close(stop)
+	if c := <-code; c != 0 {
+		t.Fatalf("expected exit code 0, got %v: %s", c, stderr.String())
+	}
+}
+
+func appendFile(t *testing.T, name, s string) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.log")
+	if err := ioutil.WriteFile(name, []byte("1\n2\n3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	follow(t, []string{"-s", "10ms", "-n", "2", "-v", name}, "==> "+name+" <==\n2\n3\n4\n", func() {
+		appendFile(t, name, "4\n")
+	})
+}
+
+func TestRunState(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.log")
+	state := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(name, []byte("1\n2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"-s", "10ms", "-state", state, name}
+	follow(t, args, "1\n2\n", nil)
+
+	// The next run resumes where the last one stopped, rather than
+	// printing the last lines again.
+	appendFile(t, name, "3\n")
+	follow(t, args, "3\n", nil)
+}
+
+func TestRunFilesFrom(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.log")
+	b := filepath.Join(dir, "b.log")
+	manifest := filepath.Join(dir, "files")
+	for name, s := range map[string]string{
+		a:        "a1\n",
+		b:        "b1\n",
+		manifest: "# followed files\n\n" + a + "\n",
+	} {
+		if err := ioutil.WriteFile(name, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr syncBuffer
+	stop := make(chan struct{})
+	code := make(chan int)
+	go func() {
+		code <- run([]string{"-s", "10ms", "--files-from", manifest}, &stdout, &stderr, stop)
+	}()
+
+	wait := func(expected string) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second * 5)
+		for stdout.String() != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %q, got %q", expected, stdout.String())
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+
+	expected := "==> " + a + " <==\na1\n"
+	wait(expected)
+
+	// Replacing a with a glob matching b stops following a.
+	if err := ioutil.WriteFile(manifest, []byte(filepath.Join(dir, "b*.log")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected += "\n==> " + b + " <==\nb1\n"
+	wait(expected)
+
+	appendFile(t, a, "a2\n")
+	appendFile(t, b, "b2\n")
+	expected += "b2\n"
+	wait(expected)
+
+	close(stop)
+	if c := <-code; c != 0 {
+		t.Fatalf("expected exit code 0, got %v: %s", c, stderr.String())
+	}
+	if stdout.String() != expected {
+		t.Fatalf("expected %q after stopping, got %q", expected, stdout.String())
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run(nil, ioutil.Discard, &stderr, nil); code != 2 {
+		t.Fatalf("expected exit code 2, got %v", code)
+	}
+	if !strings.HasPrefix(stderr.String(), "usage: gotail") {
+		t.Fatalf("expected usage, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,62 @@
+package tail
+
+// advance moves the state read up to to a line boundary, with mu held.
+// Without ManualCommit the state moves along with it, and otherwise only
+// once there's nothing left to commit before it.
+func (l *LineReader) advance(state FileState) {
+	l.read = state
+	l.readGen = l.generation
+	if !l.pending {
+		l.commit(state, l.generation)
+	}
+}
+
+// commit moves the state to resume from, with mu held.
+func (l *LineReader) commit(state FileState, generation int64) {
+	l.state = state
+	l.commitGen = generation
+	l.unsaved = true
+}
+
+// Commit marks every line returned by Next so far as processed with
+// Config.ManualCommit, so FileState moves to the end of the last one. The
+// Checkpoint is saved as usual once it moves, and not by Commit itself.
+// It does nothing without ManualCommit, and is safe to call in parallel
+// to Next, though lines returned in the meantime are committed too.
+func (l *LineReader) Commit() {
+	if !l.c.ManualCommit {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.commit(l.read, l.readGen)
+	l.pending = false
+}
+
+// CommitLine is Commit for line and the lines before it, such as when
+// lines from NextBatch are processed on other goroutines. Committing a
+// line from before one already committed does nothing, so lines can be
+// committed in any order, but the state only ever moves forward to the
+// latest one.
+func (l *LineReader) CommitLine(line Line) {
+	if !l.c.ManualCommit {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if line.Generation < l.commitGen ||
+		(line.Generation == l.commitGen && line.State.Position <= l.state.Position) {
+		return
+	}
+
+	if line.Generation == l.readGen && line.State.Position == l.read.Position {
+		l.commit(l.read, l.readGen)
+		l.pending = false
+		return
+	}
+	l.commit(line.State, line.Generation)
+}
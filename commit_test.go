@@ -0,0 +1,95 @@
+package tail
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLineReaderManualCommit(t *testing.T) {
+	h := NewWatcherHarness(t, "manual-commit-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb\nc\n")
+
+	store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{
+		Path:         h.Path(),
+		Interval:     time.Millisecond * 10,
+		Checkpoint:   &CheckpointConfig{Store: store},
+		ManualCommit: true,
+	}
+
+	r, err := NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readLine(t, r, "a")
+	a := r.Line()
+	readLine(t, r, "b")
+	if pos := r.FileState().Position; pos != 0 {
+		t.Fatalf("expected position 0 before committing, got %v", pos)
+	}
+
+	r.Commit()
+	if pos := r.FileState().Position; pos != 4 {
+		t.Fatalf("expected position 4 after committing, got %v", pos)
+	}
+
+	readLine(t, r, "c")
+	c3 := r.Line()
+
+	// Committing an earlier line doesn't move the state back.
+	r.CommitLine(a)
+	if pos := r.FileState().Position; pos != 4 {
+		t.Fatalf("expected position 4 after committing an earlier line, got %v", pos)
+	}
+
+	r.CommitLine(c3)
+	if pos := r.FileState().Position; pos != 6 {
+		t.Fatalf("expected position 6 after committing the last line, got %v", pos)
+	}
+
+	// A line that's returned but not committed is read again after a
+	// restart.
+	writeString(t, writer, "d\n")
+	readLine(t, r, "d")
+	state, _, err := r.CloseAndState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Position != 6 {
+		t.Fatalf("expected position 6 when closed, got %v", state.Position)
+	}
+
+	r, err = NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	readLine(t, r, "d")
+}
+
+func TestLineReaderCommitWithoutManualCommit(t *testing.T) {
+	h := NewWatcherHarness(t, "commit-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\n")
+
+	r, err := NewLineReader(Config{Path: h.Path(), Interval: time.Millisecond * 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "a")
+	r.Commit()
+	if pos := r.FileState().Position; pos != 2 {
+		t.Fatalf("expected position 2, got %v", pos)
+	}
+}
@@ -0,0 +1,210 @@
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+var _ io.ReadSeeker = (*ConcatReader)(nil)
+var _ io.ReaderAt = (*ConcatReader)(nil)
+
+// ConcatReader presents a family of rotated files as one continuous stream,
+// oldest first. The files are expected to be named like Path.N through
+// Path.1, followed by Path itself, which is how a rotation by renaming
+// usually leaves them. Offsets into the stream are global across all of
+// the files, so historical data can be addressed with a single number.
+//
+// The files are all opened and their sizes recorded when the ConcatReader
+// is created, so it is a snapshot: rotations after that point don't affect
+// it, and data written to the live file afterwards won't be read.
+type ConcatReader struct {
+	segments []segment
+	size     int64
+	off      int64
+}
+
+type segment struct {
+	name  string
+	f     *os.File
+	state FileState
+	start int64
+}
+
+// NewConcatReader opens every file in the rotation family of path.
+// It returns an error if none of them exist.
+func NewConcatReader(path string) (*ConcatReader, error) {
+	var segments []segment
+
+	closeAll := func() {
+		for _, s := range segments {
+			s.f.Close()
+		}
+	}
+
+	for i := 0; ; i++ {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%v", path, i)
+		}
+
+		f, err := openFile(name)
+		if os.IsNotExist(err) {
+			// The live file may be missing right after a rotation,
+			// so keep looking for older files.
+			if i == 0 {
+				continue
+			}
+			break
+		} else if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		state, err := NewFileState(f)
+		if err != nil {
+			f.Close()
+			closeAll()
+			return nil, err
+		}
+
+		// A rotation while opening can shift the same file to the next
+		// name, so don't include it twice.
+		if containsFile(segments, state.ID()) {
+			f.Close()
+			continue
+		}
+
+		segments = append(segments, segment{name: name, f: f, state: state})
+	}
+
+	if len(segments) == 0 {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	// Segments were found newest first, but the stream starts at the oldest.
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+
+	c := &ConcatReader{segments: segments}
+	for i := range c.segments {
+		c.segments[i].start = c.size
+		c.size += c.segments[i].state.Size
+	}
+	return c, nil
+}
+
+func containsFile(segments []segment, id FileID) bool {
+	for _, s := range segments {
+		if s.state.ID().Equal(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Size returns the total size of all the files in the stream.
+func (c *ConcatReader) Size() int64 {
+	return c.size
+}
+
+// find returns the index of the segment containing offset.
+func (c *ConcatReader) find(offset int64) int {
+	return sort.Search(len(c.segments), func(i int) bool {
+		s := c.segments[i]
+		return s.start+s.state.Size > offset
+	})
+}
+
+// ReadAt reads from the global offset off, crossing file boundaries
+// as needed.
+func (c *ConcatReader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	for n < len(p) {
+		i := c.find(off)
+		if i == len(c.segments) {
+			return n, io.EOF
+		}
+
+		s := c.segments[i]
+		buf := p[n:]
+		if remaining := s.start + s.state.Size - off; int64(len(buf)) > remaining {
+			buf = buf[:remaining]
+		}
+
+		read, err := s.f.ReadAt(buf, off-s.start)
+		n += read
+		off += int64(read)
+
+		// The file may have grown, but only the recorded size is part
+		// of the stream, so an EOF is only an error if it's short.
+		if err != nil && !(err == io.EOF && read == len(buf)) {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (c *ConcatReader) Read(p []byte) (int, error) {
+	n, err := c.ReadAt(p, c.off)
+	c.off += int64(n)
+
+	// Like other readers, don't report EOF with a full read.
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the global offset for the next Read.
+func (c *ConcatReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.off
+	case io.SeekEnd:
+		offset += c.size
+	default:
+		return 0, fmt.Errorf("%w: %v", ErrBadWhence, whence)
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	c.off = offset
+	return offset, nil
+}
+
+// Locate translates a global offset into the name of the file containing
+// it and its FileState, with the Position set to the offset within that
+// file. The name is as of when the ConcatReader was created.
+func (c *ConcatReader) Locate(offset int64) (name string, state FileState, err error) {
+	i := c.find(offset)
+	if offset < 0 || i == len(c.segments) {
+		return "", FileState{}, fmt.Errorf("offset %v is outside of the stream size %v", offset, c.size)
+	}
+
+	s := c.segments[i]
+	state = s.state
+	state.Position = offset - s.start
+	return s.name, state, nil
+}
+
+// Close closes all of the files, returning the first error.
+func (c *ConcatReader) Close() error {
+	var err error
+	for _, s := range c.segments {
+		if e := s.f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
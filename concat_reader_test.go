@@ -0,0 +1,56 @@
+package tail
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestConcatReader(t *testing.T) {
+
+	h := NewWatcherHarness(t, "concat-reader")
+
+	for i, s := range []string{"a\n", "bb\n", "ccc\n"} {
+		name := fmt.Sprintf("%s.%d", h.Path(), 3-i)
+		if err := ioutil.WriteFile(name, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Leave the live file missing, which happens right after rotating.
+	r, err := NewConcatReader(h.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if r.Size() != 9 {
+		t.Fatalf("expected size 9, got %v", r.Size())
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "a\nbb\nccc\n" {
+		t.Fatalf("unexpected stream contents %q", string(b))
+	}
+
+	if _, err := r.Seek(3, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	expectString(t, r, "b\ncc")
+
+	name, state, err := r.Locate(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != h.Path()+".1" || state.Position != 0 || state.Size != 4 {
+		t.Fatalf("unexpected location %v %+v", name, state)
+	}
+
+	if _, _, err := r.Locate(9); err == nil {
+		t.Fatal("expected an error locating the end of the stream")
+	}
+}
@@ -0,0 +1,111 @@
+//go:build linux
+// +build linux
+
+package tail
+
+import (
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// dirNotifyMask is watched on each directory to see files and
+// subdirectories being created, removed or renamed.
+const dirNotifyMask = unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE | unix.IN_ONLYDIR
+
+// dirNotifier sends to changed when an entry in one of the directories it
+// watches changes, using inotify.
+type dirNotifier struct {
+	changed chan struct{}
+
+	// f reads events from fd. Calling f.Fd would make it blocking.
+	f  *os.File
+	fd int
+
+	// dirs are the watches by directory, guarded by mu.
+	dirs map[string]int
+	mu   sync.Mutex
+}
+
+func newDirNotifier() (*dirNotifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &dirNotifier{
+		changed: make(chan struct{}, 1),
+		// A non-blocking descriptor uses the runtime poller, so close
+		// interrupts a Read in progress.
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		fd:   fd,
+		dirs: make(map[string]int),
+	}
+	go n.watch()
+	return n, nil
+}
+
+// set watches dirs and stops watching any others. A directory that can't
+// be watched is left to polling.
+func (n *dirNotifier) set(dirs []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	keep := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		keep[dir] = true
+		if _, ok := n.dirs[dir]; ok {
+			continue
+		}
+		if wd, err := unix.InotifyAddWatch(n.fd, dir, dirNotifyMask); err == nil {
+			n.dirs[dir] = wd
+		}
+	}
+
+	for dir, wd := range n.dirs {
+		if !keep[dir] {
+			unix.InotifyRmWatch(n.fd, uint32(wd))
+			delete(n.dirs, dir)
+		}
+	}
+}
+
+func (n *dirNotifier) watch() {
+	buf := make([]byte, 16*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		size, err := n.f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= size; {
+			e := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			off += unix.SizeofInotifyEvent + int(e.Len)
+
+			// The directory was removed, so its watch is already gone,
+			// and it's watched again if it's created again.
+			if e.Mask&unix.IN_IGNORED != 0 {
+				n.mu.Lock()
+				for dir, wd := range n.dirs {
+					if wd == int(e.Wd) {
+						delete(n.dirs, dir)
+					}
+				}
+				n.mu.Unlock()
+			}
+		}
+
+		// Any event means the directories should be listed again, which
+		// also finds what the events were about.
+		select {
+		case n.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *dirNotifier) close() error {
+	return n.f.Close()
+}
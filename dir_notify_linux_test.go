@@ -0,0 +1,50 @@
+//go:build linux
+// +build linux
+
+package tail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirWatcherNotify(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// Listing the directory every Interval would never find the file
+	// within the test.
+	w, err := NewDirWatcher(DirConfig{
+		Dir:       dir,
+		Recursive: true,
+		Notify:    true,
+		Interval:  time.Hour,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if w.notify == nil {
+		t.Fatal("expected inotify to be used")
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 50)
+
+	f, err := os.Create(filepath.Join(dir, "sub", "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	time.Sleep(time.Millisecond * 50)
+	writeString(t, f, "line\n")
+
+	if path, line := readDirLine(t, w); path != filepath.Join(dir, "sub", "app.log") || line != "line" {
+		t.Fatalf("expected line from sub/app.log, got %v from %v", line, path)
+	}
+}
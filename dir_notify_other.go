@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package tail
+
+import "errors"
+
+// dirNotifier is never used on this platform, since there are no
+// directory notifications.
+type dirNotifier struct {
+	changed chan struct{}
+}
+
+func newDirNotifier() (*dirNotifier, error) {
+	return nil, errors.New("directory notifications are only available on linux")
+}
+
+func (n *dirNotifier) set(dirs []string) {}
+
+func (n *dirNotifier) close() error {
+	return nil
+}
@@ -0,0 +1,559 @@
+package tail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirConfig configures a DirWatcher.
+type DirConfig struct {
+	// Dir is the directory to tail files in. Subdirectories are ignored
+	// unless Recursive is set.
+	Dir string
+
+	// Recursive also tails the files in every subdirectory of Dir,
+	// including ones created later. Symlinks to directories aren't
+	// followed.
+	Recursive bool
+
+	// Include and Exclude are filepath.Match patterns for the files to
+	// tail. A pattern without a / is matched against the name of the
+	// file, and one with a / against its path relative to Dir, where a
+	// ** matches any number of directories, like pods/**/*.log. A file
+	// is tailed if it matches any of Include, or Include is empty, and
+	// none of Exclude. Rotated files should be excluded, as they would
+	// be tailed from the start if they haven't been read yet.
+	Include []string
+	Exclude []string
+
+	// Interval is how often the directory is checked for new files, and
+	// how often each file is polled.
+	Interval time.Duration
+
+	// Notify checks the directories as soon as inotify reports that a
+	// file or subdirectory was created, removed or renamed in one, rather
+	// than waiting for the next Interval, which is still used as well.
+	// It's only available on linux, and elsewhere or if inotify can't be
+	// used, only Interval is. Each file is also followed with a Watcher
+	// from NewAutoWatcher.
+	Notify bool
+
+	// Whence applies to files that exist when the DirWatcher is created,
+	// and don't have a state in States. Files created later are always
+	// read from the start.
+	Whence int
+
+	// States is optional and resumes each file from the state for its
+	// path, such as from DirWatcher.States before a restart. A file
+	// without one resumes from a state with the same identity instead,
+	// in case it was renamed.
+	States map[string]FileState
+
+	// Scheduler is optional and polls every file with it, like
+	// Config.Scheduler, rather than with a goroutine for each.
+	Scheduler *Scheduler
+
+	// FileLimit is optional and caps how many of the files are open at
+	// once, like Config.FileLimit.
+	FileLimit *FileLimit
+}
+
+// DirWatcher tails every regular file in a directory with a LineReader,
+// including files created after it started, returning their lines in the
+// order they're read. Files that are removed are read to the end and then
+// stop being tailed, and their states are kept as long as a file with the
+// same identity is in the directory.
+type DirWatcher struct {
+	c     DirConfig
+	onErr ErrorHandler
+
+	lines chan dirLine
+	// cur is the line last returned by Next.
+	cur dirLine
+
+	// ctx is cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	err    error
+
+	// notify is set with DirConfig.Notify if inotify can be used.
+	notify *dirNotifier
+
+	// mu protects the fields below, which are used by the scanning
+	// goroutine and States.
+	mu     sync.Mutex
+	files  map[string]*dirFile
+	states map[string]FileState
+	// seen is the identity of every file read from, so a file that was
+	// renamed within the directory isn't tailed again under its new name.
+	seen   map[FileID]bool
+	closed bool
+}
+
+// dirFile is a file being tailed.
+type dirFile struct {
+	path string
+	r    *LineReader
+	ack  chan struct{}
+}
+
+type dirLine struct {
+	f     *dirFile
+	b     []byte
+	state FileState
+}
+
+// NewDirWatcher validates c, tails the files already in the directory,
+// and starts checking it for new ones every Interval until it's closed.
+// Errors from each LineReader and from listing the directory are passed to
+// h, and if it returns an error for the directory, the DirWatcher stops.
+func NewDirWatcher(c DirConfig, h ErrorHandler) (*DirWatcher, error) {
+	if c.Dir == "" {
+		return nil, errors.New("config value for dir cannot be empty")
+	}
+
+	if c.Interval < 0 {
+		return nil, errors.New("config value for interval cannot be negative")
+	} else if c.Interval == 0 {
+		c.Interval = time.Second
+	}
+
+	if !(c.Whence == io.SeekStart ||
+		c.Whence == io.SeekCurrent ||
+		c.Whence == io.SeekEnd) {
+		return nil, fmt.Errorf("config value for %w: %v", ErrBadWhence, c.Whence)
+	}
+
+	for _, pattern := range append(append([]string(nil), c.Include...), c.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("config value for pattern %q is invalid: %w", pattern, err)
+		}
+	}
+
+	if h == nil {
+		h = DiscardErrorHandler
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &DirWatcher{
+		c:      c,
+		onErr:  h,
+		lines:  make(chan dirLine),
+		ctx:    ctx,
+		cancel: cancel,
+		files:  make(map[string]*dirFile),
+		states: make(map[string]FileState),
+		seen:   make(map[FileID]bool),
+	}
+
+	if c.Notify {
+		// Errors are ignored, since polling still finds new files.
+		w.notify, _ = newDirNotifier()
+	}
+
+	if err := w.scan(c.Whence); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// dirNotifyDelay is how long to wait after a notification before listing
+// the directories, so a burst of changes is found by one scan.
+const dirNotifyDelay = time.Millisecond * 10
+
+func (w *DirWatcher) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.c.Interval)
+	defer ticker.Stop()
+
+	var changed chan struct{}
+	if w.notify != nil {
+		changed = w.notify.changed
+	}
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-changed:
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(dirNotifyDelay):
+			}
+		}
+
+		if err := w.scan(io.SeekStart); err != nil {
+			if err = w.onErr(err); err != nil {
+				w.mu.Lock()
+				w.err = err
+				w.mu.Unlock()
+				w.closeFiles()
+				return
+			}
+		}
+	}
+}
+
+// matches reports whether the file at rel, relative to Dir with /
+// separators, passes the Include and Exclude patterns.
+func (w *DirWatcher) matches(rel string) bool {
+	included := len(w.c.Include) == 0
+	for _, pattern := range w.c.Include {
+		if matchPattern(pattern, rel) {
+			included = true
+			break
+		}
+	}
+
+	if !included {
+		return false
+	}
+
+	for _, pattern := range w.c.Exclude {
+		if matchPattern(pattern, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPattern reports whether rel matches pattern, by its name if the
+// pattern has no /, or otherwise by each directory in it.
+func matchPattern(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := filepath.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments reports whether the elements of a path match those of a
+// pattern, where ** matches any number of them.
+func matchSegments(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchSegments(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// dirEntry is a regular file found by list, with its path relative to
+// Dir.
+type dirEntry struct {
+	path string
+	rel  string
+	info os.FileInfo
+}
+
+// list returns the regular files in Dir, and in its subdirectories with
+// Recursive, along with every directory listed. A subdirectory removed
+// while listing is skipped.
+func (w *DirWatcher) list() (files []dirEntry, dirs []string, err error) {
+	var walk func(dir, rel string) error
+	walk = func(dir, rel string) error {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, dir)
+
+		for _, info := range infos {
+			p := filepath.Join(dir, info.Name())
+			r := path.Join(rel, info.Name())
+
+			switch {
+			case info.Mode().IsRegular():
+				files = append(files, dirEntry{path: p, rel: r, info: info})
+			case info.IsDir() && w.c.Recursive:
+				if err := walk(p, r); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	err = walk(w.c.Dir, "")
+	return files, dirs, err
+}
+
+// scan lists the directory, starting a LineReader for each new file at
+// whence, and shutting down the ones for files that were removed.
+func (w *DirWatcher) scan(whence int) error {
+	entries, dirs, err := w.list()
+	if err != nil {
+		return err
+	}
+
+	if w.notify != nil {
+		w.notify.set(dirs)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	for _, f := range w.files {
+		if id := f.r.FileState().ID(); !id.IsZero() {
+			w.seen[id] = true
+		}
+	}
+
+	present := make(map[string]bool)
+	identities := make(map[FileID]bool)
+	for _, entry := range entries {
+		if !w.matches(entry.rel) {
+			continue
+		}
+
+		path := entry.path
+		present[path] = true
+
+		id, err := pathID(path, entry.info)
+		if err != nil {
+			continue
+		}
+		identities[id] = true
+
+		if _, ok := w.files[path]; ok || (!id.IsZero() && w.seen[id]) {
+			continue
+		}
+
+		if err := w.start(path, whence); err != nil {
+			return err
+		}
+	}
+
+	// Forget files that are gone, so a reused inode isn't mistaken
+	// for one that was already read.
+	for id := range w.seen {
+		if !identities[id] {
+			delete(w.seen, id)
+		}
+	}
+
+	for path, f := range w.files {
+		if present[path] {
+			continue
+		}
+
+		delete(w.files, path)
+		w.wg.Add(1)
+		go func(f *dirFile) {
+			defer w.wg.Done()
+			f.r.Shutdown(w.ctx)
+		}(f)
+	}
+
+	for path, state := range w.states {
+		if id := state.ID(); !present[path] && (id.IsZero() || !identities[id]) {
+			delete(w.states, path)
+		}
+	}
+	return nil
+}
+
+// start tails path with mu held.
+func (w *DirWatcher) start(path string, whence int) error {
+	c := Config{
+		Path:      path,
+		Interval:  w.c.Interval,
+		Whence:    whence,
+		Scheduler: w.c.Scheduler,
+		FileLimit: w.c.FileLimit,
+	}
+
+	if state, ok := w.startState(path); ok {
+		c.StartState = &state
+	}
+
+	var r *LineReader
+	if w.c.Notify {
+		fw, err := NewAutoWatcher(c)
+		if err != nil {
+			return err
+		}
+		r = NewLineReaderFromWatcher(fw, c, w.onErr)
+	} else {
+		var err error
+		if r, err = NewLineReader(c, w.onErr); err != nil {
+			return err
+		}
+	}
+
+	f := &dirFile{path: path, r: r, ack: make(chan struct{})}
+	w.files[path] = f
+
+	w.wg.Add(1)
+	go w.read(f)
+	return nil
+}
+
+// startState finds the state in DirConfig.States for path, or for the
+// file at path if it was renamed.
+func (w *DirWatcher) startState(path string) (FileState, bool) {
+	if state, ok := w.c.States[path]; ok {
+		return state, true
+	}
+
+	named, err := NewFileStateFromPath(path)
+	if err != nil || named.ID().IsZero() {
+		return FileState{}, false
+	}
+
+	for _, state := range w.c.States {
+		if state.ID().Equal(named.ID()) {
+			return state, true
+		}
+	}
+	return FileState{}, false
+}
+
+// read passes each line from f to Next, waiting for it to be consumed
+// before reading the next one.
+func (w *DirWatcher) read(f *dirFile) {
+	defer w.wg.Done()
+
+	for f.r.Next() {
+		select {
+		case w.lines <- dirLine{f: f, b: f.r.Bytes(), state: f.r.FileState()}:
+		case <-w.ctx.Done():
+			return
+		}
+
+		select {
+		case <-f.ack:
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// Next blocks until a line is available from any file and returns true,
+// or returns false once the DirWatcher is closed.
+func (w *DirWatcher) Next() bool {
+	if w.cur.f != nil {
+		select {
+		case w.cur.f.ack <- struct{}{}:
+		case <-w.ctx.Done():
+			return false
+		}
+		w.cur = dirLine{}
+	}
+
+	select {
+	case w.cur = <-w.lines:
+	case <-w.ctx.Done():
+		return false
+	}
+
+	w.mu.Lock()
+	w.states[w.cur.f.path] = w.cur.state
+	if id := w.cur.state.ID(); !id.IsZero() {
+		w.seen[id] = true
+	}
+	w.mu.Unlock()
+	return true
+}
+
+// Bytes returns the line last returned by Next, which is only valid
+// until Next is called again.
+func (w *DirWatcher) Bytes() []byte {
+	return w.cur.b
+}
+
+// Path returns the path of the file the last line returned by Next
+// was read from.
+func (w *DirWatcher) Path() string {
+	if w.cur.f == nil {
+		return ""
+	}
+	return w.cur.f.path
+}
+
+// States returns the state of each file as of the end of the last line
+// returned from it, which can be passed as DirConfig.States to resume.
+// It is safe to call in parallel to Next.
+func (w *DirWatcher) States() map[string]FileState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	states := make(map[string]FileState, len(w.states))
+	for path, state := range w.states {
+		states[path] = state
+	}
+	return states
+}
+
+// Err returns the error from listing the directory that stopped the
+// DirWatcher, if any.
+func (w *DirWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops tailing every file and waits for them to be closed.
+func (w *DirWatcher) Close() error {
+	err := w.closeFiles()
+	w.wg.Wait()
+	return err
+}
+
+// closeFiles closes every LineReader and stops Next.
+func (w *DirWatcher) closeFiles() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.cancel()
+
+	if w.notify != nil {
+		w.notify.close()
+	}
+
+	var err error
+	for _, f := range w.files {
+		if e := f.r.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package tail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readDirLine(t *testing.T, w *DirWatcher) (path, line string) {
+	t.Helper()
+
+	done := make(chan bool)
+	go func() {
+		done <- w.Next()
+	}()
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatal("Next() returned false when expecting more data")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for a line")
+	}
+	return w.Path(), string(w.Bytes())
+}
+
+func TestDirWatcher(t *testing.T) {
+
+	dir := t.TempDir()
+	write := func(name, s string) {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeString(t, f, s)
+		f.Close()
+	}
+
+	write("a.log", "a1\n")
+	write("skip.txt", "skipped\n")
+
+	c := DirConfig{
+		Dir:      dir,
+		Include:  []string{"*.log", "*.old"},
+		Interval: time.Millisecond * 10,
+	}
+
+	w, err := NewDirWatcher(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path, line := readDirLine(t, w); path != filepath.Join(dir, "a.log") || line != "a1" {
+		t.Fatalf("expected a1 from a.log, got %v from %v", line, path)
+	}
+
+	// Created after starting.
+	write("b.log", "b1\n")
+	if path, line := readDirLine(t, w); path != filepath.Join(dir, "b.log") || line != "b1" {
+		t.Fatalf("expected b1 from b.log, got %v from %v", line, path)
+	}
+
+	// Renamed to a name that matches, which shouldn't be read again.
+	if err := os.Rename(filepath.Join(dir, "a.log"), filepath.Join(dir, "a.old")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 50)
+	write("b.log", "b2\n")
+
+	if path, line := readDirLine(t, w); path != filepath.Join(dir, "b.log") || line != "b2" {
+		t.Fatalf("expected b2 from b.log, got %v from %v", line, path)
+	}
+
+	states := w.States()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if states[filepath.Join(dir, "b.log")].Position != 6 {
+		t.Fatalf("expected b.log at position 6, got %v", states)
+	}
+
+	// Resuming should only return what was written since.
+	write("b.log", "b3\n")
+	c.States = states
+	w, err = NewDirWatcher(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if path, line := readDirLine(t, w); path != filepath.Join(dir, "b.log") || line != "b3" {
+		t.Fatalf("expected b3 from b.log, got %v from %v", line, path)
+	}
+}
+
+func TestDirWatcherInvalidPattern(t *testing.T) {
+
+	_, err := NewDirWatcher(DirConfig{Dir: t.TempDir(), Include: []string{"["}}, nil)
+	if err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+}
+
+func TestDirWatcherRecursive(t *testing.T) {
+
+	dir := t.TempDir()
+	write := func(name, s string) {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeString(t, f, s)
+		f.Close()
+	}
+
+	write("pods/a/app.log", "a1\n")
+	write("pods/a/app.txt", "skipped\n")
+	write("top.log", "skipped\n")
+
+	w, err := NewDirWatcher(DirConfig{
+		Dir:       dir,
+		Recursive: true,
+		Include:   []string{"pods/**/*.log"},
+		Interval:  time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if path, line := readDirLine(t, w); path != filepath.Join(dir, "pods", "a", "app.log") || line != "a1" {
+		t.Fatalf("expected a1 from pods/a/app.log, got %v from %v", line, path)
+	}
+
+	// A subdirectory removed while tailing it shouldn't be an error.
+	if err := os.RemoveAll(filepath.Join(dir, "pods", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nested in directories created after starting.
+	write("pods/b/c/app.log", "c1\n")
+	if path, line := readDirLine(t, w); path != filepath.Join(dir, "pods", "b", "c", "app.log") || line != "c1" {
+		t.Fatalf("expected c1 from pods/b/c/app.log, got %v from %v", line, path)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		rel     string
+		match   bool
+	}{
+		{"*.log", "app.log", true},
+		{"*.log", "a/b/app.log", true},
+		{"*.log", "a/app.txt", false},
+		{"a/*.log", "a/app.log", true},
+		{"a/*.log", "a/b/app.log", false},
+		{"a/**/*.log", "a/app.log", true},
+		{"a/**/*.log", "a/b/c/app.log", true},
+		{"a/**/*.log", "b/app.log", false},
+		{"**/app.log", "app.log", true},
+		{"a/**", "a/b/app.log", true},
+	}
+
+	for _, test := range tests {
+		if match := matchPattern(test.pattern, test.rel); match != test.match {
+			t.Errorf("expected %q matching %q to be %v", test.pattern, test.rel, test.match)
+		}
+	}
+}
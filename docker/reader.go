@@ -0,0 +1,161 @@
+// Package docker reads container logs written by Docker's json-file log
+// driver, where each line is a JSON object like
+//
+//	{"log":"message\n","stream":"stdout","time":"2016-10-06T00:17:09.669794202Z"}
+//
+// and messages longer than 16KB are split across objects, with only the
+// last part ending in a newline.
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+// Streams a record can be from.
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// fingerprintBytes is how much of each file ContainerConfig fingerprints,
+// which covers the first object of a log.
+const fingerprintBytes = 1024
+
+// ContainerConfig returns a tail.Config for the log at path, such as
+// /var/lib/docker/containers/<id>/<id>-json.log. When resuming from a
+// StartState, it first reads the files Docker rotated the log to since,
+// path.1 and so on, including ones it compressed.
+func ContainerConfig(path string) tail.Config {
+	return tail.Config{
+		Path:             path,
+		FingerprintBytes: fingerprintBytes,
+		Backfill:         &tail.BackfillConfig{},
+	}
+}
+
+// Record is a message from a container.
+type Record struct {
+	// Time is when Docker received the message, or its last part if it
+	// was split.
+	Time time.Time
+	// Stream is Stdout or Stderr, or empty if the line wasn't a json-file
+	// object, in which case Message is the whole line.
+	Stream string
+	// Message is the message with its parts joined, without a trailing
+	// newline.
+	Message []byte
+}
+
+// entry is a line in a json-file log.
+type entry struct {
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+// Reader reads Records from a LineReader, joining the split parts of
+// each stream's messages back together.
+type Reader struct {
+	l   *tail.LineReader
+	max int
+
+	rec Record
+	err error
+
+	// pending is the parts of the message so far for each stream.
+	pending map[string][]byte
+	state   tail.FileState
+}
+
+// NewReader returns a Reader reading lines from l. If maxSize is greater
+// than 0 and a message grows larger than it, Next returns false and Err
+// returns tail.ErrRecordTooLarge.
+func NewReader(l *tail.LineReader, maxSize int) *Reader {
+	return &Reader{
+		l:       l,
+		max:     maxSize,
+		pending: make(map[string][]byte),
+		state:   l.FileState(),
+	}
+}
+
+// Next blocks until a complete message is available and returns true, or
+// returns false if the LineReader stopped or a message was too large.
+func (r *Reader) Next() bool {
+	for {
+		if r.err != nil || !r.l.Next() {
+			return false
+		}
+
+		var e entry
+		if err := json.Unmarshal(r.l.Bytes(), &e); err != nil || e.Stream == "" {
+			r.rec = Record{Message: append(r.rec.Message[:0], r.l.Bytes()...)}
+			r.updateState()
+			return true
+		}
+
+		msg := append(r.pending[e.Stream], e.Log...)
+		if r.max > 0 && len(msg) > r.max {
+			r.err = tail.ErrRecordTooLarge
+			return false
+		}
+
+		if !bytes.HasSuffix(msg, []byte{'\n'}) {
+			r.pending[e.Stream] = msg
+			continue
+		}
+
+		// The pending slice is kept to reuse for the next message.
+		r.pending[e.Stream] = msg[:0]
+		r.rec = Record{
+			Time:    e.Time,
+			Stream:  e.Stream,
+			Message: append(r.rec.Message[:0], bytes.TrimSuffix(msg, []byte{'\n'})...),
+		}
+		r.updateState()
+		return true
+	}
+}
+
+// updateState saves the LineReader's state unless a stream has a partial
+// message, which would be lost by resuming after it.
+func (r *Reader) updateState() {
+	for _, msg := range r.pending {
+		if len(msg) > 0 {
+			return
+		}
+	}
+	r.state = r.l.FileState()
+}
+
+// Record returns the last message, which is only valid until the next
+// call to Next.
+func (r *Reader) Record() Record {
+	return r.rec
+}
+
+// Err returns the error that caused Next to return false, either from
+// the LineReader or tail.ErrRecordTooLarge.
+func (r *Reader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.l.Err()
+}
+
+// FileState returns the state as of the end of the last message. While
+// another stream has part of a message read, it stays before that part,
+// so resuming from it can return messages from the other stream again
+// rather than losing part of one.
+func (r *Reader) FileState() tail.FileState {
+	return r.state
+}
+
+// Close closes the underlying LineReader.
+func (r *Reader) Close() error {
+	return r.l.Close()
+}
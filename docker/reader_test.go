@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+func TestReader(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "container-json.log")
+
+	lines := `{"log":"first\n","stream":"stdout","time":"2016-10-06T00:17:09.669794202Z"}` + "\n" +
+		`{"log":"sec","stream":"stdout","time":"2016-10-06T00:17:09.669794203Z"}` + "\n" +
+		`{"log":"oops\n","stream":"stderr","time":"2016-10-06T00:17:09.669794204Z"}` + "\n" +
+		`{"log":"ond\n","stream":"stdout","time":"2016-10-06T00:17:09.669794205Z"}` + "\n" +
+		"not json\n"
+	if err := ioutil.WriteFile(p, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := tail.NewLineReader(tail.Config{Path: p, Interval: time.Millisecond * 10, StopAtEOF: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(l, 0)
+	defer r.Close()
+
+	expected := []struct {
+		stream string
+		msg    string
+		pos    int64
+	}{
+		{Stdout, "first", 76},
+		// The partial stdout message isn't resumed after.
+		{Stderr, "oops", 76},
+		{Stdout, "second", 297},
+		{"", "not json", 306},
+	}
+
+	for _, e := range expected {
+		if !r.Next() {
+			t.Fatalf("expected %q, got %v", e.msg, r.Err())
+		}
+
+		rec := r.Record()
+		if rec.Stream != e.stream || string(rec.Message) != e.msg {
+			t.Fatalf("expected %v %q, got %v %q", e.stream, e.msg, rec.Stream, rec.Message)
+		}
+		if pos := r.FileState().Position; pos != e.pos {
+			t.Fatalf("expected position %v after %q, got %v", e.pos, e.msg, pos)
+		}
+	}
+
+	if rec := r.Record(); !rec.Time.IsZero() {
+		t.Fatalf("expected no time for a line that isn't json, got %v", rec.Time)
+	}
+}
+
+func TestReaderRotated(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "container-json.log")
+
+	first := `{"log":"a\n","stream":"stdout","time":"2016-10-06T00:17:09Z"}` + "\n"
+	second := `{"log":"b\n","stream":"stdout","time":"2016-10-06T00:17:10Z"}` + "\n"
+	if err := ioutil.WriteFile(p, []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ContainerConfig(p)
+	c.Interval = time.Millisecond * 10
+
+	l, err := tail.NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(l, 0)
+	if !r.Next() || string(r.Record().Message) != "a" {
+		t.Fatalf("expected a, got %q, %v", r.Record().Message, r.Err())
+	}
+	state := r.FileState()
+	r.Close()
+
+	// Docker rotates while nothing is reading.
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(second)
+	f.Close()
+	if err := os.Rename(p, p+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c.StartState = &state
+	if l, err = tail.NewLineReader(c, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r = NewReader(l, 0)
+	defer r.Close()
+
+	for _, msg := range []string{"b", "a"} {
+		if !r.Next() || string(r.Record().Message) != msg {
+			t.Fatalf("expected %v, got %q, %v", msg, r.Record().Message, r.Err())
+		}
+	}
+}
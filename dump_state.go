@@ -0,0 +1,141 @@
+package tail
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// historySize is how many recent events and errors a LineReader keeps
+// for DumpState.
+const historySize = 10
+
+// history is a ring of the most recent entries, up to max or historySize
+// if it's 0.
+type history struct {
+	entries []historyEntry
+	next    int
+	max     int
+}
+
+type historyEntry struct {
+	at  time.Time
+	msg string
+}
+
+func (h *history) add(at time.Time, msg string) {
+	max := h.max
+	if max == 0 {
+		max = historySize
+	}
+
+	e := historyEntry{at: at, msg: msg}
+	if len(h.entries) < max {
+		h.entries = append(h.entries, e)
+		return
+	}
+
+	h.entries[h.next] = e
+	h.next = (h.next + 1) % max
+}
+
+// list returns the entries oldest first.
+func (h *history) list() []historyEntry {
+	return append(append([]historyEntry(nil), h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+func (s FileState) String() string {
+	modTime := "unknown"
+	if !s.ModTime.IsZero() {
+		modTime = s.ModTime.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("inode=%v device=%v size=%v position=%v modtime=%v",
+		s.Inode, s.Device, s.Size, s.Position, modTime)
+}
+
+func (s WaitStatus) String() string {
+	var b strings.Builder
+	if s.File != nil {
+		fmt.Fprintf(&b, "file=%q ", s.File.Name())
+	}
+	fmt.Fprintf(&b, "%v event=%q reopened=%v", s.State, s.Event, s.ReOpened)
+	if s.RotatedName != "" {
+		fmt.Fprintf(&b, " rotated=%q", s.RotatedName)
+	}
+	if s.Truncated {
+		fmt.Fprintf(&b, " truncated=true")
+	}
+	return b.String()
+}
+
+// recordEvent adds a message to the events shown by DumpState.
+func (l *LineReader) recordEvent(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events.add(l.c.clock().Now(), fmt.Sprintf(format, args...))
+}
+
+// recordError adds err to the errors shown by DumpState.
+func (l *LineReader) recordError(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs.add(l.c.clock().Now(), err.Error())
+}
+
+// DumpState writes a human readable description of the reader to w,
+// meant to be included in bug reports. It has the path, the state of the
+// last line returned, how far behind the file at the path it is, how many
+// bytes are read but not returned yet, and the last few events and
+// errors. It is safe to call in parallel to Next.
+func (l *LineReader) DumpState(w io.Writer) error {
+	l.mu.Lock()
+	state := l.read
+	buffered := l.buffered
+	offset := l.offset
+	partial := l.partial
+	events := l.events.list()
+	errs := l.errs.list()
+	l.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "path: %v\n", l.c.Path)
+	fmt.Fprintf(&b, "state: %v\n", state)
+	fmt.Fprintf(&b, "stream offset: %v\n", offset)
+	fmt.Fprintf(&b, "buffered: %v bytes (partial line: %v)\n", buffered, partial)
+
+	named, err := NewFileStateFromPath(l.c.Path)
+	switch {
+	case os.IsNotExist(err):
+		fmt.Fprintf(&b, "lag: path doesn't exist\n")
+	case err != nil:
+		fmt.Fprintf(&b, "lag: %v\n", err)
+	case l.c.SameFile(state, *named):
+		fmt.Fprintf(&b, "lag: %v bytes\n", named.Size-state.Position)
+	default:
+		fmt.Fprintf(&b, "lag: rotated, %v bytes in the newest file\n", named.Size)
+	}
+
+	fmt.Fprintf(&b, "growth rate: %.1f bytes/s\n", l.GrowthRate())
+
+	dumpHistory(&b, "events", events)
+	dumpHistory(&b, "errors", errs)
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func (e historyEntry) String() string {
+	return fmt.Sprintf("%v %v", e.at.Format(time.RFC3339Nano), e.msg)
+}
+
+func dumpHistory(b *strings.Builder, name string, entries []historyEntry) {
+	fmt.Fprintf(b, "%v:\n", name)
+	if len(entries) == 0 {
+		fmt.Fprintf(b, "  none\n")
+	}
+	for _, e := range entries {
+		fmt.Fprintf(b, "  %v\n", e)
+	}
+}
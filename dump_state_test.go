@@ -0,0 +1,101 @@
+package tail
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineReaderDumpState(t *testing.T) {
+
+	h := NewWatcherHarness(t, "dump-state")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "one\ntwo\nthree\n")
+	writer.Close()
+
+	readLine(t, r, "one")
+
+	var b strings.Builder
+	if err := r.DumpState(&b); err != nil {
+		t.Fatal(err)
+	}
+	dump := b.String()
+
+	for _, expect := range []string{
+		"path: " + h.Path() + "\n",
+		"position=4 ",
+		"stream offset: 4\n",
+		"buffered: 10 bytes",
+		"lag: 10 bytes\n",
+		"opened file=\"" + h.Path() + "\"",
+		"errors:\n  none\n",
+	} {
+		if !strings.Contains(dump, expect) {
+			t.Fatalf("expected dump to contain %q, got:\n%v", expect, dump)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+
+	var h history
+	now := time.Now()
+	for i := 0; i < historySize+3; i++ {
+		h.add(now, string(rune('a'+i)))
+	}
+
+	entries := h.list()
+	if len(entries) != historySize {
+		t.Fatalf("expected %v entries, got %v", historySize, len(entries))
+	}
+
+	if entries[0].msg != "d" || entries[historySize-1].msg != "m" {
+		t.Fatalf("expected entries d through m, got %v through %v", entries[0].msg, entries[historySize-1].msg)
+	}
+}
+
+func TestLineReaderDumpStateClock(t *testing.T) {
+
+	h := NewWatcherHarness(t, "dump-state-clock")
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		Clock:    NewManualClock(at),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	r.recordEvent("event")
+	r.recordError(errors.New("failure"))
+
+	var b strings.Builder
+	if err := r.DumpState(&b); err != nil {
+		t.Fatal(err)
+	}
+	dump := b.String()
+
+	// Both are timed with the configured clock.
+	for _, expect := range []string{
+		at.Format(time.RFC3339Nano) + " event\n",
+		at.Format(time.RFC3339Nano) + " failure\n",
+	} {
+		if !strings.Contains(dump, expect) {
+			t.Fatalf("expected dump to contain %q, got:\n%v", expect, dump)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package tail
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// AutoEncoding is a Config.Encoding that detects the encoding of each
+// file from its first few kilobytes when it's opened, choosing UTF-16 if
+// it starts with a byte order mark or every other byte is NUL, UTF-8 if
+// it's valid, and Latin-1 otherwise. A file that's empty when opened is
+// detected once it has something in it. TailLines still counts lines as
+// if files were UTF-8.
+var AutoEncoding encoding.Encoding = &autoEncoding{unicode.UTF8}
+
+// autoEncoding is UTF-8 until a file's encoding is detected.
+type autoEncoding struct {
+	encoding.Encoding
+}
+
+// sniffBytes is how much of the start of a file AutoEncoding detects
+// the encoding from.
+const sniffBytes = 4096
+
+// detectEncoding guesses the encoding of b, the start of a file.
+func detectEncoding(b []byte) encoding.Encoding {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xff, 0xfe}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	}
+
+	// Text that's mostly ASCII has a NUL in the high byte of each code
+	// unit in UTF-16, and almost never one otherwise.
+	var even, odd int
+	for i, c := range b {
+		if c != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	units := len(b) / 2
+	switch {
+	case units > 0 && odd > units/2 && even == 0:
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case units > 0 && even > units/2 && odd == 0:
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	}
+
+	// The last character may be cut off where b ends.
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				b = b[:i]
+			}
+			break
+		}
+	}
+	if utf8.Valid(b) {
+		return unicode.UTF8
+	}
+	return charmap.ISO8859_1
+}
+
+// textEncoding is how text is written in files with Config.Encoding.
+type textEncoding struct {
+	enc encoding.Encoding
+	// delim is the Delimiter as it's written in the file.
+	delim []byte
+	// bom is the byte order mark a file can start with, if the
+	// encoding has one.
+	bom []byte
+	// unit is the size of a code unit, so a delimiter is only found at
+	// the start of one.
+	unit int
+}
+
+// newTextEncoding returns how delim and the rest of the text is written
+// with enc.
+func newTextEncoding(enc encoding.Encoding, delim []byte) *textEncoding {
+	t := &textEncoding{enc: enc, delim: delim, unit: 1}
+
+	// Encoders for encodings that use a byte order mark write it first,
+	// so it's found by encoding twice as much.
+	once, err := encodeText(enc, delim)
+	if err != nil {
+		return t
+	}
+	twice, err := encodeText(enc, append(append([]byte(nil), delim...), delim...))
+	if err != nil || len(twice) < len(once) {
+		return t
+	}
+	t.delim = twice[len(once):]
+	prefix := once[:len(once)-len(t.delim)]
+
+	if unit, err := encodeText(enc, []byte{'a'}); err == nil && len(unit) > len(prefix) {
+		t.unit = len(unit) - len(prefix)
+	}
+
+	if bom, err := encodeText(enc, []byte("\ufeff")); err == nil && bytes.HasPrefix(bom, prefix) && len(bom) > len(prefix) {
+		t.bom = bom[len(prefix):]
+	}
+	return t
+}
+
+func encodeText(enc encoding.Encoding, b []byte) ([]byte, error) {
+	out, _, err := transform.Bytes(enc.NewEncoder(), b)
+	return out, err
+}
+
+// aligned reports whether a line of n bytes ends at the end of a code
+// unit, so the delimiter it ends with isn't part of other characters.
+func (t *textEncoding) aligned(n int) bool {
+	return n%t.unit == 0
+}
+
+// decode returns line decoded to UTF-8, without the byte order mark if
+// it's at the start of the file. If it can't be decoded, it's returned
+// as is.
+func (t *textEncoding) decode(line []byte, start bool) []byte {
+	if start {
+		line = bytes.TrimPrefix(line, t.bom)
+	}
+
+	text, _, err := transform.Bytes(t.enc.NewDecoder(), line)
+	if err != nil {
+		return line
+	}
+	return text
+}
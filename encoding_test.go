@@ -0,0 +1,127 @@
+package tail
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestLineReaderEncoding(t *testing.T) {
+
+	utf16 := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+
+	tests := []struct {
+		name  string
+		enc   encoding.Encoding
+		raw   string
+		lines []string
+		// end is the position after the last line.
+		end int64
+	}{
+		{
+			name: "utf16",
+			enc:  utf16,
+			// A byte order mark, then a\r\n, then U+0A41 U+0100 which
+			// together contain the bytes of \n in the wrong place.
+			raw:   "\xff\xfea\x00\r\x00\n\x00\x41\x0a\x00\x01\n\x00",
+			lines: []string{"a", "ੁĀ"},
+			end:   14,
+		},
+		{
+			name:  "latin1",
+			enc:   charmap.ISO8859_1,
+			raw:   "caf\xe9\nna\xefve\n",
+			lines: []string{"café", "naïve"},
+			end:   11,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewWatcherHarness(t, "line-reader-encoding-test")
+			writer := h.Create()
+			defer writer.Close()
+			writeString(t, writer, test.raw)
+
+			r, err := NewLineReader(Config{
+				Path:     h.Path(),
+				Interval: time.Millisecond * 10,
+				Encoding: test.enc,
+			}, func(e error) error {
+				t.Fatal(e)
+				return e
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			for _, line := range test.lines {
+				readLine(t, r, line)
+			}
+
+			if pos := r.FileState().Position; pos != test.end {
+				t.Fatalf("expected position %v, got %v", test.end, pos)
+			}
+		})
+	}
+}
+
+func TestLineReaderAutoEncoding(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-auto-encoding-test")
+	writer := h.Create()
+	writeString(t, writer, "\xff\xfea\x00\r\x00\n\x00")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		Encoding: AutoEncoding,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "a")
+
+	// The next file is empty when it's opened, so it's detected once
+	// there's something in it.
+	h.Rotate()
+	writer.Close()
+	writer = h.Create()
+	defer writer.Close()
+	time.Sleep(time.Millisecond * 50)
+	writeString(t, writer, "caf\xe9\n")
+
+	readLine(t, r, "café")
+}
+
+func TestDetectEncoding(t *testing.T) {
+
+	tests := []struct {
+		name string
+		b    string
+		enc  encoding.Encoding
+	}{
+		{"utf16le bom", "\xff\xfea\x00", unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+		{"utf16be bom", "\xfe\xff\x00a", unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+		{"utf16le", "a\x00b\x00\n\x00", unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+		{"utf16be", "\x00a\x00b\x00\n", unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+		{"utf8", "café\n", unicode.UTF8},
+		{"utf8 cut off", "caf\xc3", unicode.UTF8},
+		{"latin1", "caf\xe9\n", charmap.ISO8859_1},
+	}
+
+	for _, test := range tests {
+		if enc := detectEncoding([]byte(test.b)); enc != test.enc {
+			t.Errorf("%v: expected %v, got %v", test.name, test.enc, enc)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ErrTimeout is returned by LineReader.NextTimeout when no complete
+// line was available in time.
+var ErrTimeout = errors.New("timed out waiting for a line")
+
+// ErrStopped is returned by LineReader.Err once one of the stop
+// conditions in Config, like StopAfterLines, was reached.
+var ErrStopped = errors.New("stop condition was reached")
+
+// ErrUnreadable is matched by errors.Is when the file at the configured
+// path exists but couldn't be opened because of its permissions, as
+// opposed to not existing at all which is silently retried.
+var ErrUnreadable = errors.New("file is not readable")
+
+// UnreadableError is returned by a Watcher when the named file exists
+// but opening it was denied, usually because its mode was changed. The
+// polling Watcher also returns it once when the mode of the open file
+// changes so that it can't be opened again, though reading what's already
+// open continues.
+type UnreadableError struct {
+	Path string
+	// Mode is the mode of the file at the time of the failed open,
+	// or 0 if it couldn't be determined.
+	Mode os.FileMode
+	Err  error
+}
+
+func (e *UnreadableError) Error() string {
+	return fmt.Sprintf("file %s with mode %v is not readable: %v", e.Path, e.Mode, e.Err)
+}
+
+func (e *UnreadableError) Unwrap() error {
+	return e.Err
+}
+
+// Is allows errors.Is(err, ErrUnreadable) to match.
+func (e *UnreadableError) Is(target error) bool {
+	return target == ErrUnreadable
+}
+
+// ErrTruncated is matched by errors.Is when the open file was truncated
+// and Config.Truncation is TruncateError.
+var ErrTruncated = errors.New("file was truncated")
+
+// TruncatedError is returned by a Watcher when the open file got smaller
+// than the position it was read up to.
+type TruncatedError struct {
+	Path string
+	// Size is the size of the file after it was truncated, and
+	// Position is where it had been read up to.
+	Size     int64
+	Position int64
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("file %s was truncated to %v bytes after reading %v", e.Path, e.Size, e.Position)
+}
+
+// Is allows errors.Is(err, ErrTruncated) to match.
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
+
+// ErrStartMismatch is matched by errors.Is when Config.StartState doesn't
+// match the file at Path and Config.StartMismatch is MismatchError.
+var ErrStartMismatch = errors.New("start state doesn't match the file")
+
+// StartMismatchError is returned by a Watcher when Config.StartState
+// doesn't match the file at Path.
+type StartMismatchError struct {
+	Path string
+	// Saved is the StartState, and Found is the state of the file.
+	Saved FileState
+	Found FileState
+}
+
+func (e *StartMismatchError) Error() string {
+	return fmt.Sprintf("file %s doesn't match the state it was read up to %v in", e.Path, e.Saved.Position)
+}
+
+// Is allows errors.Is(err, ErrStartMismatch) to match.
+func (e *StartMismatchError) Is(target error) bool {
+	return target == ErrStartMismatch
+}
+
+// ErrLineTooLong is matched by errors.Is when a line is longer than
+// Config.MaxLineBytes and Config.LongLines is LongLineError.
+var ErrLineTooLong = errors.New("line is too long")
+
+// LineTooLongError is passed to the ErrorHandler by a LineReader when a
+// line is longer than the maximum.
+type LineTooLongError struct {
+	Path string
+	// Position is where the line starts in the file.
+	Position int64
+	Max      int
+}
+
+func (e *LineTooLongError) Error() string {
+	return fmt.Sprintf("line at %v in file %s is longer than %v bytes", e.Position, e.Path, e.Max)
+}
+
+// Is allows errors.Is(err, ErrLineTooLong) to match.
+func (e *LineTooLongError) Is(target error) bool {
+	return target == ErrLineTooLong
+}
+
+// ErrMalformedJSON is matched by errors.Is when a line read by
+// LineReader.NextJSON isn't valid JSON and Config.MalformedJSON is
+// MalformedError.
+var ErrMalformedJSON = errors.New("line is malformed json")
+
+// MalformedJSONError is passed to the ErrorHandler by NextJSON when a line
+// couldn't be decoded.
+type MalformedJSONError struct {
+	Path string
+	// Position is where the line starts in the file.
+	Position int64
+	Err      error
+}
+
+func (e *MalformedJSONError) Error() string {
+	return fmt.Sprintf("line at %v in file %s is malformed json: %v", e.Position, e.Path, e.Err)
+}
+
+// Is allows errors.Is(err, ErrMalformedJSON) to match.
+func (e *MalformedJSONError) Is(target error) bool {
+	return target == ErrMalformedJSON
+}
+
+func (e *MalformedJSONError) Unwrap() error {
+	return e.Err
+}
+
+// ErrBadWhence is matched by errors.Is when a whence value isn't one of
+// the Seek constants from the io package, or isn't supported where it's
+// used.
+var ErrBadWhence = errors.New("whence is invalid")
+
+// ErrFileRemoved is matched by errors.Is when a file that was read from
+// before can't be found anymore, even by its identity after a rotation.
+var ErrFileRemoved = errors.New("file was removed")
+
+// FileRemovedError is returned by OpenSkipped when the file of the
+// skipped range was removed. Along with ErrFileRemoved, it matches
+// os.ErrNotExist.
+type FileRemovedError struct {
+	Path string
+	// State identifies the file that was removed.
+	State FileState
+}
+
+func (e *FileRemovedError) Error() string {
+	return fmt.Sprintf("file %s with identity %v was removed", e.Path, e.State.ID())
+}
+
+// Is allows errors.Is(err, ErrFileRemoved) and os.ErrNotExist to match.
+func (e *FileRemovedError) Is(target error) bool {
+	return target == ErrFileRemoved || target == os.ErrNotExist
+}
+
+// ErrFileNotFound is matched by errors.Is when Config.MustExist is set and
+// the file didn't exist in time.
+var ErrFileNotFound = errors.New("file was not found")
+
+// FileNotFoundError is returned by the polling Watcher's Wait with
+// Config.MustExist when no file was found at the path within
+// MustExistTimeout. Along with ErrFileNotFound, it matches os.ErrNotExist.
+type FileNotFoundError struct {
+	Path string
+	// Waited is how long it was waited for.
+	Waited time.Duration
+}
+
+func (e *FileNotFoundError) Error() string {
+	return fmt.Sprintf("file %s was not found after %v", e.Path, e.Waited.Round(time.Millisecond))
+}
+
+// Is allows errors.Is(err, ErrFileNotFound) and os.ErrNotExist to match.
+func (e *FileNotFoundError) Is(target error) bool {
+	return target == ErrFileNotFound || target == os.ErrNotExist
+}
+
+// ErrorEvent is passed to Config.OnErrorEvent with the context of an error
+// from a LineReader.
+type ErrorEvent struct {
+	// Op is what failed: open, stat, seek or read for the file, wait for
+	// other errors from the Watcher, checkpoint for saving the state, or
+	// decode for a line NextJSON couldn't decode.
+	Op string
+	// Path is the configured path.
+	Path string
+	// Retries is how many errors in a row came from the same Op before
+	// this one. It resets once the Op succeeds, which for the file is
+	// when a line is read or the Watcher waits successfully.
+	Retries int
+	Err     error
+}
+
+func (e ErrorEvent) Error() string {
+	return fmt.Sprintf("%s %s (retry %v): %v", e.Op, e.Path, e.Retries, e.Err)
+}
+
+func (e ErrorEvent) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient reports whether err is likely to go away on its own, so the
+// operation is worth retrying. This includes the file being missing or
+// unreadable (it may be in the middle of being rotated or have its mode
+// fixed), stale NFS handles, interrupted system calls, and short reads.
+// Errors that provide a Temporary method are also transient if it
+// returns true. Everything else is considered terminal.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if os.IsNotExist(err) || os.IsPermission(err) || errors.Is(err, ErrUnreadable) {
+		return true
+	}
+
+	for _, target := range []error{
+		syscall.ESTALE,
+		syscall.EINTR,
+		syscall.EAGAIN,
+		io.ErrUnexpectedEOF,
+		io.ErrNoProgress,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// RetryTransient is an ErrorHandler that retries transient errors as
+// classified by IsTransient and returns terminal errors, which stops a
+// LineReader. It doesn't wait itself, so it's safe to share between
+// readers. Set Config.Backoff, such as to ExponentialBackoff, for how long
+// each reader waits before retrying, which counts its own failures and is
+// interrupted by closing it.
+func RetryTransient(err error) error {
+	if !IsTransient(err) {
+		return err
+	}
+	return nil
+}
+
+// ErrRecordTooLarge is returned by record readers when a record grows
+// beyond the configured maximum size before it's complete.
+var ErrRecordTooLarge = errors.New("record exceeds the maximum size")
+
+// ErrLeaseLost is returned by FileCheckpointStore.Save once another owner
+// has taken the lease it acquired, so a Standby that took over isn't
+// overwritten.
+var ErrLeaseLost = errors.New("lease is held by another owner")
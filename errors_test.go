@@ -0,0 +1,132 @@
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{nil, false},
+		{&os.PathError{Op: "open", Path: "x", Err: syscall.ENOENT}, true},
+		{&os.PathError{Op: "open", Path: "x", Err: syscall.EACCES}, true},
+		{&UnreadableError{Path: "x", Err: os.ErrPermission}, true},
+		{fmt.Errorf("stat: %w", syscall.ESTALE), true},
+		{&os.SyscallError{Syscall: "read", Err: syscall.EINTR}, true},
+		{io.ErrUnexpectedEOF, true},
+		{&os.PathError{Op: "read", Path: "x", Err: syscall.EISDIR}, false},
+		{errors.New("something else"), false},
+	}
+
+	for _, test := range tests {
+		if actual := IsTransient(test.err); actual != test.expected {
+			t.Errorf("IsTransient(%v): expected %v, got %v", test.err, test.expected, actual)
+		}
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+
+	var h ErrorHandler = RetryTransient
+
+	if err := h(io.ErrUnexpectedEOF); err != nil {
+		t.Fatalf("expected transient error to be retried, got %v", err)
+	}
+
+	terminal := errors.New("terminal")
+	if err := h(terminal); err != terminal {
+		t.Fatalf("expected terminal error to be returned, got %v", err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+
+	_, err := NewPollingWatcher(Config{Path: "x", Whence: 7})
+	if !errors.Is(err, ErrBadWhence) {
+		t.Errorf("expected ErrBadWhence for an invalid whence, got %v", err)
+	}
+
+	c := &ConcatReader{}
+	if _, err = c.Seek(0, 7); !errors.Is(err, ErrBadWhence) {
+		t.Errorf("expected ErrBadWhence from seeking, got %v", err)
+	}
+
+	h := NewWatcherHarness(t, "typed-errors-test")
+	writer := h.Create()
+	writer.Close()
+
+	state, err := NewFileStateFromPath(h.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(h.Path())
+
+	_, err = OpenSkipped(SkippedRange{Path: h.Path(), State: *state})
+	if !errors.Is(err, ErrFileRemoved) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected ErrFileRemoved for a removed file, got %v", err)
+	}
+
+	var removed *FileRemovedError
+	if !errors.As(err, &removed) || removed.Path != h.Path() {
+		t.Errorf("expected a FileRemovedError for %v, got %v", h.Path(), err)
+	}
+}
+
+type unreadableWatcher struct{}
+
+func (unreadableWatcher) Wait() (WaitStatus, bool, error) {
+	return WaitStatus{}, false, &UnreadableError{
+		Path: "x",
+		Err:  &os.PathError{Op: "open", Path: "x", Err: os.ErrPermission},
+	}
+}
+
+func (unreadableWatcher) Close() error {
+	return nil
+}
+
+func TestLineReaderOnErrorEvent(t *testing.T) {
+
+	var events []ErrorEvent
+	giveUp := errors.New("give up")
+
+	r := NewLineReaderFromWatcher(unreadableWatcher{}, Config{
+		Path:               "x",
+		UnreadableInterval: time.Millisecond,
+		OnErrorEvent: func(e ErrorEvent) error {
+			events = append(events, e)
+			if e.Op == "open" && e.Retries == 2 {
+				return giveUp
+			}
+			return nil
+		},
+	}, func(e error) error {
+		t.Fatal("expected OnErrorEvent to be used instead")
+		return e
+	})
+
+	if r.Next() {
+		t.Fatal("expected Next to return false")
+	}
+	if r.Err() != giveUp {
+		t.Fatalf("expected the error from OnErrorEvent, got %v", r.Err())
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %v", len(events))
+	}
+	for i, e := range events {
+		if e.Op != "open" || e.Path != "x" || e.Retries != i || !errors.Is(e, ErrUnreadable) {
+			t.Errorf("unexpected event %v: %v", i, e)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+//go:build solaris
+// +build solaris
+
+package tail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// eventPortDirEvents is watched on the directory, which is modified
+	// when the path is created, replaced or removed.
+	eventPortDirEvents = unix.FILE_MODIFIED
+
+	// eventPortFileEvents is watched on the file at the path. Events for
+	// it being deleted, renamed or unmounted are always reported.
+	eventPortFileEvents = unix.FILE_MODIFIED | unix.FILE_ATTRIB | unix.FILE_TRUNC
+)
+
+// eventPortWatcher is a pollWatcher that polls as soon as an event port
+// reports a change instead of waiting for the next Interval.
+type eventPortWatcher struct {
+	*pollWatcher
+
+	port *unix.EventPort
+	dir  string
+
+	// stop is a pipe whose read end is associated with the port, so
+	// closing the write end interrupts waiting for events. done is
+	// closed once watch has released the port and the pipe. stop is
+	// guarded by mu.
+	stop [2]int
+	done chan struct{}
+	mu   sync.Mutex
+}
+
+// NewInotifyWatcher configures a Watcher like NewPollingWatcher, but uses
+// an event port, the closest to inotify on Solaris and illumos, to check
+// for more data as soon as the file at the path is written to, renamed or
+// removed, or the path is created. Associations are by name, so writes to
+// a file after it's rotated away are only found by polling. Interval is
+// still used as a fallback, such as for PartialLineGrace. If an event port
+// can't be used, it falls back to only polling.
+func NewInotifyWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+	return startEventPort(p), nil
+}
+
+// NewAutoWatcher is like NewInotifyWatcher, but only polls if the path is
+// on a filesystem where event ports don't see every change, such as
+// writes from other machines to NFS or SMB shares. Its NotifyStatus method
+// reports which was chosen.
+func NewAutoWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason := unreliableNotify(filepath.Dir(c.Path)); reason != "" {
+		p.notify.Reason = reason
+		p.start()
+		return p, nil
+	}
+	return startEventPort(p), nil
+}
+
+// startEventPort starts polling with p, using an event port if it can.
+func startEventPort(p *pollWatcher) Watcher {
+	w, err := newEventPortWatcher(p)
+	if err != nil {
+		p.notify.Reason = fmt.Sprintf("event port failed: %v", err)
+		p.start()
+		return p
+	}
+
+	p.notify.Notify = true
+	go w.watch()
+	p.start()
+	return w
+}
+
+// unreliableNotify returns why event ports can't be relied on in dir, or
+// an empty string if they can. They're assumed to work if the filesystem
+// can't be checked.
+func unreliableNotify(dir string) string {
+	var fs unix.Statvfs_t
+	if err := unix.Statvfs(dir, &fs); err != nil {
+		return ""
+	}
+
+	name := make([]byte, 0, len(fs.Basetype))
+	for _, c := range fs.Basetype {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+
+	switch string(name) {
+	case "nfs":
+		return "nfs filesystem"
+	case "smbfs":
+		return "smb filesystem"
+	}
+	return ""
+}
+
+func newEventPortWatcher(p *pollWatcher) (*eventPortWatcher, error) {
+	port, err := unix.NewEventPort()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &eventPortWatcher{
+		pollWatcher: p,
+		port:        port,
+		dir:         filepath.Dir(p.c.Path),
+		stop:        [2]int{-1, -1},
+		done:        make(chan struct{}),
+	}
+
+	if err = unix.Pipe(w.stop[:]); err != nil {
+		w.release()
+		return nil, err
+	}
+	unix.CloseOnExec(w.stop[0])
+	unix.CloseOnExec(w.stop[1])
+
+	if err = port.AssociateFd(uintptr(w.stop[0]), unix.POLLIN, nil); err != nil {
+		w.release()
+		return nil, err
+	}
+
+	if err = w.associate(w.dir, eventPortDirEvents); err != nil {
+		w.release()
+		return nil, err
+	}
+
+	p.wake = make(chan struct{}, 1)
+	w.associate(w.c.Path, eventPortFileEvents)
+	return w, nil
+}
+
+// associate watches the file named name for events. Associations only
+// report one event, so it's done again after each. Changes made since
+// name was stat'd are reported straight away, so none are missed.
+func (w *eventPortWatcher) associate(name string, events int) error {
+	// Errors are ignored for the path, since the file may not exist yet
+	// and polling will still notice changes.
+	stat, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return w.port.AssociatePath(name, stat, events, nil)
+}
+
+func (w *eventPortWatcher) watch() {
+	defer close(w.done)
+	defer w.release()
+
+	events := make([]unix.PortEvent, 16)
+	for {
+		n, err := w.port.Get(events, 1, nil)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return
+		}
+
+		for _, e := range events[:n] {
+			switch {
+			case e.Source == unix.PORT_SOURCE_FD:
+				return
+			case e.Path == w.dir:
+				w.associate(w.dir, eventPortDirEvents)
+			}
+		}
+
+		// The path may name a different file now, or the same one was
+		// changed, and either way its association may be used up.
+		if !w.port.PathIsWatched(w.c.Path) {
+			w.associate(w.c.Path, eventPortFileEvents)
+		}
+
+		if n > 0 {
+			select {
+			case w.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// release closes the event port and the pipe.
+func (w *eventPortWatcher) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.port.Close()
+	for _, fd := range w.stop {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+	}
+	w.stop = [2]int{-1, -1}
+}
+
+func (w *eventPortWatcher) Close() error {
+	err := w.pollWatcher.Close()
+
+	// Closing the write end makes the read end readable, which stops
+	// watch, and it's the only one using the port by now.
+	w.mu.Lock()
+	if w.stop[1] >= 0 {
+		unix.Close(w.stop[1])
+		w.stop[1] = -1
+	}
+	w.mu.Unlock()
+
+	<-w.done
+	return err
+}
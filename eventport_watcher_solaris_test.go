@@ -0,0 +1,89 @@
+//go:build solaris
+// +build solaris
+
+package tail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventPortWatcher(t *testing.T) {
+
+	h := NewWatcherHarness(t, "eventport")
+
+	// Polling would never notice anything within the test.
+	r, err := NewInotifyWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, ok := r.(*eventPortWatcher); !ok {
+		t.Skip("event ports aren't available")
+	}
+
+	// A missed change would otherwise leave the harness waiting forever.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		writer := h.Create()
+		writeString(t, writer, "foo")
+
+		reader := h.Wait(r, true, false, nil)
+		expectString(t, reader, "foo")
+
+		writeString(t, writer, "bar")
+		h.Wait(r, false, false, nil)
+		expectString(t, reader, "bar")
+		writer.Close()
+
+		h.Rotate()
+		writer = h.Create()
+		writeString(t, writer, "baz")
+		writer.Close()
+
+		reader = h.Wait(r, true, false, nil)
+		expectString(t, reader, "baz")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("event port watcher didn't notice a change")
+	}
+}
+
+func TestEventPortAutoWatcher(t *testing.T) {
+
+	h := NewWatcherHarness(t, "eventport-auto")
+
+	r, err := NewAutoWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	status := r.(interface{ NotifyStatus() NotifyStatus }).NotifyStatus()
+	_, eventport := r.(*eventPortWatcher)
+	if status.Notify != eventport {
+		t.Fatalf("expected notify to be %v, got %+v", eventport, status)
+	}
+	if !status.Notify && status.Reason == "" {
+		t.Fatal("expected a reason for polling")
+	}
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "foo")
+
+	reader := h.Wait(r, true, false, nil)
+	expectString(t, reader, "foo")
+}
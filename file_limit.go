@@ -0,0 +1,176 @@
+package tail
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileLimit caps how many files the polling Watchers sharing it have
+// open at once, for following more files than the limit on open
+// descriptors allows. Set the same one as Config.FileLimit for each file,
+// such as in every Config of a MultiTailer, or as DirConfig.FileLimit.
+//
+// A file that nothing was written to for the idle time is closed, and
+// its state is kept so it's opened again where it was read up to once it
+// has more to read, which is returned as a Resumed event. These are
+// counted in Stats.Evictions. While the limit is reached, more files
+// aren't opened until idle ones are closed, so new data in them isn't
+// noticed until then. Anything written to a closed file right before it
+// was rotated is missed, since it's no longer open to finish reading.
+type FileLimit struct {
+	max  int
+	idle time.Duration
+
+	mu   sync.Mutex
+	open int
+}
+
+// NewFileLimit returns a FileLimit of max open files, which closes files
+// after they're idle for idle.
+func NewFileLimit(max int, idle time.Duration) (*FileLimit, error) {
+	if max < 1 {
+		return nil, errors.New("config value for max open files must be at least 1")
+	}
+
+	if idle < 0 {
+		return nil, errors.New("config value for idle time cannot be negative")
+	}
+
+	return &FileLimit{max: max, idle: idle}, nil
+}
+
+// Open returns how many files are open under the limit.
+func (l *FileLimit) Open() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.open
+}
+
+// acquire reserves a file to open, returning false if the limit is
+// reached. A nil FileLimit has no limit.
+func (l *FileLimit) acquire() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open >= l.max {
+		return false
+	}
+	l.open++
+	return true
+}
+
+// release gives back a file reserved with acquire once it's closed.
+func (l *FileLimit) release() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.open--
+}
+
+// evict closes the open file if it was read to the end and nothing was
+// written to it for the idle time of the FileLimit, keeping its state to
+// resume from, with mu held.
+func (p *pollWatcher) evict() {
+	// A deleted file can only be read while it's open.
+	if p.c.FileLimit == nil || p.f == nil || p.removed || !p.graceStart.IsZero() {
+		return
+	}
+
+	if p.c.clock().Now().Sub(p.stats.LastActivity) < p.c.FileLimit.idle {
+		return
+	}
+
+	state, err := p.fileState(p.f)
+	if err != nil || state.Size != state.Position {
+		return
+	}
+
+	p.f.Close()
+	p.f = nil
+	p.c.FileLimit.release()
+	p.parked = &state
+	p.stats.Evictions++
+	p.debug("closed idle file", "position", state.Position)
+}
+
+// resume opens the file closed by evict again once there's more to read
+// from it, or finishes it like a rotation if a different file is at the
+// path, with mu held.
+func (p *pollWatcher) resume() (r pollResult, ok bool) {
+	parked := *p.parked
+	// A truncated file is still the same one, and the next poll finds it
+	// smaller than where it was read up to.
+	same := func(s FileState) bool {
+		return p.c.SameFile(parked, s)
+	}
+
+	named, err := NewFileStateFromPath(p.c.Path)
+	if os.IsNotExist(err) {
+		return r, false
+	} else if err != nil {
+		return pollResult{err: err}, true
+	}
+
+	if !same(*named) {
+		p.parked = nil
+		p.rotatedName = findRenamed(filepath.Dir(p.target), parked)
+		p.stats.Rotations++
+		p.debug("rotation detected while closed", "rotated_to", p.rotatedName, "position", parked.Position)
+		p.finished = &parked
+		return p.check()
+	}
+
+	if named.Size == parked.Position || !p.c.FileLimit.acquire() {
+		return r, false
+	}
+
+	p.stats.Reopens++
+	f, err := openFile(p.target)
+	if err != nil {
+		p.c.FileLimit.release()
+		if os.IsNotExist(err) {
+			return r, false
+		}
+		return pollResult{err: err}, true
+	}
+
+	// It's only resumed if it's still the same file once it's open, and
+	// otherwise the next poll finds the one that replaced it.
+	state, err := NewFileState(f)
+	if err == nil && same(state) {
+		_, err = f.Seek(parked.Position, io.SeekStart)
+	} else if err == nil {
+		f.Close()
+		p.c.FileLimit.release()
+		return r, false
+	}
+	if err != nil {
+		f.Close()
+		p.c.FileLimit.release()
+		return pollResult{err: err}, true
+	}
+
+	p.f = f
+	p.parked = nil
+	r.s.State, err = p.fileState(f)
+	if err != nil {
+		return pollResult{s: r.s, err: err}, true
+	}
+	p.readTo = r.s.State.Position
+	p.debug("resumed file", "position", r.s.State.Position)
+
+	r.s.File = f
+	r.s.Event = Resumed
+	r.s.ReOpened = true
+	return r, true
+}
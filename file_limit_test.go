@@ -0,0 +1,153 @@
+package tail
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatcherFileLimit(t *testing.T) {
+	limit, err := NewFileLimit(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewWatcherHarness(t, "file-limit-test")
+	w, err := NewPollingWatcher(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		FileLimit: limit,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a")
+
+	f := h.Wait(w, true, false, nil)
+	expectString(t, f, "a")
+
+	// The file is closed by the first poll that finds nothing new.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, _, err := w.(ContextWatcher).WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+
+	if n := limit.Open(); n != 0 {
+		t.Fatalf("expected no open files, got %v", n)
+	}
+	if n := w.(StatsWatcher).Stats().Evictions; n != 1 {
+		t.Fatalf("expected 1 eviction, got %v", n)
+	}
+
+	writeString(t, writer, "b")
+	s, closed, err := w.Wait()
+	if err != nil || closed {
+		t.Fatalf("unexpected result %v, %v", closed, err)
+	}
+	if s.Event != Resumed || s.State.Position != 1 {
+		t.Fatalf("expected to resume at 1, got %v", s)
+	}
+	expectString(t, s.File, "b")
+
+	if n := limit.Open(); n != 1 {
+		t.Fatalf("expected 1 open file, got %v", n)
+	}
+
+	// A file rotated while it was closed is finished, and the new one
+	// read from the start.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, _, err := w.(ContextWatcher).WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "c")
+
+	s, closed, err = w.Wait()
+	if err != nil || closed {
+		t.Fatalf("unexpected result %v, %v", closed, err)
+	}
+	if s.Event != Rotated || s.State.Position != 0 {
+		t.Fatalf("expected a rotation, got %v", s)
+	}
+	expectString(t, s.File, "c")
+
+	if n := w.(StatsWatcher).Stats().Rotations; n != 1 {
+		t.Fatalf("expected 1 rotation, got %v", n)
+	}
+}
+
+func TestMultiTailerFileLimit(t *testing.T) {
+	limit, err := NewFileLimit(1, time.Millisecond*30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewWatcherHarness(t, "multi-tailer-limit-a")
+	b := NewWatcherHarness(t, "multi-tailer-limit-b")
+
+	wa := a.Create()
+	defer wa.Close()
+	writeString(t, wa, "a1\n")
+
+	mt, err := NewMultiTailer([]Config{
+		{Path: a.Path(), Interval: time.Millisecond * 10, FileLimit: limit},
+		{Path: b.Path(), Interval: time.Millisecond * 10, FileLimit: limit},
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Close()
+
+	expectLine := func(path, s string) {
+		t.Helper()
+		if !mt.Next() {
+			t.Fatal("expected a line")
+		}
+		if line := mt.Line(); line.Path != path || string(line.Bytes) != s {
+			t.Fatalf("expected %q from %v, got %q from %v", s, path, line.Bytes, line.Path)
+		}
+	}
+
+	expectLine(a.Path(), "a1")
+
+	// b can only be opened once a was idle long enough to be closed,
+	// and the other way around.
+	wb := b.Create()
+	defer wb.Close()
+	writeString(t, wb, "b1\n")
+	expectLine(b.Path(), "b1")
+
+	writeString(t, wa, "a2\n")
+	expectLine(a.Path(), "a2")
+
+	stats := mt.Stats()
+	if stats[a.Path()].Evictions == 0 || stats[b.Path()].Evictions == 0 {
+		t.Fatalf("expected both files to be evicted, got %v and %v", stats[a.Path()].Evictions, stats[b.Path()].Evictions)
+	}
+
+	states := mt.States()
+	if states[a.Path()].Position != 6 || states[b.Path()].Position != 3 {
+		t.Fatalf("expected positions 6 and 3, got %v and %v", states[a.Path()].Position, states[b.Path()].Position)
+	}
+}
+
+func TestNewFileLimit(t *testing.T) {
+	if _, err := NewFileLimit(0, time.Second); err == nil {
+		t.Fatal("expected an error for no open files")
+	}
+	if _, err := NewFileLimit(1, -time.Second); err == nil {
+		t.Fatal("expected an error for a negative idle time")
+	}
+}
@@ -1,18 +1,13 @@
 package tail
 
 import (
-	"errors"
+	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
-	"syscall"
-
-	"golang.org/x/sys/unix"
+	"time"
 )
 
-// TODO: file creation time (or birth time) isn't universally supported the same
-// between filesystems and stat doesn't always show it, but in the future
-// it may be worth supporting an optional creation time field.
-
 // FileState describes some details about a regular file that can be used
 // to compare it with another file on disk for a best guess on if they are
 // the same file. It can also store the position of a file descriptor
@@ -21,31 +16,115 @@ type FileState struct {
 	Size     int64  `json:",string"`
 	Position int64  `json:",string"`
 	Inode    uint64 `json:",string"`
+	// Device is the ID of the device containing the file, since inodes
+	// are only unique within a filesystem.
+	Device uint64 `json:",string"`
+	// ModTime is the last modification time of the file.
+	ModTime time.Time
+	// BirthTime is optional and is when the file was created, which
+	// tells files apart when an inode is reused. It's only known on
+	// Linux, from statx, and on Darwin, FreeBSD and NetBSD, and only
+	// if the filesystem records it.
+	BirthTime time.Time
+	// Fingerprint is optional and is a hash of the first FingerprintSize
+	// bytes of the file, which tells files apart when an inode is reused.
+	// See Config.FingerprintBytes.
+	Fingerprint     uint64 `json:",string,omitempty"`
+	FingerprintSize int64  `json:",string,omitempty"`
+	// Checksum is optional and is a hash of the ChecksumSize bytes before
+	// Position, which tells apart a file that was rewritten with the
+	// same inode and at least as many bytes. See SetChecksum.
+	Checksum     uint64 `json:",string,omitempty"`
+	ChecksumSize int64  `json:",string,omitempty"`
+}
+
+// FileID identifies a file independently of its name, so it can be found
+// again after it's renamed. On unix it's the device and inode, and on
+// Windows the volume serial number and file index. The zero FileID means
+// the identity isn't known, such as on wasm runtimes that don't provide
+// one. FileIDs are comparable, but Equal should be used to compare them,
+// since it allows for an unknown device.
+type FileID struct {
+	device, inode uint64
+}
+
+// ID returns the identity of the file s describes, from its Inode and
+// Device.
+func (s FileState) ID() FileID {
+	return FileID{device: s.Device, inode: s.Inode}
+}
+
+// IsZero reports whether the identity isn't known.
+func (id FileID) IsZero() bool {
+	return id.inode == 0
+}
+
+// Equal reports whether id and o are known to be the same file. The
+// device is ignored if it's unknown in either, such as from a FileState
+// saved before it was recorded.
+func (id FileID) Equal(o FileID) bool {
+	if id.IsZero() || o.IsZero() || id.inode != o.inode {
+		return false
+	}
+	return id.device == 0 || o.device == 0 || id.device == o.device
+}
+
+func (id FileID) String() string {
+	return fmt.Sprintf("%v:%v", id.device, id.inode)
+}
+
+// pathID returns the identity of the file at path, described by stat.
+func pathID(path string, stat os.FileInfo) (FileID, error) {
+	device, inode, err := pathIdentity(path, stat)
+	return FileID{device: device, inode: inode}, err
 }
 
-// SeekIfMatches will try to determine if this FileState matches that of the file,
-// which means they must have a matching Inode and the size of f must be at least as
-// big as this FileState's Position. Otherwise it does nothing. The returned SeekInfo
-// is always valid for f if the error is nil, though the Position is not updated so
-// if the descriptor of f points beyond the start of the file, Position will
-// need to be updated outside this method.
+// SeekIfMatches will try to determine if this FileState matches that of
+// the file, and if so seeks f to its Position. To match, they must have a
+// matching Inode, Device and BirthTime (when both are known) and the same
+// Fingerprint (when this FileState has one), f must not have been
+// modified before this FileState's ModTime, and the size of f must be at
+// least as big as this FileState's Position. If it has a Checksum, the
+// bytes of f before Position must also still match it. Otherwise it does
+// nothing. The returned FileState is always valid for f if the error is
+// nil, though if it doesn't match, its Position is where f already was.
 func (s *FileState) SeekIfMatches(f *os.File) (fs FileState, matches bool, err error) {
 	newState, err := NewFileState(f)
 	if err != nil {
 		return FileState{}, false, err
 	}
 
-	if s.Inode != newState.Inode {
+	if s.FingerprintSize > 0 {
+		var ok bool
+		newState.Fingerprint, ok, err = fingerprint(f, s.FingerprintSize)
+		if err != nil {
+			return FileState{}, false, err
+		} else if !ok {
+			return newState, false, nil
+		}
+		newState.FingerprintSize = s.FingerprintSize
+	}
+
+	if !s.sameIdentity(newState) {
 		return newState, false, nil
 	}
 
-	// Inode can be reused or file could be truncated. Truncation isn't really supported
-	// by this module anyways. Checking the size is another guard against thinking
-	// a different file is the same.
+	// Inode can be reused or the file could be truncated, and either way
+	// Position no longer means the same thing. Checking the size is
+	// another guard against thinking a different file is the same.
 	if s.Position > newState.Size {
 		return newState, false, nil
 	}
 
+	if s.ChecksumSize > 0 {
+		sum, ok, err := s.checksum(f)
+		if err != nil {
+			return FileState{}, false, err
+		} else if !ok || sum != s.Checksum {
+			return newState, false, nil
+		}
+	}
+
 	newState.Position, err = f.Seek(s.Position, io.SeekStart)
 	if err != nil {
 		return FileState{}, true, err
@@ -54,50 +133,133 @@ func (s *FileState) SeekIfMatches(f *os.File) (fs FileState, matches bool, err e
 	return newState, true, err
 }
 
-func (s *FileState) readInfo(i os.FileInfo) error {
-	s.Size = i.Size()
+// sameIdentity compares the identifying fields of s and a later state o.
+// Fields that are unknown (zero) in either, such as from an older saved
+// state or a platform that doesn't provide them, are ignored. Files
+// only grow and are written to over time, so a modification time that
+// went backwards also means it's a different file with a reused inode.
+func (s FileState) sameIdentity(o FileState) bool {
+	if id := s.ID(); !id.IsZero() && !o.ID().IsZero() && !id.Equal(o.ID()) {
+		return false
+	}
+
+	if !s.ModTime.IsZero() && !o.ModTime.IsZero() && o.ModTime.Before(s.ModTime) {
+		return false
+	}
+
+	if !s.BirthTime.IsZero() && !o.BirthTime.IsZero() && !s.BirthTime.Equal(o.BirthTime) {
+		return false
+	}
+
+	if s.FingerprintSize > 0 && s.FingerprintSize == o.FingerprintSize && s.Fingerprint != o.Fingerprint {
+		return false
+	}
+	return true
+}
 
-	switch stat_t := i.Sys().(type) {
-	case *unix.Stat_t:
-		s.Inode = stat_t.Ino
-	case *syscall.Stat_t:
-		s.Inode = stat_t.Ino
-	default:
-		return errors.New("file stat isn't *unix.Stat_t type")
+// fingerprint hashes the first n bytes of r with 64-bit FNV-1a. Ok is
+// false if r is shorter than n.
+func fingerprint(r io.ReaderAt, n int64) (sum uint64, ok bool, err error) {
+	sum, size, err := hashPrefix(io.NewSectionReader(r, 0, n), n)
+	return sum, size == n, err
+}
+
+// SetChecksum sets Checksum to a hash of up to n bytes of r before
+// Position, where r is the file s describes, so SeekIfMatches only
+// resumes from s if those bytes are unchanged.
+func (s *FileState) SetChecksum(r io.ReaderAt, n int64) error {
+	if n > s.Position {
+		n = s.Position
+	}
+
+	s.ChecksumSize = n
+	sum, ok, err := s.checksum(r)
+	if err != nil || !ok {
+		s.Checksum, s.ChecksumSize = 0, 0
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
 	}
+	s.Checksum = sum
 	return nil
 }
 
-// NewFileState will initialize a FileState with the inode, size, and position
-// of the provided file. Currently does not support windows, or anything that
-// isn't a *syscall.Stat_t or *unix.Stat_t in the underlying stat.
+// checksum hashes the ChecksumSize bytes of r before Position like
+// fingerprint. Ok is false if r ends before Position.
+func (s FileState) checksum(r io.ReaderAt) (sum uint64, ok bool, err error) {
+	if s.ChecksumSize > s.Position {
+		return 0, false, nil
+	}
+
+	start := s.Position - s.ChecksumSize
+	sum, size, err := hashPrefix(io.NewSectionReader(r, start, s.ChecksumSize), s.ChecksumSize)
+	return sum, size == s.ChecksumSize, err
+}
+
+// hashPrefix hashes up to the first n bytes of r like fingerprint, and
+// returns how many there were.
+func hashPrefix(r io.Reader, n int64) (sum uint64, size int64, err error) {
+	h := fnv.New64a()
+	size, err = io.CopyN(h, r, n)
+	if err == io.EOF {
+		err = nil
+	}
+	return h.Sum64(), size, err
+}
+
+// sameFile reports whether the file described by o, stat'd after s, is
+// most likely the file described by s, by their identity. A file with
+// the same identity that got smaller was truncated, which is left to the
+// Watcher's Truncation policy. Only when the inode is unknown, as on some
+// wasm runtimes, is a file that got smaller considered new, since that's
+// the only way to guess.
+func (s FileState) sameFile(o FileState) bool {
+	if !s.sameIdentity(o) {
+		return false
+	}
+
+	if s.ID().IsZero() || o.ID().IsZero() {
+		return o.Size >= s.Size
+	}
+	return true
+}
+
+func (s *FileState) readInfo(i os.FileInfo, device, inode uint64) {
+	s.Size = i.Size()
+	s.Inode = inode
+	s.Device = device
+	s.ModTime = i.ModTime()
+}
+
+// NewFileState will initialize a FileState with the inode, device, size,
+// modification time, birth time and position of the provided file. It's
+// supported on unix ports, where the underlying stat is a
+// *syscall.Stat_t or *unix.Stat_t, on Windows, where the file index and
+// volume serial number are used as the inode and device, and on
+// js/wasip1, where the inode is left as 0 if the runtime doesn't provide
+// one.
 func NewFileState(f *os.File) (FileState, error) {
 	stat, err := f.Stat()
 	if err != nil {
 		return FileState{}, err
 	}
 
-	var inode uint64
-
-	switch stat_t := stat.Sys().(type) {
-	case *unix.Stat_t:
-		inode = stat_t.Ino
-	case *syscall.Stat_t:
-		inode = stat_t.Ino
-	default:
-		return FileState{}, errors.New("file stat isn't *unix.Stat_t type")
+	device, inode, err := fileIdentity(f, stat)
+	if err != nil {
+		return FileState{}, err
 	}
 
-	pos, err := f.Seek(0, io.SeekCurrent)
+	var state FileState
+	state.readInfo(stat, device, inode)
+	state.BirthTime = fileBirthTime(f, stat)
+
+	state.Position, err = f.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return FileState{}, err
 	}
 
-	return FileState{
-		Size:     stat.Size(),
-		Inode:    inode,
-		Position: pos,
-	}, nil
+	return state, nil
 }
 
 func NewFileStateFromPath(p string) (*FileState, error) {
@@ -106,6 +268,13 @@ func NewFileStateFromPath(p string) (*FileState, error) {
 		return nil, err
 	}
 
+	device, inode, err := pathIdentity(p, stat)
+	if err != nil {
+		return nil, err
+	}
+
 	var state FileState
-	return &state, state.readInfo(stat)
+	state.readInfo(stat, device, inode)
+	state.BirthTime = pathBirthTime(p, stat)
+	return &state, nil
 }
@@ -0,0 +1,36 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package tail
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileBirthTime returns when the open file f was created, from the
+// Birthtimespec stat provides, or the zero time if it isn't known.
+func fileBirthTime(f *os.File, stat os.FileInfo) time.Time {
+	return statBirthTime(stat)
+}
+
+// pathBirthTime returns when the file at path, which stat came from, was
+// created like fileBirthTime.
+func pathBirthTime(path string, stat os.FileInfo) time.Time {
+	return statBirthTime(stat)
+}
+
+// infoBirthTime returns when a file from an fs.FS was created, if its
+// stat info has it.
+func infoBirthTime(i os.FileInfo) time.Time {
+	return statBirthTime(i)
+}
+
+func statBirthTime(stat os.FileInfo) time.Time {
+	stat_t, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok || stat_t.Birthtimespec.Sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(stat_t.Birthtimespec.Sec), int64(stat_t.Birthtimespec.Nsec))
+}
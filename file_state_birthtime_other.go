@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !freebsd && !netbsd
+// +build !linux,!darwin,!freebsd,!netbsd
+
+package tail
+
+import (
+	"os"
+	"time"
+)
+
+// fileBirthTime returns the zero time, since the birth time of files
+// isn't provided on this platform.
+func fileBirthTime(f *os.File, stat os.FileInfo) time.Time {
+	return time.Time{}
+}
+
+// pathBirthTime returns the zero time like fileBirthTime.
+func pathBirthTime(path string, stat os.FileInfo) time.Time {
+	return time.Time{}
+}
+
+// infoBirthTime returns the zero time like fileBirthTime.
+func infoBirthTime(i os.FileInfo) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,34 @@
+package tail
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileBirthTime returns when the open file f was created, using statx,
+// or the zero time if the kernel or filesystem doesn't record it.
+func fileBirthTime(f *os.File, stat os.FileInfo) time.Time {
+	return statxBirthTime(int(f.Fd()), "", unix.AT_EMPTY_PATH)
+}
+
+// pathBirthTime returns when the file at path, which stat came from, was
+// created like fileBirthTime.
+func pathBirthTime(path string, stat os.FileInfo) time.Time {
+	return statxBirthTime(unix.AT_FDCWD, path, 0)
+}
+
+// infoBirthTime returns the zero time, since the stat info of a file from
+// an fs.FS doesn't have the birth time on Linux.
+func infoBirthTime(i os.FileInfo) time.Time {
+	return time.Time{}
+}
+
+func statxBirthTime(dirfd int, path string, flags int) time.Time {
+	var stx unix.Statx_t
+	if err := unix.Statx(dirfd, path, flags, unix.STATX_BTIME, &stx); err != nil || stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec))
+}
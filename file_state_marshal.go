@@ -0,0 +1,245 @@
+package tail
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileStateVersion is the version of the text and binary encodings of
+// FileState. It goes up whenever a field is added, and states encoded by
+// an older version are still decoded, with the fields it didn't have
+// left as zero.
+const fileStateVersion = 1
+
+var errFileStateEncoding = errors.New("invalid FileState encoding")
+
+// MarshalText encodes s as its version followed by each field that isn't
+// zero as key=value, separated by spaces, such as
+// "v1 size=10 position=4 inode=5 device=1 modtime=2006-01-02T15:04:05Z".
+func (s FileState) MarshalText() ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "v%v", fileStateVersion)
+
+	field := func(key string, value string) {
+		b.WriteByte(' ')
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(value)
+	}
+	ints := []struct {
+		key   string
+		value int64
+	}{
+		{"size", s.Size},
+		{"position", s.Position},
+	}
+	for _, i := range ints {
+		if i.value != 0 {
+			field(i.key, strconv.FormatInt(i.value, 10))
+		}
+	}
+
+	uints := []struct {
+		key   string
+		value uint64
+	}{
+		{"inode", s.Inode},
+		{"device", s.Device},
+	}
+	for _, u := range uints {
+		if u.value != 0 {
+			field(u.key, strconv.FormatUint(u.value, 10))
+		}
+	}
+
+	if !s.ModTime.IsZero() {
+		field("modtime", s.ModTime.Format(time.RFC3339Nano))
+	}
+	if !s.BirthTime.IsZero() {
+		field("birthtime", s.BirthTime.Format(time.RFC3339Nano))
+	}
+
+	if s.FingerprintSize != 0 {
+		field("fingerprint", strconv.FormatUint(s.Fingerprint, 16))
+		field("fingerprintsize", strconv.FormatInt(s.FingerprintSize, 10))
+	}
+	if s.ChecksumSize != 0 {
+		field("checksum", strconv.FormatUint(s.Checksum, 16))
+		field("checksumsize", strconv.FormatInt(s.ChecksumSize, 10))
+	}
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText decodes a FileState encoded by MarshalText, including by
+// an older version of it. A state from a newer version is an error.
+func (s *FileState) UnmarshalText(text []byte) error {
+	fields := strings.Fields(string(text))
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "v") {
+		return fmt.Errorf("%w: missing version", errFileStateEncoding)
+	}
+
+	version, err := strconv.Atoi(fields[0][1:])
+	if err != nil {
+		return fmt.Errorf("%w: bad version %q", errFileStateEncoding, fields[0])
+	} else if version < 1 || version > fileStateVersion {
+		return fmt.Errorf("%w: unsupported version %v", errFileStateEncoding, version)
+	}
+
+	var state FileState
+	for _, f := range fields[1:] {
+		i := strings.IndexByte(f, '=')
+		if i < 0 {
+			return fmt.Errorf("%w: missing value in %q", errFileStateEncoding, f)
+		}
+
+		key, value := f[:i], f[i+1:]
+		switch key {
+		case "size":
+			state.Size, err = strconv.ParseInt(value, 10, 64)
+		case "position":
+			state.Position, err = strconv.ParseInt(value, 10, 64)
+		case "inode":
+			state.Inode, err = strconv.ParseUint(value, 10, 64)
+		case "device":
+			state.Device, err = strconv.ParseUint(value, 10, 64)
+		case "modtime":
+			state.ModTime, err = time.Parse(time.RFC3339Nano, value)
+		case "birthtime":
+			state.BirthTime, err = time.Parse(time.RFC3339Nano, value)
+		case "fingerprint":
+			state.Fingerprint, err = strconv.ParseUint(value, 16, 64)
+		case "fingerprintsize":
+			state.FingerprintSize, err = strconv.ParseInt(value, 10, 64)
+		case "checksum":
+			state.Checksum, err = strconv.ParseUint(value, 16, 64)
+		case "checksumsize":
+			state.ChecksumSize, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return fmt.Errorf("%w: unknown field %q", errFileStateEncoding, key)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: bad %v: %v", errFileStateEncoding, key, err)
+		}
+	}
+
+	*s = state
+	return nil
+}
+
+// MarshalBinary encodes s compactly as its version followed by each field
+// as a varint, in a fixed order that new fields are added to the end of.
+// Times are nanoseconds since the Unix epoch, or 0 if they're unknown.
+func (s FileState) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 1+10*binary.MaxVarintLen64)
+	b[0] = fileStateVersion
+	n := 1
+
+	for _, i := range []int64{s.Size, s.Position} {
+		n += binary.PutVarint(b[n:], i)
+	}
+	for _, u := range []uint64{s.Inode, s.Device} {
+		n += binary.PutUvarint(b[n:], u)
+	}
+	n += binary.PutVarint(b[n:], unixNano(s.ModTime))
+	n += binary.PutUvarint(b[n:], s.Fingerprint)
+	n += binary.PutVarint(b[n:], s.FingerprintSize)
+	n += binary.PutVarint(b[n:], unixNano(s.BirthTime))
+	n += binary.PutUvarint(b[n:], s.Checksum)
+	n += binary.PutVarint(b[n:], s.ChecksumSize)
+	return b[:n], nil
+}
+
+// UnmarshalBinary decodes a FileState encoded by MarshalBinary, including
+// by an older version of it, which ends before the fields it didn't have.
+// A state from a newer version is an error.
+func (s *FileState) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%w: missing version", errFileStateEncoding)
+	} else if data[0] < 1 || data[0] > fileStateVersion {
+		return fmt.Errorf("%w: unsupported version %v", errFileStateEncoding, data[0])
+	}
+	data = data[1:]
+
+	var state FileState
+	var modTime, birthTime int64
+	ints := []interface{}{
+		&state.Size,
+		&state.Position,
+		&state.Inode,
+		&state.Device,
+		&modTime,
+		&state.Fingerprint,
+		&state.FingerprintSize,
+		&birthTime,
+		&state.Checksum,
+		&state.ChecksumSize,
+	}
+	for _, i := range ints {
+		if len(data) == 0 {
+			break
+		}
+
+		var n int
+		switch i := i.(type) {
+		case *int64:
+			*i, n = binary.Varint(data)
+		case *uint64:
+			*i, n = binary.Uvarint(data)
+		}
+		if n <= 0 {
+			return fmt.Errorf("%w: bad varint", errFileStateEncoding)
+		}
+		data = data[n:]
+	}
+	if len(data) > 0 {
+		return fmt.Errorf("%w: %v bytes left over", errFileStateEncoding, len(data))
+	}
+
+	state.ModTime = fromUnixNano(modTime)
+	state.BirthTime = fromUnixNano(birthTime)
+	*s = state
+	return nil
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// fileStateJSON is FileState without its methods, so it's still encoded
+// as a JSON object rather than with MarshalText.
+type fileStateJSON FileState
+
+// MarshalJSON encodes s as a JSON object of its fields, as it always has
+// been.
+func (s FileState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fileStateJSON(s))
+}
+
+// UnmarshalJSON decodes a FileState from a JSON object of its fields, in
+// which missing fields are left zero, or from a string encoded by
+// MarshalText.
+func (s *FileState) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var text string
+		if err := json.Unmarshal(b, &text); err != nil {
+			return err
+		}
+		return s.UnmarshalText([]byte(text))
+	}
+	return json.Unmarshal(b, (*fileStateJSON)(s))
+}
@@ -0,0 +1,113 @@
+package tail
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFileStateMarshal(t *testing.T) {
+	now := time.Unix(1700000000, 123456789)
+	states := []FileState{
+		{},
+		{Size: 10, Position: 4, Inode: 5, Device: 1, ModTime: now},
+		{
+			Size:            1 << 40,
+			Position:        1 << 39,
+			Inode:           1<<64 - 1,
+			Device:          65024,
+			ModTime:         now,
+			BirthTime:       now.Add(-time.Hour),
+			Fingerprint:     0xdeadbeef,
+			FingerprintSize: 64,
+			Checksum:        1,
+			ChecksumSize:    16,
+		},
+	}
+
+	equal := func(a, b FileState) bool {
+		return a.ModTime.Equal(b.ModTime) && a.BirthTime.Equal(b.BirthTime) &&
+			a.Size == b.Size && a.Position == b.Position && a.Inode == b.Inode &&
+			a.Device == b.Device && a.Fingerprint == b.Fingerprint &&
+			a.FingerprintSize == b.FingerprintSize && a.Checksum == b.Checksum &&
+			a.ChecksumSize == b.ChecksumSize
+	}
+
+	for _, s := range states {
+		text, err := s.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromText FileState
+		if err := fromText.UnmarshalText(text); err != nil {
+			t.Fatalf("decoding %q: %v", text, err)
+		}
+		if !equal(s, fromText) {
+			t.Fatalf("expected %v from %q, got %v", s, text, fromText)
+		}
+
+		b, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fromBinary FileState
+		if err := fromBinary.UnmarshalBinary(b); err != nil {
+			t.Fatalf("decoding %x: %v", b, err)
+		}
+		if !equal(s, fromBinary) {
+			t.Fatalf("expected %v from %x, got %v", s, b, fromBinary)
+		}
+
+		// JSON is still an object, and a string from MarshalText is
+		// also accepted.
+		j, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j[0] != '{' {
+			t.Fatalf("expected a JSON object, got %s", j)
+		}
+		quoted, _ := json.Marshal(string(text))
+		for _, j := range [][]byte{j, quoted} {
+			var fromJSON FileState
+			if err := json.Unmarshal(j, &fromJSON); err != nil {
+				t.Fatalf("decoding %s: %v", j, err)
+			}
+			if !equal(s, fromJSON) {
+				t.Fatalf("expected %v from %s, got %v", s, j, fromJSON)
+			}
+		}
+	}
+}
+
+func TestFileStateUnmarshalOlder(t *testing.T) {
+	// A checkpoint saved before Device and Fingerprint were added.
+	var s FileState
+	if err := json.Unmarshal([]byte(`{"Size":"10","Position":"4","Inode":"5","ModTime":"2023-11-14T22:13:20Z"}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Position != 4 || s.Inode != 5 || s.Device != 0 || s.FingerprintSize != 0 {
+		t.Fatalf("unexpected state %v", s)
+	}
+
+	// Binary states end before the fields their version didn't have.
+	b, _ := FileState{Size: 10, Position: 4}.MarshalBinary()
+	s = FileState{}
+	if err := s.UnmarshalBinary(b[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if s.Size != 10 || s.Position != 4 {
+		t.Fatalf("unexpected state %v", s)
+	}
+
+	for _, text := range []string{"", "size=10", "v2 size=10", "v1 size=x", "v1 color=blue"} {
+		if err := s.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("expected an error decoding %q", text)
+		}
+	}
+	for _, b := range [][]byte{nil, {2}, {1, 0x80}} {
+		if err := s.UnmarshalBinary(b); err == nil {
+			t.Errorf("expected an error decoding %x", b)
+		}
+	}
+}
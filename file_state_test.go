@@ -0,0 +1,171 @@
+package tail
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileStateSameFile(t *testing.T) {
+
+	now := time.Now()
+	open := FileState{Size: 10, Inode: 5, Device: 1, ModTime: now, BirthTime: now}
+
+	tests := []struct {
+		name     string
+		named    FileState
+		expected bool
+	}{
+		{"same", FileState{Size: 10, Inode: 5, Device: 1, ModTime: now}, true},
+		{"grown", FileState{Size: 20, Inode: 5, Device: 1, ModTime: now.Add(time.Second)}, true},
+		{"different inode", FileState{Size: 10, Inode: 6, Device: 1, ModTime: now}, false},
+		{"different device", FileState{Size: 10, Inode: 5, Device: 2, ModTime: now}, false},
+		{"truncated", FileState{Size: 5, Inode: 5, Device: 1, ModTime: now.Add(time.Second)}, true},
+		{"reused inode older", FileState{Size: 10, Inode: 5, Device: 1, ModTime: now.Add(-time.Second)}, false},
+		{"unknown device and time", FileState{Size: 10, Inode: 5}, true},
+		{"reused inode born later", FileState{Size: 10, Inode: 5, Device: 1, ModTime: now, BirthTime: now.Add(time.Second)}, false},
+		{"unknown inode smaller", FileState{Size: 5}, false},
+	}
+
+	for _, test := range tests {
+		if actual := open.sameFile(test.named); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestFileStateFingerprint(t *testing.T) {
+
+	h := NewWatcherHarness(t, "file-state-fingerprint-test")
+	writer := h.Create()
+	writeString(t, writer, "ab\n")
+	writer.Close()
+
+	r, err := NewLineReader(Config{
+		Path:             h.Path(),
+		Interval:         time.Millisecond * 10,
+		FingerprintBytes: 4,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "ab")
+	r.Close()
+
+	// The file was shorter than FingerprintBytes.
+	state := r.FileState()
+	if state.FingerprintSize != 3 {
+		t.Fatalf("expected a fingerprint of 3 bytes, got %v", state.FingerprintSize)
+	}
+
+	seek := func() bool {
+		f, err := os.Open(h.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		_, matches, err := state.SeekIfMatches(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return matches
+	}
+
+	if err := ioutil.WriteFile(h.Path(), []byte("ab\ncd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !seek() {
+		t.Fatal("expected the state to match the same content")
+	}
+
+	// Rewriting the file in place keeps the inode, but the content
+	// tells it apart.
+	if err := ioutil.WriteFile(h.Path(), []byte("xy\nzz\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if seek() {
+		t.Fatal("expected the state not to match different content")
+	}
+}
+
+func TestFileStateChecksum(t *testing.T) {
+
+	h := NewWatcherHarness(t, "file-state-checksum-test")
+	writer := h.Create()
+	writeString(t, writer, "ab\ncd\n")
+	writer.Close()
+
+	r, err := NewLineReader(Config{
+		Path:          h.Path(),
+		Interval:      time.Millisecond * 10,
+		ChecksumBytes: 4,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "ab")
+	readLine(t, r, "cd")
+
+	state, _, err := r.CloseAndState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.ChecksumSize != 4 {
+		t.Fatalf("expected a checksum of 4 bytes, got %v", state.ChecksumSize)
+	}
+
+	seek := func() bool {
+		f, err := os.Open(h.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		_, matches, err := state.SeekIfMatches(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return matches
+	}
+
+	if err := ioutil.WriteFile(h.Path(), []byte("ab\ncd\nef\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !seek() {
+		t.Fatal("expected the state to match the same content")
+	}
+
+	// Rewriting the file in place keeps the inode and it's big enough,
+	// but the bytes before the position changed.
+	if err := ioutil.WriteFile(h.Path(), []byte("ab\nxy\nef\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if seek() {
+		t.Fatal("expected the state not to match different content")
+	}
+}
+
+func TestFileIDEqual(t *testing.T) {
+
+	id := FileState{Inode: 5, Device: 1}.ID()
+
+	tests := []struct {
+		name     string
+		other    FileState
+		expected bool
+	}{
+		{"same", FileState{Inode: 5, Device: 1}, true},
+		{"unknown device", FileState{Inode: 5}, true},
+		{"different device", FileState{Inode: 5, Device: 2}, false},
+		{"different inode", FileState{Inode: 6, Device: 1}, false},
+		{"unknown", FileState{}, false},
+	}
+
+	for _, test := range tests {
+		if actual := id.Equal(test.other.ID()); actual != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd illumos linux netbsd openbsd solaris
+
+package tail
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// statIdentity pulls the device and inode out of the platform specific
+// stat info. Every unix port Go supports exposes them through either
+// *syscall.Stat_t or *unix.Stat_t, though their widths vary between them.
+func statIdentity(i os.FileInfo) (device, inode uint64, err error) {
+	switch stat_t := i.Sys().(type) {
+	case *unix.Stat_t:
+		return uint64(stat_t.Dev), uint64(stat_t.Ino), nil
+	case *syscall.Stat_t:
+		return uint64(stat_t.Dev), uint64(stat_t.Ino), nil
+	default:
+		return 0, 0, errors.New("file stat isn't *unix.Stat_t type")
+	}
+}
+
+// fileIdentity returns the device and inode of the open file f, which
+// stat came from.
+func fileIdentity(f *os.File, stat os.FileInfo) (device, inode uint64, err error) {
+	return statIdentity(stat)
+}
+
+// pathIdentity returns the device and inode of the file at path, which
+// stat came from.
+func pathIdentity(path string, stat os.FileInfo) (device, inode uint64, err error) {
+	return statIdentity(stat)
+}
+
+// fileUnlinked reports whether the open file f was deleted, as opposed
+// to still having a name, even if it was renamed.
+func fileUnlinked(f *os.File) (bool, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	switch stat_t := stat.Sys().(type) {
+	case *unix.Stat_t:
+		return stat_t.Nlink == 0, nil
+	case *syscall.Stat_t:
+		return stat_t.Nlink == 0, nil
+	default:
+		return false, errors.New("file stat isn't *unix.Stat_t type")
+	}
+}
+
+// infoIdentity returns the device and inode from the stat info of a file
+// from an fs.FS, or 0 if it doesn't have them.
+func infoIdentity(i os.FileInfo) (device, inode uint64) {
+	device, inode, _ = statIdentity(i)
+	return device, inode
+}
+
+// openFile opens path for reading.
+func openFile(path string) (*os.File, error) {
+	return os.Open(path)
+}
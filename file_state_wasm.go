@@ -0,0 +1,57 @@
+//go:build js || wasip1
+// +build js wasip1
+
+package tail
+
+import (
+	"os"
+	"syscall"
+)
+
+// statIdentity returns the device and inode if the runtime provides them.
+// Many WASI runtimes and browser filesystems report 0 or no stat at all, in
+// which case FileState comparisons fall back to using position and size only.
+func statIdentity(i os.FileInfo) (device, inode uint64, err error) {
+	if stat_t, ok := i.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat_t.Dev), uint64(stat_t.Ino), nil
+	}
+	return 0, 0, nil
+}
+
+// fileIdentity returns the device and inode of the open file f, which
+// stat came from.
+func fileIdentity(f *os.File, stat os.FileInfo) (device, inode uint64, err error) {
+	return statIdentity(stat)
+}
+
+// pathIdentity returns the device and inode of the file at path, which
+// stat came from.
+func pathIdentity(path string, stat os.FileInfo) (device, inode uint64, err error) {
+	return statIdentity(stat)
+}
+
+// fileUnlinked reports whether the open file f was deleted. Runtimes
+// that don't report the link count are assumed to never delete it.
+func fileUnlinked(f *os.File) (bool, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if stat_t, ok := stat.Sys().(*syscall.Stat_t); ok && stat_t.Ino != 0 {
+		return stat_t.Nlink == 0, nil
+	}
+	return false, nil
+}
+
+// infoIdentity returns the device and inode from the stat info of a file
+// from an fs.FS, or 0 if it doesn't have them.
+func infoIdentity(i os.FileInfo) (device, inode uint64) {
+	device, inode, _ = statIdentity(i)
+	return device, inode
+}
+
+// openFile opens path for reading.
+func openFile(path string) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,69 @@
+//go:build windows
+// +build windows
+
+package tail
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the volume serial number and file index of f as
+// the device and inode, since Windows doesn't provide them from stat.
+func fileIdentity(f *os.File, stat os.FileInfo) (device, inode uint64, err error) {
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &info); err != nil {
+		return 0, 0, &os.PathError{Op: "GetFileInformationByHandle", Path: f.Name(), Err: err}
+	}
+	return uint64(info.VolumeSerialNumber), uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow), nil
+}
+
+// fileUnlinked reports whether the open file f was deleted. Windows
+// keeps a deleted file's link until every handle to it is closed, so
+// this is rarely true while it's open.
+func fileUnlinked(f *os.File) (bool, error) {
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &info); err != nil {
+		return false, &os.PathError{Op: "GetFileInformationByHandle", Path: f.Name(), Err: err}
+	}
+	return info.NumberOfLinks == 0, nil
+}
+
+// pathIdentity opens path to get its identity with fileIdentity.
+func pathIdentity(path string, stat os.FileInfo) (device, inode uint64, err error) {
+	f, err := openFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	return fileIdentity(f, stat)
+}
+
+// infoIdentity returns 0 for the device and inode, since Windows only
+// provides them from an open handle, which files from an fs.FS don't
+// expose.
+func infoIdentity(i os.FileInfo) (device, inode uint64) {
+	return 0, 0
+}
+
+// openFile opens path for reading while still allowing it to be renamed
+// or removed, which os.Open doesn't. Otherwise, having the file open
+// would stop it from being rotated.
+func openFile(path string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	h, err := syscall.CreateFile(p,
+		syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
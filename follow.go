@@ -0,0 +1,54 @@
+package tail
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Follow tails the file at path starting from its end, calling fn with each
+// new line until ctx is cancelled or fn returns an error, which is returned.
+// Files are polled every second across rotations and transient errors are
+// ignored, while any other error stops it and is returned. The returned
+// FileState can be used as Config.StartState to resume with a LineReader.
+// The line passed to fn is only valid until fn returns.
+func Follow(ctx context.Context, path string, fn func(line []byte) error) (FileState, error) {
+	return follow(ctx, Config{
+		Path:     path,
+		Interval: time.Second,
+		Whence:   io.SeekEnd,
+	}, fn)
+}
+
+func follow(ctx context.Context, c Config, fn func(line []byte) error) (FileState, error) {
+	r, err := NewLineReader(c, RetryTransient)
+	if err != nil {
+		return FileState{}, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Close()
+		case <-done:
+		}
+	}()
+
+	var fnErr error
+	for r.Next() {
+		if fnErr = fn(r.Bytes()); fnErr != nil {
+			break
+		}
+	}
+	close(done)
+
+	readErr := r.Err()
+	state, _, err := r.CloseAndState()
+	if fnErr != nil {
+		return state, fnErr
+	} else if readErr != nil {
+		return state, readErr
+	}
+	return state, err
+}
@@ -0,0 +1,82 @@
+package tail
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFollow(t *testing.T) {
+
+	h := NewWatcherHarness(t, "follow-test")
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "old\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		Whence:   io.SeekEnd,
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		writeString(t, writer, "new\n")
+	}()
+
+	var lines []string
+	state, err := follow(ctx, c, func(line []byte) error {
+		lines = append(lines, string(line))
+		cancel()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 1 || lines[0] != "new" {
+		t.Fatalf("expected only the new line, got %q", lines)
+	}
+
+	if state.Position != 8 {
+		t.Fatalf("expected final position 8, got %v", state.Position)
+	}
+}
+
+func TestFollowTerminalError(t *testing.T) {
+
+	h := NewWatcherHarness(t, "follow-terminal-test")
+	writer := h.Create()
+	writer.Close()
+
+	// A path under a regular file can never exist.
+	c := Config{
+		Path:     filepath.Join(h.Path(), "app.log"),
+		Interval: time.Millisecond * 10,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := follow(context.Background(), c, func(line []byte) error {
+			return nil
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, syscall.ENOTDIR) {
+			t.Fatalf("expected ENOTDIR, got %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected a terminal error to end Follow")
+	}
+}
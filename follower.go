@@ -0,0 +1,272 @@
+package tail
+
+import (
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	_ io.ReadCloser = (*Follower)(nil)
+	_ io.WriterTo   = (*Follower)(nil)
+)
+
+// copyBufferSize is the size of the buffer WriteTo copies through.
+const copyBufferSize = 256 << 10
+
+// Follower is an io.ReadCloser over the files a Watcher opens. Read blocks
+// until there is data, reading across rotations as if they were one
+// stream, and only returns io.EOF once the Follower is closed. Unlike
+// LineReader, it doesn't split the data, so it can be used with any
+// decoder that reads from an io.Reader.
+//
+// Of the options to stop, StopAtPosition applies, which stops at exactly
+// that position in the file being read, after which Read returns io.EOF.
+// With Offset, it reads only a range of a file.
+type Follower struct {
+	w      Watcher
+	onErr  ErrorHandler
+	retry  time.Duration
+	stopAt int64
+
+	r     io.Reader
+	state FileState
+	err   error
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFollower returns a Follower with a polling Watcher created from c.
+// Errors from the Watcher and from reading are passed to h, and if it
+// returns an error, Read returns it from then on. Otherwise reading is
+// retried after Interval. If h is nil, errors are ignored.
+func NewFollower(c Config, h ErrorHandler) (*Follower, error) {
+	w, err := NewPollingWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFollowerFromWatcher(w, c, h), nil
+}
+
+// NewFollowerFromWatcher is like NewFollower, but reads from the provided
+// Watcher instead of creating one.
+func NewFollowerFromWatcher(w Watcher, c Config, h ErrorHandler) *Follower {
+	if h == nil {
+		h = DiscardErrorHandler
+	}
+
+	retry := c.Interval
+	if retry <= 0 {
+		retry = time.Second
+	}
+
+	return &Follower{
+		w:      w,
+		onErr:  h,
+		retry:  retry,
+		stopAt: c.StopAtPosition,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Read reads up to len(p) bytes from the file currently being followed,
+// waiting for more to be written if it was read to the end.
+func (f *Follower) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for f.err == nil {
+		select {
+		case <-f.stop:
+			return 0, io.EOF
+		default:
+		}
+
+		left, ok := f.left()
+		if !ok {
+			return 0, io.EOF
+		} else if int64(len(p)) > left {
+			p = p[:left]
+		}
+
+		if f.r != nil {
+			n, err := f.r.Read(p)
+			f.state.Position += int64(n)
+			if n > 0 {
+				return n, nil
+			}
+
+			if err != nil && err != io.EOF {
+				f.handle(err)
+				continue
+			}
+		}
+
+		if !f.wait() {
+			return 0, io.EOF
+		}
+	}
+	return 0, f.err
+}
+
+// WriteTo writes everything Read would return to w until the Follower is
+// closed, which returns how much was written and a nil error, or until
+// there's an error writing or the ErrorHandler returns one. It's what
+// io.Copy uses, making it a library version of tail -f | ...
+//
+// If w is an io.ReaderFrom, such as an *os.File or *net.TCPConn, and the
+// Watcher's files are read directly, w reads from each file itself, which
+// uses splice, sendfile or copy_file_range on Linux. Errors from it are
+// returned rather than passed to the ErrorHandler, since errors reading
+// can't be told apart from ones writing. Otherwise a large buffer is
+// used.
+func (f *Follower) WriteTo(w io.Writer) (n int64, err error) {
+	var buf []byte
+	for f.err == nil {
+		select {
+		case <-f.stop:
+			return n, nil
+		default:
+		}
+
+		left, ok := f.left()
+		if !ok {
+			return n, nil
+		}
+
+		if file, ok := f.r.(*os.File); ok && file != nil {
+			if rf, ok := w.(io.ReaderFrom); ok {
+				var r io.Reader = file
+				if f.stopAt > 0 {
+					r = io.LimitReader(file, left)
+				}
+
+				written, err := rf.ReadFrom(r)
+				n += written
+				f.state.Position += written
+				if err != nil {
+					return n, f.stopped(err)
+				}
+				if written == left {
+					continue
+				}
+				if !f.wait() {
+					return n, nil
+				}
+				continue
+			}
+		}
+
+		if f.r != nil {
+			if buf == nil {
+				buf = make([]byte, copyBufferSize)
+			}
+
+			limited := buf
+			if int64(len(limited)) > left {
+				limited = limited[:left]
+			}
+
+			nr, err := f.r.Read(limited)
+			f.state.Position += int64(nr)
+			if nr > 0 {
+				nw, err := w.Write(buf[:nr])
+				n += int64(nw)
+				if err == nil && nw < nr {
+					err = io.ErrShortWrite
+				}
+				if err != nil {
+					return n, err
+				}
+				continue
+			}
+
+			if err != nil && err != io.EOF {
+				f.handle(err)
+				continue
+			}
+		}
+
+		if !f.wait() {
+			return n, nil
+		}
+	}
+	return n, f.err
+}
+
+// left returns how much can be read before StopAtPosition, returning
+// false once it's reached.
+func (f *Follower) left() (int64, bool) {
+	if f.stopAt <= 0 {
+		return math.MaxInt64, true
+	}
+
+	left := f.stopAt - f.state.Position
+	return left, left > 0
+}
+
+// stopped returns nil instead of err if the Follower was closed, which
+// can cause errors reading from its file.
+func (f *Follower) stopped(err error) error {
+	select {
+	case <-f.stop:
+		return nil
+	default:
+		return err
+	}
+}
+
+// wait waits on the Watcher for more to read, returning false if it was
+// closed.
+func (f *Follower) wait() bool {
+	s, closed, err := f.w.Wait()
+	if closed {
+		return false
+	}
+
+	if err != nil {
+		f.handle(err)
+		return true
+	}
+
+	f.state = s.State
+	if s.ReOpened {
+		f.r = s.reader()
+	}
+	return true
+}
+
+// handle passes err to the ErrorHandler, then waits before retrying
+// unless it returned an error.
+func (f *Follower) handle(err error) {
+	if f.err = f.onErr(err); f.err != nil {
+		return
+	}
+
+	timer := time.NewTimer(f.retry)
+	defer timer.Stop()
+
+	select {
+	case <-f.stop:
+	case <-timer.C:
+	}
+}
+
+// FileState returns the state of the file being read, with the position
+// after the last byte returned by Read. It isn't safe to call in parallel
+// to Read.
+func (f *Follower) FileState() FileState {
+	return f.state
+}
+
+// Close closes the Watcher, which causes a Read in progress or any later
+// call to return io.EOF.
+func (f *Follower) Close() error {
+	f.stopOnce.Do(func() { close(f.stop) })
+	return f.w.Close()
+}
@@ -0,0 +1,219 @@
+package tail
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFollower(t *testing.T) {
+
+	h := NewWatcherHarness(t, "follower-test")
+
+	f, err := NewFollower(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := h.Create()
+	writeString(t, writer, "abc")
+
+	read := func(expected string) {
+		t.Helper()
+		b := make([]byte, len(expected))
+		if _, err := io.ReadFull(f, b); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Fatalf("expected %q, got %q", expected, b)
+		}
+	}
+
+	read("abc")
+
+	writeString(t, writer, "de")
+	writer.Close()
+
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "fg")
+
+	// Reads continue into the new file as one stream.
+	read("defg")
+
+	if pos := f.FileState().Position; pos != 2 {
+		t.Fatalf("expected position 2, got %v", pos)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := f.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	time.Sleep(time.Millisecond * 20)
+	f.Close()
+
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			t.Fatalf("expected EOF after closing, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't interrupt Read")
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that's safe to write and read in
+// parallel, and isn't an io.ReaderFrom.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollowerWriteTo(t *testing.T) {
+	dst, err := os.Create(filepath.Join(t.TempDir(), "copy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	for _, tc := range []struct {
+		name     string
+		w        io.Writer
+		contents func() string
+	}{
+		{"buffer", new(lockedBuffer), nil},
+		{"file", dst, func() string {
+			b, err := ioutil.ReadFile(dst.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(b)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.contents == nil {
+				tc.contents = tc.w.(*lockedBuffer).String
+			}
+
+			h := NewWatcherHarness(t, "follower-write-to-test")
+			f, err := NewFollower(Config{
+				Path:     h.Path(),
+				Interval: time.Millisecond * 10,
+			}, func(e error) error {
+				t.Error(e)
+				return e
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			type result struct {
+				n   int64
+				err error
+			}
+			done := make(chan result)
+			go func() {
+				n, err := io.Copy(tc.w, f)
+				done <- result{n, err}
+			}()
+
+			expect := func(s string) {
+				t.Helper()
+				deadline := time.Now().Add(time.Second)
+				for tc.contents() != s {
+					if time.Now().After(deadline) {
+						t.Fatalf("expected %q to be copied, got %q", s, tc.contents())
+					}
+					time.Sleep(time.Millisecond * 10)
+				}
+			}
+
+			writer := h.Create()
+			writeString(t, writer, "abc")
+			writer.Close()
+			expect("abc")
+
+			h.Rotate()
+			writer = h.Create()
+			defer writer.Close()
+			writeString(t, writer, "de")
+			expect("abcde")
+
+			f.Close()
+			r := <-done
+			if r.err != nil || r.n != 5 {
+				t.Fatalf("expected 5 bytes copied without an error, got %v and %v", r.n, r.err)
+			}
+		})
+	}
+}
+
+func TestFollowerRange(t *testing.T) {
+	h := NewWatcherHarness(t, "follower-range-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "abcdefghij")
+
+	c := Config{
+		Path:           h.Path(),
+		Interval:       time.Millisecond * 10,
+		Offset:         2,
+		StopAtPosition: 6,
+	}
+
+	f, err := NewFollower(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil || string(b) != "cdef" {
+		t.Fatalf("expected %q, got %q and %v", "cdef", b, err)
+	}
+
+	dst, err := os.Create(filepath.Join(t.TempDir(), "copy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	f, err = NewFollower(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if n, err := io.Copy(dst, f); err != nil || n != 4 {
+		t.Fatalf("expected 4 bytes copied without an error, got %v and %v", n, err)
+	}
+	if b, _ := ioutil.ReadFile(dst.Name()); string(b) != "cdef" {
+		t.Fatalf("expected %q to be copied, got %q", "cdef", b)
+	}
+}
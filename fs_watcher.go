@@ -0,0 +1,360 @@
+//go:build go1.16
+// +build go1.16
+
+package tail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// IdentityFS is an fs.FS that can tell its files apart like inodes do, so
+// a Watcher from NewFSWatcher notices when the name it follows is given
+// to a different file. Without it, the identity is only known when the
+// FileInfo's Sys is a stat struct, as it is for os.DirFS on unix, and
+// otherwise a file is only considered new when it gets smaller or its
+// modification time goes backwards.
+type IdentityFS interface {
+	fs.FS
+
+	// Identity returns the device and inode, or their equivalents, of
+	// the file named name that info describes. An inode of 0 means it
+	// isn't known.
+	Identity(name string, info fs.FileInfo) (device, inode uint64, err error)
+}
+
+// NewFileStateFromFS is NewFileStateFromPath for the file name in fsys.
+func NewFileStateFromFS(fsys fs.FS, name string) (*FileState, error) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := fsFileState(fsys, name, info)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// fsFileState returns the state of the file name in fsys that info
+// describes, without a position.
+func fsFileState(fsys fs.FS, name string, info fs.FileInfo) (FileState, error) {
+	device, inode := infoIdentity(info)
+	if ifs, ok := fsys.(IdentityFS); ok {
+		var err error
+		if device, inode, err = ifs.Identity(name, info); err != nil {
+			return FileState{}, err
+		}
+	}
+
+	var state FileState
+	state.readInfo(info, device, inode)
+	state.BirthTime = infoBirthTime(info)
+	return state, nil
+}
+
+// fsWatcher polls a file in an fs.FS.
+type fsWatcher struct {
+	fsys fs.FS
+	c    Config
+
+	cancel    chan struct{}
+	closeOnce sync.Once
+
+	// mu guards the open file, which is read by fsReader.
+	mu    sync.Mutex
+	f     fs.File
+	state FileState
+	pos   int64
+	// gen is incremented for each file opened, so readers of an older
+	// one stop.
+	gen       int
+	started   bool
+	truncated bool
+	closed    bool
+}
+
+// NewFSWatcher configures a Watcher that polls the file at c.Path in
+// fsys every Interval, which should be a slash-separated path as fs.FS
+// expects, for use with NewLineReaderFromWatcher. This allows following
+// files from sources other than the operating system, such as an
+// fstest.MapFS in tests. Of the options for the polling Watcher, it
+// supports Whence, Offset, StartState, StartMismatch, Truncation, SameFile
+// and Clock.
+//
+// Files are read through the WaitStatus Reader, and File is always nil.
+// A file that can't seek is read up to where reading starts instead.
+// Since fs.FS can't tell a deleted file from a renamed one, it never
+// returns a Removed event.
+func NewFSWatcher(fsys fs.FS, c Config) (Watcher, error) {
+	if !fs.ValidPath(c.Path) {
+		return nil, fmt.Errorf("config value for path is not a valid fs.FS path: %q", c.Path)
+	}
+
+	if c.Interval < 0 {
+		return nil, errors.New("config value for interval cannot be negative")
+	} else if c.Interval == 0 {
+		c.Interval = time.Second
+	}
+
+	if c.Whence != io.SeekStart && c.Whence != io.SeekEnd {
+		return nil, fmt.Errorf("config value for %w: %v", ErrBadWhence, c.Whence)
+	}
+
+	if err := c.checkOffset(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c.TailLines != 0:
+		return nil, errors.New("config value for tail lines is not supported with an fs.FS")
+	case c.FingerprintBytes != 0:
+		return nil, errors.New("config value for fingerprint bytes is not supported with an fs.FS")
+	case c.FollowSymlinks:
+		return nil, errors.New("config value for follow symlinks is not supported with an fs.FS")
+	case c.Backfill != nil || c.LazyBackfill:
+		return nil, errors.New("config value for backfill is not supported with an fs.FS")
+	case c.Scheduler != nil:
+		return nil, errors.New("config value for scheduler is not supported with an fs.FS")
+	}
+
+	if c.SameFile == nil {
+		c.SameFile = FileState.sameFile
+	}
+
+	return &fsWatcher{
+		fsys:   fsys,
+		c:      c,
+		cancel: make(chan struct{}),
+	}, nil
+}
+
+func (w *fsWatcher) Wait() (WaitStatus, bool, error) {
+	return w.WaitContext(context.Background())
+}
+
+func (w *fsWatcher) WaitContext(ctx context.Context) (WaitStatus, bool, error) {
+	for {
+		s, ok, err := w.poll()
+		if w.isClosed() {
+			return WaitStatus{}, true, nil
+		}
+		if ok || err != nil {
+			return s, false, err
+		}
+
+		timer := w.c.clock().NewTimer(w.c.Interval)
+		select {
+		case <-w.cancel:
+			timer.Stop()
+			return WaitStatus{}, true, nil
+		case <-ctx.Done():
+			timer.Stop()
+			return WaitStatus{}, false, ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// poll checks the open file for more data, or the path for a new file,
+// returning false if there is nothing to report yet.
+func (w *fsWatcher) poll() (WaitStatus, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return WaitStatus{}, false, nil
+	}
+
+	if w.f == nil {
+		event := Created
+		if w.started {
+			event = Rotated
+		}
+		return w.open(event)
+	}
+
+	info, err := w.f.Stat()
+	if err != nil {
+		return WaitStatus{}, false, err
+	}
+	w.state.Size = info.Size()
+	w.state.ModTime = info.ModTime()
+
+	if w.state.Size < w.pos {
+		if w.c.Truncation == TruncateError && !w.truncated {
+			w.truncated = true
+			return w.status(DataAvailable), false, &TruncatedError{
+				Path:     w.c.Path,
+				Size:     w.state.Size,
+				Position: w.pos,
+			}
+		}
+		w.truncated = false
+
+		w.f.Close()
+		w.f = nil
+		if w.c.Truncation == TruncateSeekEnd {
+			w.c.Whence = io.SeekEnd
+		}
+		return w.open(Truncated)
+	}
+
+	if w.state.Size > w.pos {
+		return w.status(DataAvailable), true, nil
+	}
+
+	named, err := NewFileStateFromFS(w.fsys, w.c.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return WaitStatus{}, false, nil
+	} else if err != nil {
+		return WaitStatus{}, false, err
+	}
+
+	if w.c.SameFile(w.state, *named) {
+		return WaitStatus{}, false, nil
+	}
+
+	// The open file was read to its end and there is a new one.
+	w.f.Close()
+	w.f = nil
+	return w.open(Rotated)
+}
+
+// open opens the file at the path and moves to where reading starts,
+// with mu held.
+func (w *fsWatcher) open(event Event) (WaitStatus, bool, error) {
+	f, err := w.fsys.Open(w.c.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Anything created later is read from the start.
+		w.c.Whence = io.SeekStart
+		w.c.Offset = 0
+		w.c.StartState = nil
+		return WaitStatus{}, false, nil
+	} else if err != nil {
+		return WaitStatus{}, false, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return WaitStatus{}, false, err
+	}
+
+	state, err := fsFileState(w.fsys, w.c.Path, info)
+	if err != nil {
+		f.Close()
+		return WaitStatus{}, false, err
+	}
+
+	var start int64
+	if s := w.c.StartState; s != nil && s.sameIdentity(state) && s.Position <= state.Size {
+		start = s.Position
+	} else if s != nil {
+		if start, err = w.c.mismatchStart(state); err != nil {
+			f.Close()
+			// It's only returned once, and the file is then read from
+			// the start.
+			w.c.StartState = nil
+			w.c.Whence = io.SeekStart
+			w.c.Offset = 0
+			return WaitStatus{}, false, err
+		}
+	} else {
+		start = w.c.startOffset(state.Size)
+	}
+
+	if err := skip(f, start); err != nil {
+		f.Close()
+		return WaitStatus{}, false, fmt.Errorf("skipping to %v in %s: %w", start, w.c.Path, err)
+	}
+
+	w.c.Whence = io.SeekStart
+	w.c.Offset = 0
+	w.c.StartState = nil
+	w.f = f
+	w.state = state
+	w.pos = start
+	w.gen++
+	w.started = true
+
+	s := w.status(event)
+	s.ReOpened = true
+	s.Truncated = event == Truncated
+	return s, true, nil
+}
+
+// skip moves f to offset, by seeking if it can or reading up to it
+// otherwise.
+func skip(f fs.File, offset int64) error {
+	if offset == 0 {
+		return nil
+	}
+	if seeker, ok := f.(io.Seeker); ok {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+	_, err := io.CopyN(ioutil.Discard, f, offset)
+	return err
+}
+
+// status returns a WaitStatus for the open file, with mu held.
+func (w *fsWatcher) status(event Event) WaitStatus {
+	state := w.state
+	state.Position = w.pos
+	return WaitStatus{
+		State:  state,
+		Reader: &fsReader{w: w, gen: w.gen},
+		Event:  event,
+	}
+}
+
+func (w *fsWatcher) isClosed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+func (w *fsWatcher) Close() error {
+	w.closeOnce.Do(func() { close(w.cancel) })
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.f != nil {
+		err := w.f.Close()
+		w.f = nil
+		return err
+	}
+	return nil
+}
+
+// fsReader reads from a file of an fsWatcher, keeping track of how far
+// it was read.
+type fsReader struct {
+	w   *fsWatcher
+	gen int
+}
+
+func (r *fsReader) Read(b []byte) (int, error) {
+	r.w.mu.Lock()
+	defer r.w.mu.Unlock()
+
+	// The file was closed for a newer one, so there's nothing more
+	// to read from it.
+	if r.w.gen != r.gen || r.w.f == nil {
+		return 0, io.EOF
+	}
+
+	n, err := r.w.f.Read(b)
+	r.w.pos += int64(n)
+	return n, err
+}
@@ -0,0 +1,93 @@
+//go:build go1.16
+// +build go1.16
+
+package tail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFSWatcher(t *testing.T) {
+	const name = "logs/app.log"
+	fsys := fstest.MapFS{name: {Data: []byte("a\nb\n")}}
+
+	w, err := NewFSWatcher(fsys, Config{Path: name, Interval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewLineReaderFromWatcher(w, Config{Path: name}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	defer r.Close()
+
+	readLine(t, r, "a")
+	readLine(t, r, "b")
+
+	fsys[name].Data = append(fsys[name].Data, "c\n"...)
+	readLine(t, r, "c")
+
+	// Without an identity, a smaller file at the name is a new one.
+	fsys[name] = &fstest.MapFile{Data: []byte("d\ne\n")}
+	readLine(t, r, "d")
+	readLine(t, r, "e")
+	if line := r.Line(); line.Generation != 2 || line.State.Position != 4 {
+		t.Fatalf("expected to be at 4 in the second file, got %+v", line)
+	}
+
+	fsys[name].Data = []byte("f\n")
+	readLine(t, r, "f")
+	if line := r.Line(); line.Generation != 3 || line.Offset != 0 {
+		t.Fatalf("expected to start over after truncating, got %+v", line)
+	}
+
+	// Resuming from a state skips what was read.
+	state := r.FileState()
+	fsys[name].Data = append(fsys[name].Data, "g\n"...)
+
+	w2, err := NewFSWatcher(fsys, Config{Path: name, StartState: &state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2 := NewLineReaderFromWatcher(w2, Config{Path: name}, nil)
+	defer r2.Close()
+	readLine(t, r2, "g")
+
+	if _, err := NewFSWatcher(fsys, Config{Path: "/" + name}); err == nil {
+		t.Fatal("expected an absolute path to fail")
+	}
+}
+
+func TestNewFileStateFromFS(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "js" || runtime.GOOS == "wasip1" {
+		t.Skip("the identity is only in the stat info on unix")
+	}
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.log"), []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := NewFileStateFromPath(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := NewFileStateFromFS(os.DirFS(dir), "app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "linux" {
+		// It's only known from statx, which fs.FS doesn't use.
+		expected.BirthTime = time.Time{}
+	}
+	if *state != *expected {
+		t.Fatalf("expected %v, got %v", expected, state)
+	}
+}
@@ -1,5 +1,21 @@
 module github.com/jacobcase/gotail
 
-go 1.15
+go 1.21
 
-require golang.org/x/sys v0.0.0-20210124154548-22da62e12c0c
+require (
+	github.com/prometheus/client_golang v1.11.1
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/sys v0.30.0
+	golang.org/x/text v0.3.7
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/golang/protobuf v1.4.3 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.26.0 // indirect
+	github.com/prometheus/procfs v0.6.0 // indirect
+	google.golang.org/protobuf v1.26.0-rc.1 // indirect
+)
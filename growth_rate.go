@@ -0,0 +1,78 @@
+package tail
+
+import (
+	"math"
+	"time"
+)
+
+// growthRateWindow is the time constant of the growth rate average. Older
+// samples lose about two thirds of their weight every window.
+const growthRateWindow = 30 * time.Second
+
+// growthRate keeps an exponentially weighted moving average of how fast
+// a file is growing, in bytes per second, from samples of its size.
+type growthRate struct {
+	rate float64
+
+	id     FileID
+	size   int64
+	at     time.Time
+	primed bool
+}
+
+// sample updates the average with the size of the file in state at now.
+// When the file changed, it's assumed the new file started out empty.
+func (g *growthRate) sample(state FileState, now time.Time) {
+	if g.at.IsZero() {
+		g.id, g.size, g.at = state.ID(), state.Size, now
+		return
+	}
+
+	dt := now.Sub(g.at).Seconds()
+	if dt <= 0 {
+		return
+	}
+
+	delta := state.Size - g.size
+	if state.ID() != g.id || delta < 0 {
+		delta = state.Size
+	}
+
+	instant := float64(delta) / dt
+	if !g.primed {
+		g.rate = instant
+		g.primed = true
+	} else {
+		weight := 1 - math.Exp(-dt/growthRateWindow.Seconds())
+		g.rate += weight * (instant - g.rate)
+	}
+
+	g.id, g.size, g.at = state.ID(), state.Size, now
+}
+
+// GrowthRate samples the open file and returns an estimate of how fast
+// it's being written to, in bytes per second.
+func (p *pollWatcher) GrowthRate() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.f != nil {
+		if state, err := NewFileState(p.f); err == nil {
+			p.growth.sample(state, p.c.clock().Now())
+		}
+	}
+	return p.growth.rate
+}
+
+// GrowthRate returns an exponentially weighted estimate of how fast the
+// file is being written to, in bytes per second, weighting roughly the
+// last 30 seconds the most. The file is sampled every time it's polled
+// and every time this is called, so calling it periodically keeps it
+// accurate even when the reader is behind. It returns 0 if the Watcher
+// doesn't support estimating it.
+func (l *LineReader) GrowthRate() float64 {
+	if g, ok := l.r.(interface{ GrowthRate() float64 }); ok {
+		return g.GrowthRate()
+	}
+	return 0
+}
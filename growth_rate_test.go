@@ -0,0 +1,32 @@
+package tail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGrowthRate(t *testing.T) {
+
+	var g growthRate
+	now := time.Now()
+
+	// A steady 100 bytes per second.
+	for i := 0; i <= 10; i++ {
+		g.sample(FileState{Inode: 1, Size: int64(i * 100)}, now.Add(time.Duration(i)*time.Second))
+	}
+	if g.rate < 99 || g.rate > 101 {
+		t.Fatalf("expected a rate of 100, got %v", g.rate)
+	}
+
+	// Rotating to a new file that already has 50 bytes after a second.
+	g.sample(FileState{Inode: 2, Size: 50}, now.Add(11*time.Second))
+	if g.rate >= 100 || g.rate <= 50 {
+		t.Fatalf("expected the rate to drop a little after rotating, got %v", g.rate)
+	}
+
+	// Stopping for a long time should decay towards 0.
+	g.sample(FileState{Inode: 2, Size: 50}, now.Add(5*time.Minute))
+	if g.rate > 1 {
+		t.Fatalf("expected the rate to decay when idle, got %v", g.rate)
+	}
+}
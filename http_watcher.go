@@ -0,0 +1,381 @@
+package tail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ ContextWatcher = (*httpWatcher)(nil)
+
+// httpWindow is how many of the last bytes read are kept to check if the
+// content changed when the ETag does.
+const httpWindow = 64
+
+type httpWatcher struct {
+	c      Config
+	client *http.Client
+
+	// pos is the offset of the next byte to read, updated by httpBody.
+	pos  int64
+	etag string
+	// window holds up to httpWindow bytes read right before pos.
+	window []byte
+	opened bool
+	// started is set once anything was opened, so opening again after
+	// the content changed is a rotation.
+	started bool
+	// requested is set after the first request, which isn't waited for.
+	requested bool
+
+	body io.ReadCloser
+	// reader is returned in every WaitStatus, reading from body, since
+	// readers only switch to a new Reader when ReOpened is set.
+	reader *httpBody
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	closed bool
+
+	mu sync.Mutex
+}
+
+// NewHTTPWatcher configures a Watcher that follows a file served over HTTP
+// at the URL in c.Path, such as by a simple file server. Every Interval, it
+// requests the bytes after what has been read with a Range request,
+// starting right away. The server must support Range requests for anything
+// but the start of the file.
+//
+// If the ETag changes, the last bytes read are requested again, and if they
+// differ it's treated as a rotation and reading starts over at the start
+// of the new content. The same happens if the content gets smaller than
+// what has been read. Anything written to the old content that wasn't read
+// is lost, since it's no longer served.
+//
+// WaitStatus.File is always nil, so Reader must be used, and the FileState
+// only has the Size and Position. Content that doesn't exist (404) is
+// waited for. Whence is supported, but not Offset or StartState.
+// If client is nil, http.DefaultClient is used.
+func NewHTTPWatcher(c Config, client *http.Client) (Watcher, error) {
+	if !(c.Whence == io.SeekStart ||
+		c.Whence == io.SeekCurrent ||
+		c.Whence == io.SeekEnd) {
+		return nil, fmt.Errorf("config value for %w: %v", ErrBadWhence, c.Whence)
+	}
+
+	if c.Offset != 0 {
+		return nil, errors.New("config value for offset is not supported over HTTP")
+	}
+
+	if c.Interval < 0 {
+		return nil, errors.New("config value for interval cannot be negative")
+	} else if c.Interval == 0 {
+		c.Interval = time.Second
+	}
+
+	if c.Path == "" {
+		return nil, errors.New("config value for path cannot be empty")
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &httpWatcher{
+		c:      c,
+		client: client,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+func (h *httpWatcher) Wait() (s WaitStatus, closed bool, err error) {
+	return h.WaitContext(context.Background())
+}
+
+// WaitContext is Wait, but returns ctx.Err() if ctx is done before
+// there is more data to read. A request in progress is only interrupted
+// by Close.
+func (h *httpWatcher) WaitContext(ctx context.Context) (s WaitStatus, closed bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.body != nil {
+		h.body.Close()
+		h.body = nil
+	}
+
+	for {
+		if h.requested {
+			timer := h.c.clock().NewTimer(h.c.Interval)
+			h.mu.Unlock()
+			select {
+			case <-h.ctx.Done():
+			case <-ctx.Done():
+			case <-timer.C():
+			}
+			timer.Stop()
+			h.mu.Lock()
+		}
+		h.requested = true
+
+		if h.closed {
+			return s, true, nil
+		}
+
+		if ctx.Err() != nil {
+			return s, false, ctx.Err()
+		}
+
+		// Whence only applies to the content first seen, and is ignored
+		// if it doesn't exist yet, like with files.
+		if !h.opened && h.c.Whence == io.SeekEnd {
+			size, etag, missing, err := h.head()
+			if err != nil {
+				return s, h.ctx.Err() != nil, h.interrupted(err)
+			}
+
+			if !missing && size > 0 {
+				h.pos, h.etag = size, etag
+			}
+			h.c.Whence = io.SeekStart
+		}
+
+		resp, size, etag, missing, err := h.get(h.pos, -1)
+		if err != nil {
+			return s, h.ctx.Err() != nil, h.interrupted(err)
+		}
+
+		// The content was replaced if it got smaller, was removed, or the
+		// ETag changed along with the last bytes read.
+		changed := h.opened && size >= 0 && size < h.pos
+		if !changed && h.opened && etag != h.etag {
+			same, err := h.sameContent()
+			if err != nil {
+				if resp != nil {
+					resp.Body.Close()
+				}
+				return s, h.ctx.Err() != nil, h.interrupted(err)
+			}
+			changed = !same
+		}
+
+		if changed || (h.opened && missing) {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			h.reset()
+			continue
+		}
+
+		h.etag = etag
+		if resp == nil {
+			continue
+		}
+
+		if !h.opened {
+			s.Event = Created
+			if h.started {
+				s.Event = Rotated
+			}
+		}
+		s.ReOpened = s.Event.reopened()
+		h.opened = true
+		h.started = true
+		h.body = resp.Body
+
+		if s.ReOpened || h.reader == nil {
+			h.reader = &httpBody{h: h}
+		}
+		h.reader.r = resp.Body
+
+		// Without a known size, all that's known is it's at least what
+		// was read.
+		if size < h.pos {
+			size = h.pos
+		}
+
+		s.Reader = h.reader
+		s.State = FileState{Size: size, Position: h.pos}
+		return s, false, nil
+	}
+}
+
+// interrupted returns nil instead of err if the request failed because
+// the Watcher was closed.
+func (h *httpWatcher) interrupted(err error) error {
+	if h.ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// reset starts over at the beginning of the content, which is then
+// considered to be a new file.
+func (h *httpWatcher) reset() {
+	h.pos = 0
+	h.window = nil
+	h.opened = false
+	h.c.Whence = io.SeekStart
+}
+
+// sameContent checks if the bytes right before pos are still the last
+// bytes that were read.
+func (h *httpWatcher) sameContent() (bool, error) {
+	if len(h.window) == 0 {
+		return true, nil
+	}
+
+	resp, _, _, _, err := h.get(h.pos-int64(len(h.window)), h.pos-1)
+	if err != nil || resp == nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(len(h.window))))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(b, h.window), nil
+}
+
+// head returns the size of the content, or -1 if it's unknown, and its
+// ETag. Missing is set if it doesn't exist (404).
+func (h *httpWatcher) head() (size int64, etag string, missing bool, err error) {
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodHead, h.c.Path, nil)
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, "", false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return -1, "", true, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", false, fmt.Errorf("unexpected status %v from %v", resp.Status, h.c.Path)
+	}
+	return resp.ContentLength, resp.Header.Get("ETag"), false, nil
+}
+
+// get requests the bytes from start to end inclusive, or to the end of the
+// content if end is negative. It returns a nil response if there is
+// nothing in that range, along with the size of the content, or -1 if
+// it's unknown, and the ETag. Missing is set if it doesn't exist (404),
+// which is different from an unknown size, such as from a chunked
+// response or a Content-Range ending in /*.
+func (h *httpWatcher) get(start, end int64) (resp *http.Response, size int64, etag string, missing bool, err error) {
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodGet, h.c.Path, nil)
+	if err != nil {
+		return nil, 0, "", false, err
+	}
+
+	if end < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-", start))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", start, end))
+	}
+
+	resp, err = h.client.Do(req)
+	if err != nil {
+		return nil, 0, "", false, err
+	}
+	etag = resp.Header.Get("ETag")
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp, contentRangeSize(resp.Header.Get("Content-Range")), etag, false, nil
+
+	case http.StatusOK:
+		// The server ignored the range, so skip what was already read.
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, start); err != nil {
+			resp.Body.Close()
+			if err == io.EOF {
+				return nil, resp.ContentLength, etag, false, nil
+			}
+			return nil, 0, "", false, err
+		}
+
+		if resp.ContentLength >= 0 && resp.ContentLength == start {
+			resp.Body.Close()
+			return nil, resp.ContentLength, etag, false, nil
+		}
+		return resp, resp.ContentLength, etag, false, nil
+
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, -1, "", true, nil
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		return nil, contentRangeSize(resp.Header.Get("Content-Range")), etag, false, nil
+
+	default:
+		resp.Body.Close()
+		return nil, 0, "", false, fmt.Errorf("unexpected status %v from %v", resp.Status, h.c.Path)
+	}
+}
+
+// contentRangeSize parses the complete length from a Content-Range header
+// like "bytes 0-9/10" or "bytes */10", returning -1 if it's unknown.
+func contentRangeSize(v string) int64 {
+	i := strings.LastIndexByte(v, '/')
+	if i < 0 {
+		return -1
+	}
+
+	size, err := strconv.ParseInt(v[i+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+func (h *httpWatcher) Close() error {
+	// WaitContext holds mu during requests, so they're interrupted first.
+	h.cancel()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+
+	if h.body != nil {
+		err := h.body.Close()
+		h.body = nil
+		return err
+	}
+	return nil
+}
+
+// httpBody tracks the position and last bytes read from a response.
+type httpBody struct {
+	h *httpWatcher
+	r io.Reader
+}
+
+func (b *httpBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+
+	h := b.h
+	h.pos += int64(n)
+	h.window = append(h.window, p[:n]...)
+	if len(h.window) > httpWindow {
+		h.window = append(h.window[:0], h.window[len(h.window)-httpWindow:]...)
+	}
+	return n, err
+}
@@ -0,0 +1,245 @@
+package tail
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpFile serves content that can be appended to or replaced.
+type httpFile struct {
+	content    []byte
+	generation int
+	mu         sync.Mutex
+}
+
+func (f *httpFile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	content := append([]byte(nil), f.content...)
+	etag := fmt.Sprintf(`"%v-%v"`, f.generation, len(content))
+	f.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "log", time.Time{}, bytes.NewReader(content))
+}
+
+func (f *httpFile) append(s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.content = append(f.content, s...)
+}
+
+func (f *httpFile) replace(s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.content = []byte(s)
+	f.generation++
+}
+
+func TestHTTPWatcher(t *testing.T) {
+
+	file := &httpFile{content: []byte("a\nb\n")}
+	server := httptest.NewServer(file)
+	defer server.Close()
+
+	c := Config{
+		Path:     server.URL,
+		Interval: time.Millisecond * 10,
+	}
+
+	w, err := NewHTTPWatcher(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewLineReaderFromWatcher(w, c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	defer r.Close()
+
+	readLine(t, r, "a")
+	readLine(t, r, "b")
+
+	// The ETag changes with every append, but the content before
+	// doesn't, so it should keep reading from the same position.
+	file.append("c\n")
+	readLine(t, r, "c")
+
+	if pos := r.FileState().Position; pos != 6 {
+		t.Fatalf("expected position 6, got %v", pos)
+	}
+
+	// Replacing it with content of the same size should be noticed.
+	file.replace("x\ny\nz\n")
+	readLine(t, r, "x")
+
+	if pos := r.FileState().Position; pos != 2 {
+		t.Fatalf("expected position 2 after rotating, got %v", pos)
+	}
+}
+
+func TestHTTPWatcherClock(t *testing.T) {
+
+	file := &httpFile{content: []byte("a\n")}
+	server := httptest.NewServer(file)
+	defer server.Close()
+
+	clock := NewManualClock(time.Now())
+	w, err := NewHTTPWatcher(Config{
+		Path:     server.URL,
+		Interval: time.Hour,
+		Clock:    clock,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// The first request doesn't wait for Interval.
+	s, closed, err := w.Wait()
+	if err != nil || closed || !s.ReOpened {
+		t.Fatalf("expected the content to be opened, got %v, %v, %v", s.ReOpened, closed, err)
+	}
+	if b, err := ioutil.ReadAll(s.Reader); err != nil || string(b) != "a\n" {
+		t.Fatalf("expected a, got %q, %v", b, err)
+	}
+
+	file.append("b\n")
+	done := make(chan WaitStatus, 1)
+	go func() {
+		s, _, err := w.Wait()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- s
+	}()
+
+	clock.BlockUntil(1)
+	select {
+	case <-done:
+		t.Fatal("expected to wait for Interval")
+	default:
+	}
+
+	clock.Advance(time.Hour)
+	select {
+	case s = <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for more content")
+	}
+	if b, err := ioutil.ReadAll(s.Reader); err != nil || string(b) != "b\n" {
+		t.Fatalf("expected b, got %q, %v", b, err)
+	}
+}
+
+// unsizedFile serves content without saying how big it is, either as a
+// chunked response that ignores Range, or as a range ending in /*.
+type unsizedFile struct {
+	httpFile
+	ranges bool
+}
+
+func (f *unsizedFile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	content := append([]byte(nil), f.content...)
+	f.mu.Unlock()
+
+	var start int
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start); !f.ranges || err != nil {
+		// Flushing before writing makes the response chunked.
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		w.Write(content)
+		return
+	}
+
+	if start >= len(content) {
+		w.Header().Set("Content-Range", "bytes */*")
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %v-%v/*", start, len(content)-1))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(content[start:])
+}
+
+func TestHTTPWatcherUnknownSize(t *testing.T) {
+
+	for name, ranges := range map[string]bool{"chunked": false, "unknown range": true} {
+		t.Run(name, func(t *testing.T) {
+			file := &unsizedFile{httpFile: httpFile{content: []byte("a\nb\n")}, ranges: ranges}
+			server := httptest.NewServer(file)
+			defer server.Close()
+
+			c := Config{
+				Path:     server.URL,
+				Interval: time.Millisecond * 10,
+			}
+
+			w, err := NewHTTPWatcher(c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := NewLineReaderFromWatcher(w, c, func(e error) error {
+				t.Fatal(e)
+				return e
+			})
+			defer r.Close()
+
+			readLine(t, r, "a")
+			readLine(t, r, "b")
+			file.append("c\n")
+			readLine(t, r, "c")
+
+			// Not knowing the size isn't mistaken for the content
+			// being removed, which would read it all again.
+			if ok, err := r.NextTimeout(time.Millisecond * 100); ok || err != ErrTimeout {
+				t.Fatalf("expected a timeout, got %q and %v", r.Bytes(), err)
+			}
+		})
+	}
+}
+
+func TestHTTPWatcherCloseInterruptsRequest(t *testing.T) {
+
+	stall := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stall:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(stall)
+
+	w, err := NewHTTPWatcher(Config{Path: server.URL, Interval: time.Millisecond * 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		_, closed, _ := w.Wait()
+		done <- closed
+	}()
+
+	time.Sleep(time.Millisecond * 50)
+	closeErr := make(chan error)
+	go func() { closeErr <- w.Close() }()
+
+	select {
+	case <-closeErr:
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't interrupt the request")
+	}
+	if closed := <-done; !closed {
+		t.Fatal("expected Wait to return closed")
+	}
+}
@@ -0,0 +1,249 @@
+//go:build linux
+// +build linux
+
+package tail
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// inotifyDirMask is watched on the directory to see the path
+	// being created or replaced.
+	inotifyDirMask = unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE
+
+	// inotifyFileMask is watched on every file the path refers to, so
+	// writes are seen even after it's rotated.
+	inotifyFileMask = unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_MOVE_SELF | unix.IN_DELETE_SELF
+)
+
+// inotifyWatcher is a pollWatcher that polls as soon as inotify reports
+// a change instead of waiting for the next Interval.
+type inotifyWatcher struct {
+	*pollWatcher
+
+	// f reads events from fd. Calling f.Fd would make it blocking.
+	f     *os.File
+	fd    int
+	dirWd int
+	base  string
+
+	// files are the watches on the file at the path and the ones it
+	// replaced that are still being read, guarded by mu.
+	files map[int]bool
+	mu    sync.Mutex
+}
+
+// NewInotifyWatcher configures a Watcher like NewPollingWatcher, but uses
+// inotify to check for more data as soon as the file is written to,
+// renamed or removed, or the path is created. Interval is still used as
+// a fallback, such as for PartialLineGrace. If inotify can't be used,
+// such as when the limit on instances or watches is reached, it falls
+// back to only polling.
+func NewInotifyWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+	return startInotify(p), nil
+}
+
+// NewAutoWatcher is like NewInotifyWatcher, but only polls if the path is
+// on a filesystem where inotify doesn't see every change, such as writes
+// from other machines to NFS or SMB shares, or to files under FUSE and
+// overlayfs mounts. Its NotifyStatus method reports which was chosen.
+func NewAutoWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason := unreliableNotify(filepath.Dir(c.Path)); reason != "" {
+		p.notify.Reason = reason
+		p.start()
+		return p, nil
+	}
+	return startInotify(p), nil
+}
+
+// startInotify starts polling with p, using inotify if it can.
+func startInotify(p *pollWatcher) Watcher {
+	w, err := newInotifyWatcher(p)
+	if err != nil {
+		p.notify.Reason = fmt.Sprintf("inotify failed: %v", err)
+		p.start()
+		return p
+	}
+
+	p.notify.Notify = true
+	go w.watch()
+	p.start()
+	return w
+}
+
+// Magic numbers of the filesystems missing from unix.
+const (
+	cifsSuperMagic = 0xff534d42
+	smb2SuperMagic = 0xfe534d42
+	fuseSuperMagic = 0x65735546
+)
+
+// unreliableNotify returns why inotify can't be relied on in dir, or an
+// empty string if it can. It's assumed to work if the filesystem can't
+// be checked.
+func unreliableNotify(dir string) string {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(dir, &fs); err != nil {
+		return ""
+	}
+
+	switch uint32(fs.Type) {
+	case unix.NFS_SUPER_MAGIC:
+		return "nfs filesystem"
+	case unix.SMB_SUPER_MAGIC, cifsSuperMagic, smb2SuperMagic:
+		return "smb filesystem"
+	case fuseSuperMagic:
+		return "fuse filesystem"
+	case unix.OVERLAYFS_SUPER_MAGIC:
+		return "overlay filesystem"
+	}
+	return ""
+}
+
+func newInotifyWatcher(p *pollWatcher) (*inotifyWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &inotifyWatcher{
+		pollWatcher: p,
+		// A non-blocking descriptor uses the runtime poller, so Close
+		// interrupts a Read in progress.
+		f:     os.NewFile(uintptr(fd), "inotify"),
+		fd:    fd,
+		base:  filepath.Base(p.c.Path),
+		files: make(map[int]bool),
+	}
+
+	w.dirWd, err = unix.InotifyAddWatch(fd, filepath.Dir(p.c.Path), inotifyDirMask)
+	if err != nil {
+		w.f.Close()
+		return nil, err
+	}
+
+	p.wake = make(chan struct{}, 1)
+	p.onOpen = w.opened
+	w.watchFile()
+	return w, nil
+}
+
+// watchFile adds a watch for the file currently at the path, returning
+// its descriptor. Watches for older files are kept until the next file
+// is opened, since they're still read from after a rotation until then.
+func (w *inotifyWatcher) watchFile() (int, error) {
+	// Errors are ignored by callers, since the file may not exist yet
+	// and polling will still notice changes if there are too many
+	// watches.
+	wd, err := unix.InotifyAddWatch(w.fd, w.c.Path, inotifyFileMask)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[wd] = true
+	return wd, nil
+}
+
+// opened removes the watches for the files before the one just opened,
+// since they're finished with.
+func (w *inotifyWatcher) opened(state FileState) {
+	wd, err := w.watchFile()
+	if err != nil {
+		return
+	}
+
+	// If the path was already replaced again, the watch is for a file
+	// that hasn't been opened yet, and the open one is still read.
+	named, err := NewFileStateFromPath(w.c.Path)
+	if err != nil || !named.ID().Equal(state.ID()) {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for old := range w.files {
+		if old != wd {
+			unix.InotifyRmWatch(w.fd, uint32(old))
+			delete(w.files, old)
+		}
+	}
+}
+
+func (w *inotifyWatcher) watch() {
+	buf := make([]byte, 16*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		wake := false
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			e := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(e.Len)]
+			name = bytes.TrimRight(name, "\x00")
+			off += unix.SizeofInotifyEvent + int(e.Len)
+
+			if e.Mask&unix.IN_Q_OVERFLOW != 0 {
+				wake = true
+				continue
+			}
+
+			// The file was removed, so its watch is already gone.
+			if e.Mask&unix.IN_IGNORED != 0 {
+				w.mu.Lock()
+				delete(w.files, int(e.Wd))
+				w.mu.Unlock()
+				continue
+			}
+
+			if int(e.Wd) != w.dirWd {
+				wake = wake || e.Mask&inotifyFileMask != 0
+				continue
+			}
+
+			if string(name) != w.base {
+				continue
+			}
+
+			if e.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				w.watchFile()
+			}
+			wake = true
+		}
+
+		if wake {
+			select {
+			case w.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (w *inotifyWatcher) Close() error {
+	err := w.pollWatcher.Close()
+	if e := w.f.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
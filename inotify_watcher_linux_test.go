@@ -0,0 +1,97 @@
+//go:build linux
+// +build linux
+
+package tail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInotifyWatcher(t *testing.T) {
+
+	h := NewWatcherHarness(t, "inotify")
+
+	// Polling would never notice anything within the test.
+	r, err := NewInotifyWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	w, ok := r.(*inotifyWatcher)
+	if !ok {
+		t.Skip("inotify isn't available")
+	}
+
+	// A missed change would otherwise leave the harness waiting forever.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		writer := h.Create()
+		writeString(t, writer, "foo")
+
+		reader := h.Wait(r, true, false, nil)
+		expectString(t, reader, "foo")
+
+		writeString(t, writer, "bar")
+		h.Wait(r, false, false, nil)
+		expectString(t, reader, "bar")
+		writer.Close()
+
+		h.Rotate()
+		writer = h.Create()
+		writeString(t, writer, "baz")
+		writer.Close()
+
+		reader = h.Wait(r, true, false, nil)
+		expectString(t, reader, "baz")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("inotify watcher didn't notice a change")
+	}
+
+	// The rotated file is finished with once the new one is opened.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.files) != 1 {
+		t.Fatalf("expected only the open file to be watched, got %d watches", len(w.files))
+	}
+}
+
+func TestAutoWatcher(t *testing.T) {
+
+	h := NewWatcherHarness(t, "auto")
+
+	r, err := NewAutoWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	status := r.(interface{ NotifyStatus() NotifyStatus }).NotifyStatus()
+	_, inotify := r.(*inotifyWatcher)
+	if status.Notify != inotify {
+		t.Fatalf("expected notify to be %v, got %+v", inotify, status)
+	}
+	if !status.Notify && status.Reason == "" {
+		t.Fatal("expected a reason for polling")
+	}
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "foo")
+
+	reader := h.Wait(r, true, false, nil)
+	expectString(t, reader, "foo")
+}
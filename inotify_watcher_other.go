@@ -0,0 +1,23 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !solaris && !aix
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd,!solaris,!aix
+
+package tail
+
+// NewInotifyWatcher is the same as NewPollingWatcher, since there are no
+// filesystem notifications on this platform.
+func NewInotifyWatcher(c Config) (Watcher, error) {
+	return NewAutoWatcher(c)
+}
+
+// NewAutoWatcher is the same as NewPollingWatcher, since there are no
+// filesystem notifications on this platform.
+func NewAutoWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+
+	p.notify.Reason = "notifications are only available on linux, darwin, the bsds, solaris, illumos and aix"
+	p.start()
+	return p, nil
+}
@@ -0,0 +1,45 @@
+package tail
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// NextJSON blocks until a line is available and decodes it into v with
+// json.Unmarshal, for files with a JSON document on each line, returning
+// false like Next. Blank lines are skipped, and lines that can't be
+// decoded are handled according to Config.MalformedJSON. Fields of v may
+// be set by a line that failed to decode part way.
+func (l *LineReader) NextJSON(v interface{}) bool {
+	for l.Next() {
+		if len(bytes.TrimSpace(l.lastBytes)) == 0 {
+			continue
+		}
+
+		l.jsonErr = json.Unmarshal(l.lastBytes, v)
+		if l.jsonErr == nil {
+			return true
+		}
+
+		switch l.c.MalformedJSON {
+		case MalformedRaw:
+			return true
+		case MalformedError:
+			malformed := &MalformedJSONError{
+				Path:     l.c.Path,
+				Position: l.Line().Offset,
+				Err:      l.jsonErr,
+			}
+			if l.err = l.handle("decode", malformed); l.err != nil {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// JSONErr returns the error decoding the line last returned by NextJSON,
+// which is only set with MalformedRaw.
+func (l *LineReader) JSONErr() error {
+	return l.jsonErr
+}
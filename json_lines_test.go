@@ -0,0 +1,61 @@
+package tail
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLineReaderNextJSON(t *testing.T) {
+
+	type record struct {
+		N int `json:"n"`
+	}
+
+	for _, policy := range []MalformedPolicy{MalformedSkip, MalformedRaw, MalformedError} {
+		h := NewWatcherHarness(t, "line-reader-json-test")
+		writer := h.Create()
+		writeString(t, writer, "{\"n\":1}\n\nnot json\n{\"n\":2}\n")
+		writer.Close()
+
+		var handled error
+		r, err := NewLineReader(Config{
+			Path:          h.Path(),
+			Interval:      time.Millisecond * 10,
+			StopAtEOF:     true,
+			MalformedJSON: policy,
+		}, func(e error) error {
+			handled = e
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rec record
+		if !r.NextJSON(&rec) || rec.N != 1 || r.JSONErr() != nil {
+			t.Fatalf("policy %v: expected 1, got %v, %v", policy, rec.N, r.JSONErr())
+		}
+
+		if policy == MalformedRaw {
+			if !r.NextJSON(&rec) || r.JSONErr() == nil || string(r.Bytes()) != "not json" {
+				t.Fatalf("expected the raw line, got %q, %v", r.Bytes(), r.JSONErr())
+			}
+		}
+
+		if !r.NextJSON(&rec) || rec.N != 2 {
+			t.Fatalf("policy %v: expected 2, got %v", policy, rec.N)
+		}
+
+		var malformed *MalformedJSONError
+		if policy == MalformedError {
+			if !errors.As(handled, &malformed) || malformed.Position != 9 || !errors.Is(handled, ErrMalformedJSON) {
+				t.Fatalf("expected a malformed json error at 9, got %v", handled)
+			}
+		} else if errors.Is(handled, ErrMalformedJSON) {
+			t.Fatalf("policy %v: unexpected error %v", policy, handled)
+		}
+
+		r.Close()
+	}
+}
@@ -0,0 +1,145 @@
+package tail
+
+import (
+	"bytes"
+)
+
+// JSONRecordReader reads JSON documents that may span many lines, such as
+// pretty printed objects, from a LineReader. It tracks the depth of braces
+// and brackets, ignoring those in strings, and returns a record once a
+// line brings it back to zero. Lines that are blank between documents are
+// skipped, and other lines that aren't part of a document are returned as
+// their own record.
+type JSONRecordReader struct {
+	l   *LineReader
+	max int
+
+	record []byte
+	state  FileState
+	err    error
+
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+// NewJSONRecordReader returns a JSONRecordReader reading lines from l. If
+// maxSize is greater than 0 and a record grows larger than it, Next returns
+// false and Err returns ErrRecordTooLarge. Calling Next again skips the
+// rest of that record and continues with the next one.
+func NewJSONRecordReader(l *LineReader, maxSize int) *JSONRecordReader {
+	return &JSONRecordReader{
+		l:     l,
+		max:   maxSize,
+		state: l.FileState(),
+	}
+}
+
+// Next blocks until a complete document is available and returns true,
+// or returns false if the LineReader stopped or a record was too large.
+func (r *JSONRecordReader) Next() bool {
+	if r.err == ErrRecordTooLarge {
+		r.err = nil
+		if !r.skip() {
+			return false
+		}
+	}
+
+	r.record = r.record[:0]
+	r.depth = 0
+	r.inString = false
+	r.escaped = false
+
+	for {
+		if r.err != nil || !r.l.Next() {
+			return false
+		}
+
+		line := r.l.Bytes()
+		if len(r.record) == 0 && len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if len(r.record) > 0 {
+			r.record = append(r.record, '\n')
+		}
+		r.record = append(r.record, line...)
+		r.scan(line)
+
+		if r.max > 0 && len(r.record) > r.max {
+			r.err = ErrRecordTooLarge
+			r.record = r.record[:0]
+			return false
+		}
+
+		if r.depth <= 0 {
+			r.state = r.l.FileState()
+			return true
+		}
+	}
+}
+
+// skip reads the lines left in a record that was too large, without
+// keeping them.
+func (r *JSONRecordReader) skip() bool {
+	for r.depth > 0 {
+		if !r.l.Next() {
+			return false
+		}
+		r.scan(r.l.Bytes())
+	}
+
+	r.state = r.l.FileState()
+	return true
+}
+
+func (r *JSONRecordReader) scan(line []byte) {
+	for _, c := range line {
+		if r.inString {
+			switch {
+			case r.escaped:
+				r.escaped = false
+			case c == '\\':
+				r.escaped = true
+			case c == '"':
+				r.inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			r.inString = true
+		case '{', '[':
+			r.depth++
+		case '}', ']':
+			r.depth--
+		}
+	}
+}
+
+// Bytes returns the last record, with the lines of it joined by \n. It is
+// only valid until the next call to Next.
+func (r *JSONRecordReader) Bytes() []byte {
+	return r.record
+}
+
+// Err returns the error that caused Next to return false, either from
+// the LineReader or ErrRecordTooLarge.
+func (r *JSONRecordReader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.l.Err()
+}
+
+// FileState returns the state as of the end of the last record, which
+// unlike the LineReader's is never in the middle of one.
+func (r *JSONRecordReader) FileState() FileState {
+	return r.state
+}
+
+// Close closes the underlying LineReader.
+func (r *JSONRecordReader) Close() error {
+	return r.l.Close()
+}
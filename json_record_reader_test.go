@@ -0,0 +1,102 @@
+package tail
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONRecordReader(t *testing.T) {
+
+	h := NewWatcherHarness(t, "json-record-reader-test")
+
+	writer := h.Create()
+	writeString(t, writer, `{"a": 1}
+
+{
+  "b": [1, 2],
+  "c": "}\" ["
+}
+not json
+[
+  {}
+]
+`)
+	writer.Close()
+
+	l, err := NewLineReader(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		StopAtEOF: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewJSONRecordReader(l, 0)
+	defer r.Close()
+
+	var records []string
+	for r.Next() {
+		records = append(records, string(r.Bytes()))
+	}
+
+	if r.Err() != io.EOF {
+		t.Fatalf("unexpected error: %v", r.Err())
+	}
+
+	expected := []string{
+		`{"a": 1}`,
+		"{\n  \"b\": [1, 2],\n  \"c\": \"}\\\" [\"\n}",
+		"not json",
+		"[\n  {}\n]",
+	}
+	if !reflect.DeepEqual(expected, records) {
+		t.Fatalf("expected %q, got %q", expected, records)
+	}
+}
+
+func TestJSONRecordReaderTooLarge(t *testing.T) {
+
+	h := NewWatcherHarness(t, "json-record-reader-too-large-test")
+
+	writer := h.Create()
+	writeString(t, writer, "{\n\"a\": 1\n}\n{\n\"aaaaaaaaaa\": 1\n}\n{\n\"b\": 2\n}\n")
+	writer.Close()
+
+	l, err := NewLineReader(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		StopAtEOF: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewJSONRecordReader(l, 12)
+	defer r.Close()
+
+	if !r.Next() {
+		t.Fatalf("expected a record, got %v", r.Err())
+	}
+
+	if pos := r.FileState().Position; pos != 11 {
+		t.Fatalf("expected position 11 after the first record, got %v", pos)
+	}
+
+	if r.Next() || r.Err() != ErrRecordTooLarge {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", r.Err())
+	}
+
+	// The rest of the large record is skipped, like a long line.
+	if !r.Next() {
+		t.Fatalf("expected the record after the large one, got %v", r.Err())
+	}
+	if record := string(r.Bytes()); record != "{\n\"b\": 2\n}" {
+		t.Fatalf("expected the third record, got %q", record)
+	}
+	if pos := r.FileState().Position; pos != 42 {
+		t.Fatalf("expected position 42 after the third record, got %v", pos)
+	}
+}
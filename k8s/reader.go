@@ -0,0 +1,190 @@
+// Package k8s reads container logs in the CRI format that containerd and
+// CRI-O write for Kubernetes, where each line is
+//
+//	<timestamp> <stream> <tag> <message>
+//
+// and a long message is split into partial lines tagged P, with the last
+// part tagged F.
+package k8s
+
+import (
+	"bytes"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+// Streams a record can be from.
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// ContainerConfig returns a tail.Config for the log at path, which follows
+// it through the symlinks in /var/log/containers as the container is
+// restarted and its log is rotated.
+func ContainerConfig(path string) tail.Config {
+	return tail.Config{
+		Path:           path,
+		FollowSymlinks: true,
+	}
+}
+
+// Record is a message from a container.
+type Record struct {
+	// Time is when the runtime received the message, or when its last
+	// part was received if it was split.
+	Time time.Time
+	// Stream is Stdout or Stderr, or empty if the line wasn't in the CRI
+	// format, in which case Message is the whole line.
+	Stream string
+	// Message is the message with its parts joined, without a trailing
+	// newline.
+	Message []byte
+}
+
+// Reader reads Records from a LineReader, joining the partial lines of
+// each stream back into a whole message.
+type Reader struct {
+	l   *tail.LineReader
+	max int
+
+	rec Record
+	err error
+
+	// pending is the parts of the message so far for each stream.
+	pending map[string][]byte
+	state   tail.FileState
+}
+
+// NewReader returns a Reader reading lines from l. If maxSize is greater
+// than 0 and a message grows larger than it, Next returns false and Err
+// returns tail.ErrRecordTooLarge.
+func NewReader(l *tail.LineReader, maxSize int) *Reader {
+	return &Reader{
+		l:       l,
+		max:     maxSize,
+		pending: make(map[string][]byte),
+		state:   l.FileState(),
+	}
+}
+
+// Next blocks until a complete message is available and returns true, or
+// returns false if the LineReader stopped or a message was too large.
+func (r *Reader) Next() bool {
+	for {
+		if r.err != nil || !r.l.Next() {
+			return false
+		}
+
+		rec, partial, ok := parseLine(r.l.Bytes())
+		if !ok {
+			r.rec = Record{Message: append(r.rec.Message[:0], r.l.Bytes()...)}
+			r.updateState()
+			return true
+		}
+
+		msg := append(r.pending[rec.Stream], rec.Message...)
+		if r.max > 0 && len(msg) > r.max {
+			r.err = tail.ErrRecordTooLarge
+			return false
+		}
+
+		if partial {
+			r.pending[rec.Stream] = msg
+			continue
+		}
+
+		// The pending slice is kept to reuse for the next message.
+		r.pending[rec.Stream] = msg[:0]
+		r.rec = Record{
+			Time:    rec.Time,
+			Stream:  rec.Stream,
+			Message: append(r.rec.Message[:0], msg...),
+		}
+		r.updateState()
+		return true
+	}
+}
+
+// updateState saves the LineReader's state unless a stream has a partial
+// message, which would be lost by resuming after it.
+func (r *Reader) updateState() {
+	for _, msg := range r.pending {
+		if len(msg) > 0 {
+			return
+		}
+	}
+	r.state = r.l.FileState()
+}
+
+// parseLine splits a CRI log line, returning false if it isn't one.
+// Message in the Record is part of line.
+func parseLine(line []byte) (rec Record, partial bool, ok bool) {
+	fields := bytes.SplitN(line, []byte{' '}, 4)
+	if len(fields) < 3 {
+		return rec, false, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, string(fields[0]))
+	if err != nil {
+		return rec, false, false
+	}
+
+	switch string(fields[1]) {
+	case Stdout:
+		rec.Stream = Stdout
+	case Stderr:
+		rec.Stream = Stderr
+	default:
+		return rec, false, false
+	}
+
+	// The tag can have more flags after the first, separated by colons.
+	tag := fields[2]
+	if i := bytes.IndexByte(tag, ':'); i >= 0 {
+		tag = tag[:i]
+	}
+
+	switch string(tag) {
+	case "P":
+		partial = true
+	case "F":
+	default:
+		return rec, false, false
+	}
+
+	rec.Time = t
+	if len(fields) == 4 {
+		rec.Message = fields[3]
+	}
+	return rec, partial, true
+}
+
+// Record returns the last message, which is only valid until the next
+// call to Next.
+func (r *Reader) Record() Record {
+	return r.rec
+}
+
+// Err returns the error that caused Next to return false, either from
+// the LineReader or tail.ErrRecordTooLarge.
+func (r *Reader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.l.Err()
+}
+
+// FileState returns the state as of the end of the last message. While
+// another stream has part of a message read, it stays before that part,
+// so resuming from it can return messages from the other stream again
+// rather than losing part of one.
+func (r *Reader) FileState() tail.FileState {
+	return r.state
+}
+
+// Close closes the underlying LineReader.
+func (r *Reader) Close() error {
+	return r.l.Close()
+}
@@ -0,0 +1,89 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+func TestReader(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "container.log")
+
+	lines := "2016-10-06T00:17:09.669794202Z stdout F first\n" +
+		"2016-10-06T00:17:09.669794203Z stdout P sec\n" +
+		"2016-10-06T00:17:09.669794204Z stderr F oops\n" +
+		"2016-10-06T00:17:09.669794205Z stdout F ond\n" +
+		"not a cri line\n" +
+		"2016-10-06T00:17:10Z stderr F\n"
+	if err := ioutil.WriteFile(p, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ContainerConfig(p)
+	c.Interval = time.Millisecond * 10
+	c.StopAtEOF = true
+	l, err := tail.NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(l, 0)
+	defer r.Close()
+
+	expected := []struct {
+		stream string
+		msg    string
+		pos    int64
+	}{
+		{Stdout, "first", 46},
+		// The partial stdout message isn't resumed after.
+		{Stderr, "oops", 46},
+		{Stdout, "second", 179},
+		{"", "not a cri line", 194},
+		{Stderr, "", 224},
+	}
+
+	for _, e := range expected {
+		if !r.Next() {
+			t.Fatalf("expected %q, got %v", e.msg, r.Err())
+		}
+
+		rec := r.Record()
+		if rec.Stream != e.stream || string(rec.Message) != e.msg {
+			t.Fatalf("expected %v %q, got %v %q", e.stream, e.msg, rec.Stream, rec.Message)
+		}
+		if pos := r.FileState().Position; pos != e.pos {
+			t.Fatalf("expected position %v after %q, got %v", e.pos, e.msg, pos)
+		}
+	}
+
+	if r.Next() {
+		t.Fatalf("expected no more records, got %q", r.Record().Message)
+	}
+}
+
+func TestReaderTooLarge(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "container.log")
+
+	lines := "2016-10-06T00:17:09Z stdout P aaaa\n" +
+		"2016-10-06T00:17:09Z stdout P bbbb\n" +
+		"2016-10-06T00:17:09Z stdout F cccc\n"
+	if err := ioutil.WriteFile(p, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := tail.NewLineReader(tail.Config{Path: p, Interval: time.Millisecond * 10, StopAtEOF: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(l, 10)
+	defer r.Close()
+
+	if r.Next() || r.Err() != tail.ErrRecordTooLarge {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", r.Err())
+	}
+}
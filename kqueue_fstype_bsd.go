@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd
+// +build darwin dragonfly freebsd
+
+package tail
+
+import "golang.org/x/sys/unix"
+
+// fsTypeName returns the name of the type of filesystem dir is on.
+func fsTypeName(dir string) (string, error) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(dir, &fs); err != nil {
+		return "", err
+	}
+	return unix.ByteSliceToString(fs.Fstypename[:]), nil
+}
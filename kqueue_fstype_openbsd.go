@@ -0,0 +1,23 @@
+//go:build openbsd
+// +build openbsd
+
+package tail
+
+import "golang.org/x/sys/unix"
+
+// fsTypeName returns the name of the type of filesystem dir is on.
+func fsTypeName(dir string) (string, error) {
+	var fs unix.Statfs_t
+	if err := unix.Statfs(dir, &fs); err != nil {
+		return "", err
+	}
+
+	name := make([]byte, 0, len(fs.F_fstypename))
+	for _, c := range fs.F_fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return string(name), nil
+}
@@ -0,0 +1,284 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package tail
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// kqueueDirNotes is watched on the directory, which is written to
+	// when the path is created, replaced or removed.
+	kqueueDirNotes = unix.NOTE_WRITE
+
+	// kqueueFileNotes is watched on every file the path refers to, so
+	// writes are seen even after it's rotated.
+	kqueueFileNotes = unix.NOTE_WRITE | unix.NOTE_EXTEND | unix.NOTE_ATTRIB | unix.NOTE_RENAME | unix.NOTE_DELETE
+)
+
+// kqueueWatcher is a pollWatcher that polls as soon as kqueue reports a
+// change instead of waiting for the next Interval.
+type kqueueWatcher struct {
+	*pollWatcher
+
+	kq  int
+	dir int
+
+	// stop is a pipe whose read end is watched along with the files,
+	// so closing the write end interrupts kevent. done is closed once
+	// watch has released every descriptor.
+	stop [2]int
+	done chan struct{}
+
+	// files are the descriptors watched for the file at the path and
+	// the ones it replaced that are still being read, by identity. They
+	// are guarded by mu, and nil once released.
+	files map[FileID]int
+	mu    sync.Mutex
+}
+
+// NewInotifyWatcher configures a Watcher like NewPollingWatcher, but uses
+// kqueue, the closest to inotify on the BSDs and darwin, to check for
+// more data as soon as the file is written to, renamed or removed, or
+// the path is created. Interval is still used as a fallback, such as for
+// PartialLineGrace. If kqueue can't be used, such as when the limit on
+// open files is reached, it falls back to only polling.
+func NewInotifyWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+	return startKqueue(p), nil
+}
+
+// NewAutoWatcher is like NewInotifyWatcher, but only polls if the path is
+// on a filesystem where kqueue doesn't see every change, such as writes
+// from other machines to NFS or SMB shares, or to files under FUSE
+// mounts. Its NotifyStatus method reports which was chosen.
+func NewAutoWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if reason := unreliableNotify(filepath.Dir(c.Path)); reason != "" {
+		p.notify.Reason = reason
+		p.start()
+		return p, nil
+	}
+	return startKqueue(p), nil
+}
+
+// startKqueue starts polling with p, using kqueue if it can.
+func startKqueue(p *pollWatcher) Watcher {
+	w, err := newKqueueWatcher(p)
+	if err != nil {
+		p.notify.Reason = fmt.Sprintf("kqueue failed: %v", err)
+		p.start()
+		return p
+	}
+
+	p.notify.Notify = true
+	go w.watch()
+	p.start()
+	return w
+}
+
+// unreliableNotify returns why kqueue can't be relied on in dir, or an
+// empty string if it can. It's assumed to work if the filesystem can't
+// be checked.
+func unreliableNotify(dir string) string {
+	name, err := fsTypeName(dir)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case name == "nfs":
+		return "nfs filesystem"
+	case name == "smbfs":
+		return "smb filesystem"
+	case strings.Contains(name, "fuse") || name == "puffs":
+		return "fuse filesystem"
+	}
+	return ""
+}
+
+func newKqueueWatcher(p *pollWatcher) (*kqueueWatcher, error) {
+	kq, err := unix.Kqueue()
+	if err != nil {
+		return nil, err
+	}
+	unix.CloseOnExec(kq)
+
+	w := &kqueueWatcher{
+		pollWatcher: p,
+		kq:          kq,
+		dir:         -1,
+		stop:        [2]int{-1, -1},
+		done:        make(chan struct{}),
+		files:       make(map[FileID]int),
+	}
+
+	if err = unix.Pipe(w.stop[:]); err != nil {
+		w.release()
+		return nil, err
+	}
+	unix.CloseOnExec(w.stop[0])
+	unix.CloseOnExec(w.stop[1])
+
+	if err = w.add(w.stop[0], unix.EVFILT_READ, 0); err != nil {
+		w.release()
+		return nil, err
+	}
+
+	w.dir, err = unix.Open(filepath.Dir(p.c.Path), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		w.release()
+		return nil, err
+	}
+	if err = w.add(w.dir, unix.EVFILT_VNODE, kqueueDirNotes); err != nil {
+		w.release()
+		return nil, err
+	}
+
+	p.wake = make(chan struct{}, 1)
+	p.onOpen = w.opened
+	w.watchFile()
+	return w, nil
+}
+
+// add registers fd with the kqueue for filter and its fflags.
+func (w *kqueueWatcher) add(fd, filter int, fflags uint32) error {
+	var e unix.Kevent_t
+	unix.SetKevent(&e, fd, filter, unix.EV_ADD|unix.EV_CLEAR)
+	e.Fflags = fflags
+	_, err := unix.Kevent(w.kq, []unix.Kevent_t{e}, nil, nil)
+	return err
+}
+
+// watchFile adds a watch for the file currently at the path, unless it's
+// already watched, returning its identity. Watches for older files are
+// kept until the next file is opened, since they're still read from
+// after a rotation until then.
+func (w *kqueueWatcher) watchFile() (FileID, error) {
+	// Errors are ignored by callers, since the file may not exist yet
+	// and polling will still notice changes if there are too many
+	// open files.
+	fd, err := unix.Open(w.c.Path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return FileID{}, err
+	}
+
+	var stat unix.Stat_t
+	if err = unix.Fstat(fd, &stat); err != nil {
+		unix.Close(fd)
+		return FileID{}, err
+	}
+	id := FileID{device: uint64(stat.Dev), inode: uint64(stat.Ino)}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.files[id]; ok || w.files == nil {
+		unix.Close(fd)
+		return id, nil
+	}
+
+	if err = w.add(fd, unix.EVFILT_VNODE, kqueueFileNotes); err != nil {
+		unix.Close(fd)
+		return FileID{}, err
+	}
+	w.files[id] = fd
+	return id, nil
+}
+
+// opened removes the watches for the files before the one just opened,
+// since they're finished with. The file at the path is kept as well, in
+// case it was already replaced again.
+func (w *kqueueWatcher) opened(state FileState) {
+	named, _ := w.watchFile()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.files[state.ID()]; !ok {
+		return
+	}
+
+	for id, fd := range w.files {
+		if id != state.ID() && id != named {
+			unix.Close(fd)
+			delete(w.files, id)
+		}
+	}
+}
+
+func (w *kqueueWatcher) watch() {
+	defer close(w.done)
+	defer w.release()
+
+	events := make([]unix.Kevent_t, 16)
+	for {
+		n, err := unix.Kevent(w.kq, nil, events, nil)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return
+		}
+
+		for _, e := range events[:n] {
+			switch int(e.Ident) {
+			case w.stop[0]:
+				return
+			case w.dir:
+				w.watchFile()
+			}
+		}
+
+		if n > 0 {
+			select {
+			case w.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// release closes every descriptor the watcher opened.
+func (w *kqueueWatcher) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, fd := range w.files {
+		unix.Close(fd)
+	}
+	w.files = nil
+
+	for _, fd := range []int{w.dir, w.stop[0], w.stop[1], w.kq} {
+		if fd >= 0 {
+			unix.Close(fd)
+		}
+	}
+	w.dir, w.stop, w.kq = -1, [2]int{-1, -1}, -1
+}
+
+func (w *kqueueWatcher) Close() error {
+	err := w.pollWatcher.Close()
+
+	// Closing the write end makes the read end readable, which stops
+	// watch, and it's the only one using the descriptors by now.
+	w.mu.Lock()
+	if w.stop[1] >= 0 {
+		unix.Close(w.stop[1])
+		w.stop[1] = -1
+	}
+	w.mu.Unlock()
+
+	<-w.done
+	return err
+}
@@ -0,0 +1,43 @@
+package tail
+
+import "os"
+
+// Lag returns how many bytes the LineReader is behind the file at the
+// path, from the end of the last line returned. If the file being read
+// was rotated, that's what's left of it plus the size of the file that
+// replaced it, though any files rotated in between aren't counted. A
+// line still incomplete from a previous file is counted from the start
+// of the file being read. It's 0 before the first file is opened, and is
+// safe to call in parallel to Next.
+func (l *LineReader) Lag() (int64, error) {
+	l.mu.Lock()
+	state := l.read
+	f := l.file
+	l.mu.Unlock()
+
+	if f == nil {
+		return 0, nil
+	}
+
+	open, err := NewFileState(f)
+	if err != nil {
+		return 0, err
+	}
+
+	lag := open.Size
+	if open.ID().Equal(state.ID()) {
+		lag -= state.Position
+	}
+
+	named, err := NewFileStateFromPath(l.c.Path)
+	if os.IsNotExist(err) {
+		return lag, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	if !l.c.SameFile(open, *named) {
+		lag += named.Size
+	}
+	return lag, nil
+}
@@ -0,0 +1,52 @@
+package tail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineReaderLag(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-lag-test")
+	writer := h.Create()
+	writeString(t, writer, "a\nbc\n")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	expectLag := func(expect int64) {
+		t.Helper()
+		lag, err := r.Lag()
+		if err != nil {
+			t.Fatal(err)
+		} else if lag != expect {
+			t.Fatalf("expected a lag of %v, got %v", expect, lag)
+		}
+	}
+
+	expectLag(0)
+	readLine(t, r, "a")
+	expectLag(3)
+
+	// What's left of the rotated file is added to the new one.
+	writer.Close()
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "de\n")
+	expectLag(6)
+
+	readLine(t, r, "bc")
+	expectLag(3)
+	readLine(t, r, "de")
+	expectLag(0)
+}
@@ -0,0 +1,99 @@
+package tail
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// reverseBlockSize is how much is read at a time when scanning backwards.
+const reverseBlockSize = 4096
+
+// lastLinesOffset scans backwards from size in r and returns the offset of
+// the start of the last n lines ending with delim. A delim at size
+// terminates the last line rather than starting an empty one, and a final
+// line without one is still counted, matching how tail -n behaves.
+func lastLinesOffset(r io.ReaderAt, size int64, n int, delim []byte) (int64, error) {
+	if n <= 0 || size == 0 {
+		return size, nil
+	}
+
+	// Each block is read with the start of the one after it, so a delim
+	// split between them is still found.
+	overlap := len(delim) - 1
+	buf := make([]byte, reverseBlockSize+overlap)
+	end := size
+	// Skip the terminator of the last line, if it has one.
+	skip := true
+
+	for end > 0 {
+		start := end - reverseBlockSize
+		if start < 0 {
+			start = 0
+		}
+
+		readEnd := end + int64(overlap)
+		if readEnd > size {
+			readEnd = size
+		}
+
+		block := buf[:readEnd-start]
+		if _, err := r.ReadAt(block, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		if skip {
+			skip = false
+			if bytes.HasSuffix(block, delim) {
+				block = block[:len(block)-len(delim)]
+			}
+		}
+
+		for i := bytes.LastIndex(block, delim); i >= 0; i = bytes.LastIndex(block, delim) {
+			// Delimiters starting in the overlap were already counted.
+			if start+int64(i) < end {
+				n--
+				if n == 0 {
+					return start + int64(i) + int64(len(delim)), nil
+				}
+			}
+			block = block[:i]
+		}
+
+		end = start
+	}
+
+	return 0, nil
+}
+
+// ReadLastLines returns up to the last n lines of the file at path, without
+// reading the whole file. Like LineReader, the \n or \r\n delimiters are
+// removed. A final line without a delimiter is included.
+func ReadLastLines(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := lastLinesOffset(f, stat.Size(), n, newline)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	s := bufio.NewScanner(io.NewSectionReader(f, offset, stat.Size()-offset))
+	// Lines can be arbitrarily long, so don't limit the scanner.
+	s.Buffer(nil, int(stat.Size()-offset)+1)
+	for s.Scan() {
+		lines = append(lines, strings.TrimSuffix(s.Text(), "\r"))
+	}
+	return lines, s.Err()
+}
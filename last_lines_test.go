@@ -0,0 +1,71 @@
+package tail
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLastLines(t *testing.T) {
+
+	long := strings.Repeat("x", reverseBlockSize*2)
+
+	tests := []struct {
+		name     string
+		contents string
+		n        int
+		expected []string
+	}{
+		{"empty", "", 3, []string{}},
+		{"fewer lines", "a\nb\n", 3, []string{"a", "b"}},
+		{"exact", "a\nb\nc\n", 2, []string{"b", "c"}},
+		{"no trailing newline", "a\nb\nc", 2, []string{"b", "c"}},
+		{"crlf", "a\r\nb\r\nc\r\n", 2, []string{"b", "c"}},
+		{"empty lines", "a\n\n\n", 2, []string{"", ""}},
+		{"across blocks", "a\n" + long + "\nb\n", 2, []string{long, "b"}},
+		{"zero", "a\n", 0, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewWatcherHarness(t, "last-lines")
+			writer := h.Create()
+			writeString(t, writer, test.contents)
+			writer.Close()
+
+			actual, err := ReadLastLines(h.Path(), test.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(test.expected, actual) {
+				t.Fatalf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLastLinesOffsetDelimiter(t *testing.T) {
+
+	// The delimiter after "a" is split between the two blocks.
+	contents := "a||b" + strings.Repeat("x", reverseBlockSize-2)
+	r := strings.NewReader(contents)
+
+	tests := []struct {
+		n        int
+		expected int64
+	}{
+		{1, 3},
+		{2, 0},
+	}
+
+	for _, test := range tests {
+		offset, err := lastLinesOffset(r, int64(len(contents)), test.n, []byte("||"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != test.expected {
+			t.Fatalf("expected offset %v for %v lines, got %v", test.expected, test.n, offset)
+		}
+	}
+}
@@ -3,28 +3,147 @@ package tail
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"sync"
 	"time"
 )
 
 // LineReader provides a way to transparently read
 // \n or \r\n delimited lines across multiple files.
-// The only method that is safe to call in parallel
-// to other methods is Close().
+// The only methods that are safe to call in parallel to other
+// methods are Close(), CloseAndState(), Shutdown(), FileState(),
+// StreamOffset() and DumpState().
 type LineReader struct {
 	onErr ErrorHandler
 	c     Config
 
+	// delim is the delimiter as it's written in the file, and text is
+	// set if it's in a different Encoding than UTF-8.
+	delim []byte
+	text  *textEncoding
+	// sniffing is set with AutoEncoding until the encoding of the open
+	// file is detected.
+	sniffing bool
+
 	r Watcher
 
 	s  WaitStatus
 	br *bufio.Reader
 
 	lastBytes []byte
+	// lineLen is the length of lastBytes before trimming the delimiter,
+	// including any bytes dropped from it.
+	lineLen int
+
+	// lineNumber is the number of the last line read in the current
+	// file, only meaningful if it was read from the start.
+	lineNumber int64
+	fromStart  bool
+
+	// resume is set when Next was interrupted by a context, so the
+	// next call continues the partial line in lastBytes.
+	resume bool
+
+	// dropped is how many bytes of the current line were dropped for
+	// being over MaxLineBytes.
+	dropped int
+
+	// lastRead is when bytes were last added to the current line, used
+	// for PartialLineTimeout.
+	lastRead time.Time
+	// unterminated is set if the line was returned after
+	// PartialLineTimeout without its delimiter.
+	unterminated bool
+
+	// generation is the number of files opened, and readTime is when
+	// the last line was returned. polled is when the Watcher last found
+	// more to read.
+	generation int64
+	readTime   time.Time
+	polled     time.Time
+
+	// finished are the last few files read before the open one, oldest
+	// first, so OnViolation can check that rotations only move forward.
+	finished []FileState
+
+	// jsonErr is the error decoding the last line from NextJSON.
+	jsonErr error
+
+	// failedOp is the operation of the last error, and retries is how
+	// many errors in a row there were from it before that one.
+	failedOp string
+	retries  int
+
+	// reuse is set when the last line was only copied out, by AppendLine
+	// or NextBatch, so its buffer can be reused for the next one.
+	reuse bool
+
+	// skipPartial discards the next line read, set when seeking
+	// to an offset that may be in the middle of a line.
+	skipPartial bool
+
+	// noWait is set by TryNext so next returns errWouldBlock instead
+	// of sleeping or waiting on the Watcher.
+	noWait bool
 
 	stop chan struct{}
 
 	err error
+
+	// running is held for the duration of Next so CloseAndState
+	// can wait for it to stop at a line boundary.
+	running sync.Mutex
+
+	// mu protects the fields below, which are read by FileState
+	// and CloseAndState while Next may be running.
+	mu sync.Mutex
+	// state is the FileState as of the end of the last line returned,
+	// or the last one committed with ManualCommit, and commitGen is the
+	// generation it's in.
+	state     FileState
+	commitGen int64
+	// read is the FileState as of the end of the last line returned,
+	// and readGen is the generation it's in. pending is set while a
+	// line returned since the last commit isn't committed.
+	read    FileState
+	readGen int64
+	pending bool
+	// file is the file being read, for Lag.
+	file *os.File
+	// partial is set if Next stopped with part of a line read.
+	partial bool
+	// offset is the total bytes of lines returned, including delimiters.
+	offset int64
+	// returned is how many lines were returned, for StopAfterLines and
+	// Stats, and latency is how long after polled they were.
+	returned int64
+	latency  Histogram
+	// draining is set by Shutdown so Next stops instead of waiting.
+	draining bool
+	// waiting is set while Next is blocked on the Watcher.
+	waiting bool
+	// buffered is how many bytes were read from the file but not
+	// returned yet as of the last call to Next.
+	buffered int
+	// events and errs are the recent history shown by DumpState.
+	events history
+	errs   history
+	// unsaved is set when state changed since it was last checkpointed.
+	unsaved bool
+
+	// saving is held while checkpointing, so saves happen in order.
+	saving   sync.Mutex
+	lastSave time.Time
+
+	// drained is closed once Next returns false for the first time.
+	drained   chan struct{}
+	drainOnce sync.Once
+
+	caughtUp chan struct{}
 }
 
 // NewLineReader returns a LineReader that has an underlying
@@ -33,53 +152,342 @@ type LineReader struct {
 // it will not be passed to the error handler. If h is nil,
 // errors will be ignored and will automatically retry.
 func NewLineReader(c Config, h ErrorHandler) (*LineReader, error) {
-	if h == nil {
-		h = DiscardErrorHandler
+	if c.BufferSize < 0 {
+		return nil, errors.New("config value for buffer size cannot be negative")
+	}
+
+	if c.ChecksumBytes < 0 {
+		return nil, errors.New("config value for checksum bytes cannot be negative")
+	}
+
+	if c.Checkpoint != nil {
+		if c.Checkpoint.Store == nil {
+			return nil, errors.New("config value for checkpoint store cannot be nil")
+		}
+
+		if c.StartState == nil {
+			state, err := c.Checkpoint.Store.Load(c.Path)
+			if err != nil {
+				return nil, err
+			}
+			c.StartState = state
+		}
 	}
 
-	r, err := NewPollingWatcher(c)
+	var r Watcher
+	var err error
+	if c.Backfill != nil {
+		r, err = newBackfillWatcher(c)
+	} else {
+		r, err = NewPollingWatcher(c)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &LineReader{
-		onErr: h,
-		r:     r,
-		c:     c,
-		stop:  make(chan struct{}),
-	}, nil
+	return NewLineReaderFromWatcher(r, c, h), nil
+}
+
+// NewLineReaderFromWatcher is like NewLineReader, but reads from the
+// provided Watcher instead of creating one, such as one from a
+// SharedWatcher. Path in c should still be the file w is watching.
+func NewLineReaderFromWatcher(r Watcher, c Config, h ErrorHandler) *LineReader {
+	if h == nil {
+		h = DiscardErrorHandler
+	}
+
+	if c.UnreadableInterval == 0 {
+		c.UnreadableInterval = time.Second
+	}
+
+	if c.SameFile == nil {
+		c.SameFile = FileState.sameFile
+	}
+
+	l := &LineReader{
+		onErr:    h,
+		r:        r,
+		c:        c,
+		delim:    c.textDelimiter(),
+		stop:     make(chan struct{}),
+		drained:  make(chan struct{}),
+		caughtUp: make(chan struct{}),
+		latency:  newHistogram(latencyBounds),
+	}
+
+	if c.Encoding != nil {
+		l.text = newTextEncoding(c.Encoding, l.delim)
+		l.delim = l.text.delim
+	}
+
+	if c.StartState != nil {
+		l.state = *c.StartState
+		l.read = *c.StartState
+	}
+	return l
 }
 
-func (l *LineReader) sleep(t time.Duration) bool {
+func (l *LineReader) sleep(ctx context.Context, t time.Duration) bool {
 	if t == 0 {
 		select {
 		case <-l.stop:
 			return false
+		case <-ctx.Done():
+			return false
 		default:
 			return true
 		}
 	}
 
+	// Use a timer instead of time.After so it's released right away
+	// if the sleep is interrupted by Close, instead of after t.
+	timer := l.c.clock().NewTimer(t)
+	defer timer.Stop()
+
 	select {
 	case <-l.stop:
 		return false
-	case <-time.After(t):
+	case <-ctx.Done():
+		return false
+	case <-timer.C():
 		return true
 	}
 }
 
+func (l *LineReader) wait(ctx context.Context) (s WaitStatus, closed bool, err error) {
+	if w, ok := l.r.(ContextWatcher); ok {
+		return w.WaitContext(ctx)
+	}
+	return l.r.Wait()
+}
+
+// Next blocks until a complete line is available and returns true, or
+// returns false if the LineReader was closed or stopped with an error.
 func (l *LineReader) Next() bool {
+	ok, _ := l.nextContext(context.Background())
+	return ok
+}
+
+// NextContext is like Next, but returns ctx.Err() if ctx is done before
+// a complete line is available. Any part of a line read so far is kept,
+// so the LineReader can continue to be used after it's cancelled. If the
+// Watcher isn't a ContextWatcher, cancelling only takes effect once Wait
+// returns. Otherwise, when it returns false the error is the same as Err().
+func (l *LineReader) NextContext(ctx context.Context) (bool, error) {
+	ok, err := l.nextContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, l.Err()
+	}
+	return true, nil
+}
+
+// NextTimeout is like NextContext, but returns ErrTimeout if no complete
+// line is available within d.
+func (l *LineReader) NextTimeout(d time.Duration) (bool, error) {
+	clock := l.c.clock()
+	ctx, cancel := withDeadline(context.Background(), clock, clock.Now().Add(d))
+	defer cancel()
+
+	ok, err := l.NextContext(ctx)
+	if err == context.DeadlineExceeded {
+		return false, ErrTimeout
+	}
+	return ok, err
+}
+
+// errWouldBlock is returned by next when noWait is set and a line
+// isn't available without waiting.
+var errWouldBlock = errors.New("would block")
+
+// TryNext is like Next, but returns right away instead of waiting for
+// more data. It reads as much of the open file as is needed for a line,
+// but doesn't wait on the Watcher, so a new file after a rotation is only
+// noticed by Next. When it returns false, more is true if Next may still
+// return lines, so it can be called after TryNext drains what's available
+// without losing part of a line, or false if Next would also return false.
+func (l *LineReader) TryNext() (ok bool, more bool) {
+	l.noWait = true
+	defer func() { l.noWait = false }()
+
+	ok, err := l.nextContext(context.Background())
+	if err == errWouldBlock {
+		return false, true
+	}
+	return ok, ok
+}
+
+// nextContext returns ctx.Err() if ctx is done before a line is read,
+// leaving the LineReader ready to continue the same line.
+func (l *LineReader) nextContext(ctx context.Context) (bool, error) {
+	l.running.Lock()
+	defer l.running.Unlock()
+
+	nextCtx := ctx
+	if !l.c.StopAt.IsZero() {
+		var cancel context.CancelFunc
+		nextCtx, cancel = withDeadline(ctx, l.c.clock(), l.c.StopAt)
+		defer cancel()
+	}
+
+	ok, err := l.next(nextCtx)
+	for err == nil && ok && l.c.Filter != nil && !l.c.Filter(l.lastBytes) {
+		// Resume after the line rather than read it again.
+		l.mu.Lock()
+		l.advance(l.s.State)
+		l.mu.Unlock()
+
+		ok, err = l.next(nextCtx)
+	}
+
+	if err != nil && ctx.Err() == nil && nextCtx.Err() != nil {
+		// Only StopAt was reached.
+		l.err = ErrStopped
+		ok, err = false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	if ok {
+		l.pending = l.c.ManualCommit
+		l.advance(l.s.State)
+		l.offset += int64(l.lineLen)
+		l.returned++
+		if !l.polled.IsZero() {
+			l.latency.observe(l.c.clock().Now().Sub(l.polled).Seconds())
+		}
+	}
+	l.partial = !ok && len(l.lastBytes) > 0
+	l.buffered = 0
+	if l.partial {
+		l.buffered = len(l.lastBytes)
+	}
+	if l.br != nil {
+		l.buffered += l.br.Buffered()
+	}
+	l.mu.Unlock()
+
+	if ok {
+		if l.stopAfter() {
+			l.err = ErrStopped
+		}
+		l.readTime = l.c.clock().Now()
+		l.succeeded()
+		l.handleCheckpoint(l.checkpoint(false))
+	} else {
+		l.drainOnce.Do(func() { close(l.drained) })
+	}
+	return ok, nil
+}
+
+// stopAfter reports whether a stop condition was reached by the line
+// that was just read.
+func (l *LineReader) stopAfter() bool {
+	return (l.c.StopAfterLines > 0 && l.returned >= l.c.StopAfterLines) ||
+		(l.c.StopAtPosition > 0 && l.s.State.Position >= l.c.StopAtPosition) ||
+		(l.c.StopWhen != nil && l.c.StopWhen(l.lastBytes))
+}
+
+// checkpoint saves the state to the Checkpoint store if it changed since
+// the last save, and it's been Interval since then or force is set.
+func (l *LineReader) checkpoint(force bool) error {
+	cp := l.c.Checkpoint
+	if cp == nil || cp.Store == nil {
+		return nil
+	}
+
+	l.saving.Lock()
+	defer l.saving.Unlock()
+
+	if !force && l.c.clock().Now().Sub(l.lastSave) < cp.Interval {
+		return nil
+	}
+
+	l.mu.Lock()
+	state, unsaved := l.state, l.unsaved
+	l.unsaved = false
+	l.mu.Unlock()
+
+	if !unsaved {
+		return nil
+	}
+
+	l.setChecksum(&state)
+	if err := cp.Store.Save(l.c.Path, state); err != nil {
+		l.mu.Lock()
+		l.unsaved = true
+		l.mu.Unlock()
+		return err
+	}
+
+	l.lastSave = l.c.clock().Now()
+	return nil
+}
+
+// handleCheckpoint passes an error from checkpoint to the ErrorHandler,
+// stopping the LineReader if it returns one.
+func (l *LineReader) handleCheckpoint(err error) {
+	if err == nil {
+		if l.failedOp == "checkpoint" {
+			l.failedOp = ""
+		}
+		return
+	}
+
+	l.err = l.handle("checkpoint", err)
+}
+
+// beginWait is called before blocking on the Watcher and returns false
+// if Shutdown was called, since all complete lines have been read.
+func (l *LineReader) beginWait() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.draining {
+		return false
+	}
+
+	l.waiting = true
+	return true
+}
+
+func (l *LineReader) next(ctx context.Context) (bool, error) {
 
 	var sleepTime time.Duration
+	delim := l.delim
 
-	l.lastBytes = nil
+	// Continue the line from a previous call that was interrupted.
+	if !l.resume {
+		l.resetLine()
+	}
+	l.resume = false
+	l.reuse = false
+
+	// The rest of a line returned after PartialLineTimeout has the
+	// same number.
+	continued := l.unterminated
+	l.unterminated = false
 
 	for {
 		var b []byte
 		var err error
 
-		if l.err != nil || !l.sleep(sleepTime) {
-			return false
+		if l.err != nil {
+			return false, nil
+		}
+
+		if l.noWait && sleepTime > 0 {
+			l.resume = true
+			return false, errWouldBlock
+		}
+
+		if !l.sleep(ctx, sleepTime) {
+			l.resume = true
+			return false, ctx.Err()
 		}
 
 		sleepTime = l.c.Interval
@@ -88,28 +496,67 @@ func (l *LineReader) Next() bool {
 			goto Wait
 		}
 
-		b, err = l.br.ReadBytes('\n')
+		if l.sniffing {
+			l.sniff()
+			delim = l.delim
+		}
+
+		b, err = l.br.ReadSlice(delim[len(delim)-1])
 		l.s.State.Position += int64(len(b))
+		if len(b) > 0 && l.c.PartialLineTimeout > 0 {
+			l.lastRead = l.c.clock().Now()
+		}
 
-		if len(b) > 0 {
-			// Avoid an allocation if lastBytes is nil.
-			if l.lastBytes != nil {
-				l.lastBytes = append(l.lastBytes, b...)
-			} else {
-				l.lastBytes = b
+		if l.appendLine(b, len(delim)) && l.c.LongLines == LongLineError {
+			tooLong := &LineTooLongError{
+				Path:     l.c.Path,
+				Position: l.s.State.Position - int64(len(l.lastBytes)+l.dropped),
+				Max:      l.c.MaxLineBytes,
+			}
+			if l.err = l.handle("read", tooLong); l.err != nil {
+				continue
 			}
 		}
 
+		if err == bufio.ErrBufferFull {
+			// The line is longer than the buffer, so keep reading.
+			sleepTime = 0
+			continue
+		}
+
+		// Only the last byte of the delimiter was found, so keep
+		// reading until the rest of it is before that. With an Encoding,
+		// it also has to start a character rather than be in the middle
+		// of others.
+		if err == nil && (!bytes.HasSuffix(l.lastBytes, delim) ||
+			(l.text != nil && !l.text.aligned(len(l.lastBytes)+l.dropped))) {
+			sleepTime = 0
+			continue
+		}
+
 		if err == nil {
+			if !continued {
+				l.lineNumber++
+			}
+			continued = false
+			if l.skipPartial || (l.dropped > 0 && l.c.LongLines != LongLineTruncate) {
+				l.skipPartial = false
+				l.resetLine()
+				sleepTime = 0
+				continue
+			}
 			break
 		}
 
 		if err != io.EOF {
-			l.err = l.onErr(err)
-			sleepTime = time.Second
+			l.err = l.handle(errorOp(err, "read"), err)
+			sleepTime = l.backoff(time.Second)
+			l.retrying(err, sleepTime)
 			continue
 		}
 
+		l.checkCaughtUp()
+
 		// The error was an EOF, so wait for more data.
 		if l.c.StopAtEOF {
 			l.err = err
@@ -117,35 +564,374 @@ func (l *LineReader) Next() bool {
 		}
 
 	Wait:
-		s, closed, err := l.r.Wait()
+		deadline, partial := l.partialDeadline()
+		if partial && !l.c.clock().Now().Before(deadline) {
+			if !continued {
+				l.lineNumber++
+			}
+			l.unterminated = true
+			break
+		}
+
+		if l.noWait {
+			l.mu.Lock()
+			draining := l.draining
+			l.mu.Unlock()
+
+			if draining {
+				return false, nil
+			}
+			l.resume = true
+			return false, errWouldBlock
+		}
+
+		// Caught up, so save the state before waiting.
+		l.handleCheckpoint(l.checkpoint(true))
+		if l.err != nil {
+			continue
+		}
+
+		if !l.beginWait() {
+			return false, nil
+		}
+
+		waitCtx, cancel := ctx, context.CancelFunc(nil)
+		if partial {
+			waitCtx, cancel = withDeadline(ctx, l.c.clock(), deadline)
+		}
+
+		s, closed, err := l.wait(waitCtx)
+		if cancel != nil {
+			cancel()
+		}
+
+		l.mu.Lock()
+		l.waiting = false
+		l.mu.Unlock()
+
 		if closed {
-			return false
+			return false, nil
+		}
+
+		if err != nil && err == ctx.Err() {
+			l.resume = true
+			return false, err
+		}
+
+		// Nothing was written before PartialLineTimeout, so the
+		// check above returns the partial line.
+		if err != nil && err == waitCtx.Err() {
+			sleepTime = 0
+			continue
+		}
+
+		if err == nil {
+			l.polled = l.c.clock().Now()
+			buffered := 0
+			if l.br != nil {
+				buffered = l.br.Buffered()
+			}
+			l.validate(l.s, buffered, s)
 		}
 
 		l.s = s
 
 		if err != nil {
-			l.err = l.onErr(err)
-			sleepTime = time.Second
+			l.err = l.handle(errorOp(err, "wait"), err)
+			if errors.Is(err, ErrUnreadable) {
+				sleepTime = l.backoff(l.c.UnreadableInterval)
+			} else {
+				sleepTime = l.backoff(time.Second)
+			}
+			l.retrying(err, sleepTime)
+			continue
+		}
+
+		// The Watcher already waited until there was more to read.
+		sleepTime = 0
+		l.succeeded()
+
+		if s.Event == Removed {
+			l.recordEvent("removed %v", s)
+		}
+
+		if s.Truncated {
+			// Part of a line from before it was truncated won't
+			// ever be completed.
+			l.resetLine()
+		}
+
+		if s.Event == Resumed && l.br != nil {
+			// It's the same file opened again, so only what's read
+			// from changes.
+			l.br.Reset(l.source(s))
+			l.mu.Lock()
+			l.file = s.File
+			l.mu.Unlock()
 			continue
 		}
 
 		if s.ReOpened {
-			l.br = bufio.NewReader(s.File)
+			l.recordEvent("opened %v", s)
+			l.generation++
+			if l.br == nil {
+				l.br = bufio.NewReaderSize(l.source(s), l.c.bufferSize())
+			} else {
+				l.br.Reset(l.source(s))
+			}
+			l.skipPartial = false
+			l.lineNumber = 0
+			l.fromStart = s.State.Position == 0
+			l.sniffing = l.c.Encoding == AutoEncoding
+
+			l.mu.Lock()
+			l.file = s.File
+			// Nothing is pending from the previous file, so the start
+			// of this one is the latest line boundary.
+			if len(l.lastBytes) == 0 {
+				l.advance(s.State)
+			}
+			l.mu.Unlock()
 			continue
 		}
 	}
 
-	// MUST have a \n suffix if it makes it to this point, so test \r.
-	trim := len(l.lastBytes) - 1
-	if bytes.HasSuffix(l.lastBytes, []byte{'\r', '\n'}) {
-		trim--
+	l.lineLen = len(l.lastBytes) + l.dropped
+
+	switch {
+	case l.dropped > 0:
+		l.lastBytes = l.lastBytes[:l.c.MaxLineBytes]
+	case l.unterminated:
+	default:
+		// MUST have the delimiter as a suffix if it makes it to this
+		// point, so only test \r with the default.
+		trim := len(l.lastBytes) - len(delim)
+		if len(l.c.Delimiter) == 0 && l.text == nil && bytes.HasSuffix(l.lastBytes, []byte{'\r', '\n'}) {
+			trim--
+		}
+		l.lastBytes = l.lastBytes[:trim]
+	}
+
+	if l.text != nil {
+		l.lastBytes = l.text.decode(l.lastBytes, l.s.State.Position == int64(l.lineLen))
+		if len(l.c.Delimiter) == 0 && l.dropped == 0 && !l.unterminated {
+			l.lastBytes = bytes.TrimSuffix(l.lastBytes, []byte{'\r'})
+		}
 	}
-	l.lastBytes = l.lastBytes[:trim]
 
 	// Don't touch the position, because if we want to resume where we
 	// left off, it should point to the start of the next line.
-	return true
+	return true, nil
+}
+
+// source returns what to read the file in s from, taking turns with the
+// other files using the Scheduler if it limits reads.
+func (l *LineReader) source(s WaitStatus) io.Reader {
+	if l.c.Scheduler != nil {
+		return l.c.Scheduler.reader(s.reader())
+	}
+	return s.reader()
+}
+
+// sniff detects the encoding of the open file for AutoEncoding, once it
+// has something in it to detect it from.
+func (l *LineReader) sniff() {
+	var start []byte
+	if l.file != nil {
+		buf := make([]byte, sniffBytes)
+		n, _ := l.file.ReadAt(buf, 0)
+		start = buf[:n]
+	} else if l.s.State.Position == 0 {
+		// Without a file, only a reader at the start can be checked.
+		start, _ = l.br.Peek(sniffBytes)
+	} else {
+		l.sniffing = false
+		return
+	}
+
+	if len(start) == 0 {
+		return
+	}
+	l.sniffing = false
+	l.text = newTextEncoding(detectEncoding(start), l.c.textDelimiter())
+	l.delim = l.text.delim
+}
+
+// partialDeadline returns when the current line is returned without its
+// delimiter, if PartialLineTimeout applies to it.
+func (l *LineReader) partialDeadline() (time.Time, bool) {
+	if l.c.PartialLineTimeout <= 0 || len(l.lastBytes) == 0 || l.skipPartial ||
+		(l.dropped > 0 && l.c.LongLines != LongLineTruncate) {
+		return time.Time{}, false
+	}
+	return l.lastRead.Add(l.c.PartialLineTimeout), true
+}
+
+// resetLine discards the current line.
+func (l *LineReader) resetLine() {
+	if l.reuse {
+		l.lastBytes = l.lastBytes[:0]
+	} else {
+		l.lastBytes = nil
+	}
+	l.dropped = 0
+}
+
+// appendLine adds b to the current line, dropping what is over
+// MaxLineBytes while keeping the last delimLen bytes to find the end of
+// it. It returns true when the line first becomes too long.
+func (l *LineReader) appendLine(b []byte, delimLen int) bool {
+	// b is only valid until the next read, so it's always copied.
+	l.lastBytes = append(l.lastBytes, b...)
+
+	max := l.c.MaxLineBytes
+	if max <= 0 || len(l.lastBytes) <= max+delimLen {
+		return false
+	}
+
+	first := l.dropped == 0
+	l.dropped += len(l.lastBytes) - max - delimLen
+	l.lastBytes = append(l.lastBytes[:max], l.lastBytes[len(l.lastBytes)-delimLen:]...)
+	return first
+}
+
+// checkCaughtUp is called at EOF and closes caughtUp the first time
+// there is no newer file with data left to read.
+func (l *LineReader) checkCaughtUp() {
+	select {
+	case <-l.caughtUp:
+		return
+	default:
+	}
+
+	named, err := NewFileStateFromPath(l.c.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	// Rotated, and the next file already has data that was
+	// written before reaching the end of this one.
+	if err == nil && named.Size > 0 && !l.c.SameFile(l.s.State, *named) {
+		return
+	}
+
+	close(l.caughtUp)
+}
+
+// CaughtUp returns a channel that is closed the first time the reader
+// reaches the end of the latest file, meaning all data that existed
+// before it started has been read and it is now following new writes.
+// If the file doesn't exist yet, it won't be closed until it does and
+// has been read to the end.
+func (l *LineReader) CaughtUp() <-chan struct{} {
+	return l.caughtUp
+}
+
+// SeekTo moves the reader within the currently open file so the next
+// call to Next returns the first line starting at or after offset. If
+// offset is in the middle of a line, the rest of that line is skipped
+// even if it hasn't been completely written yet. It returns an error if
+// no file has been opened yet or offset is beyond the end of the file.
+func (l *LineReader) SeekTo(offset int64) error {
+	if l.br == nil {
+		return errors.New("no file is open to seek in")
+	}
+
+	src := l.s.reader()
+	seeker, ok := src.(io.Seeker)
+	if !ok {
+		return errors.New("the open file doesn't support seeking")
+	}
+
+	stat, err := l.s.File.Stat()
+	if err != nil {
+		return err
+	}
+
+	if offset < 0 || offset > stat.Size() {
+		return fmt.Errorf("offset %v is outside of the file size %v", offset, stat.Size())
+	}
+
+	// Start a delimiter early, if those bytes are the delimiter then
+	// offset is already at the start of a line and only it is skipped.
+	start := offset - int64(len(l.delim))
+	if start < 0 {
+		start = 0
+	}
+
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	l.br.Reset(l.source(l.s))
+	l.s.State.Position = start
+	// Part of a line from an interrupted read was from before the seek.
+	l.resetLine()
+	l.resume = false
+	l.unterminated = false
+	l.skipPartial = offset > 0
+	l.lineNumber = 0
+	l.fromStart = offset == 0
+	return nil
+}
+
+// handle passes err from op to Config.OnErrorEvent if it's set, or the
+// ErrorHandler otherwise, and returns the result.
+func (l *LineReader) handle(op string, err error) error {
+	l.recordError(err)
+
+	if op == l.failedOp {
+		l.retries++
+	} else {
+		l.failedOp = op
+		l.retries = 0
+	}
+
+	if l.c.OnErrorEvent != nil {
+		return l.c.OnErrorEvent(ErrorEvent{
+			Op:      op,
+			Path:    l.c.Path,
+			Retries: l.retries,
+			Err:     err,
+		})
+	}
+	return l.onErr(err)
+}
+
+// retrying logs that err was handled and the failed operation is tried
+// again after the given time.
+func (l *LineReader) retrying(err error, after time.Duration) {
+	if l.err == nil {
+		l.c.debug("retrying after error", "op", l.failedOp, "retries", l.retries, "after", after, "err", err)
+	}
+}
+
+// backoff returns how long to wait after an error, from Config.Backoff
+// if it's set, or d otherwise.
+func (l *LineReader) backoff(d time.Duration) time.Duration {
+	if l.c.Backoff != nil {
+		return l.c.Backoff(l.retries)
+	}
+	return d
+}
+
+// succeeded resets the retries of file operations after reading or
+// waiting worked. Checkpoints are separate, so they're only reset by
+// saving.
+func (l *LineReader) succeeded() {
+	if l.failedOp != "checkpoint" {
+		l.failedOp = ""
+	}
+}
+
+// errorOp returns the operation of err if it's from the os package,
+// such as open, stat or seek, or op otherwise.
+func errorOp(err error, op string) string {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Op
+	}
+	return op
 }
 
 func (l *LineReader) handleError(err error) {
@@ -156,6 +942,78 @@ func (l *LineReader) Bytes() []byte {
 	return l.lastBytes
 }
 
+// Line describes a line returned by a LineReader.
+type Line struct {
+	// Path is the configured path of the file.
+	Path string
+	// Bytes is the line without its Delimiter.
+	Bytes []byte
+	// Offset is the position of the start of the line in its file.
+	Offset int64
+	// State is the FileState as of the end of the line.
+	State FileState
+	// Generation is which file the line was read from, counting the
+	// files the LineReader opened starting at 1, so lines from the same
+	// file can be told apart from ones after a rotation.
+	Generation int64
+	// Number is the same as LineNumber.
+	Number int64
+	// Partial is the same as Partial.
+	Partial bool
+	// ReadTime is when the line was returned by Next.
+	ReadTime time.Time
+}
+
+// Line returns the line last returned by Next along with where it was
+// read from. Like Bytes, Line.Bytes is only valid until the next call
+// to Next.
+func (l *LineReader) Line() Line {
+	return Line{
+		Path:       l.c.Path,
+		Bytes:      l.lastBytes,
+		Offset:     l.s.State.Position - int64(l.lineLen),
+		State:      l.s.State,
+		Generation: l.generation,
+		Number:     l.LineNumber(),
+		Partial:    l.unterminated,
+		ReadTime:   l.readTime,
+	}
+}
+
+// AppendLine blocks until a line is available like Next, and returns it
+// appended to dst. Since the line is copied, the LineReader reuses its
+// buffer for the next one instead of allocating a new one for every line,
+// and reusing dst too avoids allocating at all once it's large enough.
+// If the LineReader stops, it returns dst with Err(), or io.EOF if it was
+// closed without an error.
+func (l *LineReader) AppendLine(dst []byte) ([]byte, error) {
+	if !l.Next() {
+		return dst, l.stopErr()
+	}
+
+	dst = append(dst, l.lastBytes...)
+	l.reuse = true
+	return dst, nil
+}
+
+// Partial returns true if the line last returned by Next didn't end with
+// the Delimiter, because nothing more was written to it within
+// PartialLineTimeout.
+func (l *LineReader) Partial() bool {
+	return l.unterminated
+}
+
+// LineNumber returns the 1-based line number within its file of the line
+// last returned by Next, which starts over when a new file is opened. It
+// returns 0 if the number isn't known because reading didn't start at the
+// beginning of the file, such as when using Whence, StartState or SeekTo.
+func (l *LineReader) LineNumber() int64 {
+	if !l.fromStart {
+		return 0
+	}
+	return l.lineNumber
+}
+
 // Err returns any error that occurred that caused Next to
 // return false. If it's set, it will generally be what was
 // returned by the ErrorHandler.
@@ -172,9 +1030,107 @@ func (l *LineReader) Close() error {
 		close(l.stop)
 	}
 
-	return l.r.Close()
+	err := l.r.Close()
+	if e := l.checkpoint(true); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// Watcher returns the Watcher the LineReader waits on for more data.
+// Calling Wait on it directly will interfere with Next, so it should
+// only be used to wrap or inspect it, or call methods of extensions the
+// particular implementation provides.
+func (l *LineReader) Watcher() Watcher {
+	return l.r
+}
+
+// StreamOffset returns the total number of bytes, including delimiters,
+// of all lines returned by Next since the LineReader was created. Unlike
+// the position in FileState, it keeps increasing across rotations, which
+// makes it useful for tracking progress or detecting gaps downstream.
+func (l *LineReader) StreamOffset() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.offset
 }
 
+// FileState returns the state of the file as of the end of the last line
+// returned by Next, or the last one committed with Config.ManualCommit,
+// which is where reading should resume from with Config.StartState.
 func (l *LineReader) FileState() FileState {
-	return l.s.State
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+// Shutdown gracefully stops the LineReader. Next will continue to return
+// lines that are already available without waiting for more data, then
+// return false, at which point the LineReader is closed. If ctx expires
+// first, it is closed immediately and the context's error is returned.
+// Like CloseAndState, it should be called instead of Close.
+func (l *LineReader) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	l.draining = true
+	// If Next is already blocked waiting for data, there is nothing
+	// left to drain.
+	waiting := l.waiting
+	l.mu.Unlock()
+
+	if !waiting {
+		select {
+		case <-l.drained:
+		case <-ctx.Done():
+		}
+	}
+
+	err := l.Close()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// CloseAndState closes the LineReader, waits for any call to Next to
+// return, then returns the final FileState to resume from. Partial is
+// true if part of a line after the final state was read and discarded,
+// which will be read again when resuming. It should be called instead
+// of Close, not in addition to it.
+func (l *LineReader) CloseAndState() (state FileState, partial bool, err error) {
+	err = l.Close()
+
+	l.running.Lock()
+	defer l.running.Unlock()
+
+	// Next may have returned another line after Close saved.
+	if e := l.checkpoint(true); e != nil && err == nil {
+		err = e
+	}
+
+	l.mu.Lock()
+	state, partial = l.state, l.partial
+	l.mu.Unlock()
+
+	l.setChecksum(&state)
+	return state, partial, err
+}
+
+// setChecksum sets the Checksum of state with Config.ChecksumBytes, if
+// the file it's for is still at the path. The open file can't be used,
+// since it's closed by the time CloseAndState returns.
+func (l *LineReader) setChecksum(state *FileState) {
+	if l.c.ChecksumBytes <= 0 || state.Position == 0 {
+		return
+	}
+
+	f, err := openFile(l.c.Path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if named, err := NewFileState(f); err != nil || !state.ID().Equal(named.ID()) || named.Size < state.Position {
+		return
+	}
+	state.SetChecksum(f, l.c.ChecksumBytes)
 }
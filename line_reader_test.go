@@ -1,8 +1,15 @@
 package tail
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"testing"
 	"time"
 )
@@ -146,3 +153,1182 @@ func TestLineReaderRotate(t *testing.T) {
 
 	readLine(t, r, "file2")
 }
+
+func TestLineReaderStreamOffset(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-stream-offset-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 50,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "file1\n")
+	writer.Close()
+
+	readLine(t, r, "file1")
+
+	if offset := r.StreamOffset(); offset != 6 {
+		t.Fatalf("expected stream offset 6, got %v", offset)
+	}
+
+	// The offset keeps counting from the rotated file.
+	h.Rotate()
+	writer = h.Create()
+	writeString(t, writer, "file2\r\n")
+	writer.Close()
+
+	readLine(t, r, "file2")
+
+	if offset := r.StreamOffset(); offset != 13 {
+		t.Fatalf("expected stream offset 13 across both files, got %v", offset)
+	}
+}
+
+func TestLineReaderLineNumberRotate(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-line-number-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 50,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "file1\nfile1\nfile1\n")
+	writer.Close()
+
+	readLine(t, r, "file1")
+	readLine(t, r, "file1")
+	readLine(t, r, "file1")
+
+	if n := r.LineNumber(); n != 3 {
+		t.Fatalf("expected line number 3, got %v", n)
+	}
+
+	// The count starts again in the new file.
+	h.Rotate()
+	writer = h.Create()
+	writeString(t, writer, "file2\nfile2\n")
+	writer.Close()
+
+	readLine(t, r, "file2")
+	readLine(t, r, "file2")
+
+	if n := r.LineNumber(); n != 2 {
+		t.Fatalf("expected line number 2 in the new file, got %v", n)
+	}
+}
+
+func TestLineReaderSeekTo(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-seek-test")
+
+	c := Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 50,
+		StopAtEOF: true,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "aaa\nbbb\nccc\n")
+	writer.Close()
+
+	readLine(t, r, "aaa")
+
+	if n := r.LineNumber(); n != 1 {
+		t.Fatalf("expected line number 1, got %v", n)
+	}
+
+	// Middle of "bbb" should snap forward to "ccc".
+	if err := r.SeekTo(5); err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "ccc")
+
+	if n := r.LineNumber(); n != 0 {
+		t.Fatalf("expected unknown line number after seeking, got %v", n)
+	}
+
+	// Exactly at the start of "bbb".
+	if err := r.SeekTo(4); err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "bbb")
+
+	if pos := r.FileState().Position; pos != 8 {
+		t.Fatalf("expected position 8 after seeking, got %v", pos)
+	}
+
+	if err := r.SeekTo(0); err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "aaa")
+
+	if err := r.SeekTo(13); err == nil {
+		t.Fatal("expected error seeking beyond the end of the file")
+	}
+}
+
+func TestLineReaderCloseAndState(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-close-state-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := h.Create()
+	writeString(t, writer, "one\ntw")
+	writer.Close()
+
+	readLine(t, r, "one")
+
+	done := make(chan bool)
+	go func() {
+		done <- r.Next()
+	}()
+
+	// Give Next time to read the partial line and block waiting for more.
+	time.Sleep(time.Millisecond * 50)
+
+	state, partial, err := r.CloseAndState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if <-done {
+		t.Fatal("expected Next to return false after closing")
+	}
+
+	if !partial {
+		t.Fatal("expected a partial line to be reported")
+	}
+
+	if state.Position != 4 {
+		t.Fatalf("expected final position 4, got %v", state.Position)
+	}
+}
+
+func TestLineReaderShutdown(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-shutdown-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := h.Create()
+	writeString(t, writer, "a\nb\nc\n")
+	writer.Close()
+
+	readLine(t, r, "a")
+
+	done := make(chan error)
+	go func() {
+		done <- r.Shutdown(context.Background())
+	}()
+
+	readLine(t, r, "b")
+	readLine(t, r, "c")
+
+	if r.Next() {
+		t.Fatal("expected Next to return false after draining")
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLineReaderShutdownTimeout(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-shutdown-timeout-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}
+
+	r, err := NewLineReader(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := h.Create()
+	writeString(t, writer, "a\nb\n")
+	writer.Close()
+
+	readLine(t, r, "a")
+
+	// Nothing is consuming the remaining line, so it has to give up.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	if err := r.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	if r.Next() {
+		t.Fatal("expected Next to return false after shutdown")
+	}
+}
+
+func TestLineReaderCaughtUp(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-caught-up-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "a\nb\n")
+	defer writer.Close()
+
+	readLine(t, r, "a")
+	readLine(t, r, "b")
+
+	select {
+	case <-r.CaughtUp():
+		t.Fatal("caught up before reaching the end of the file")
+	default:
+	}
+
+	go func() {
+		<-r.CaughtUp()
+		if _, err := writer.WriteString("c\n"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	readLine(t, r, "c")
+}
+
+func TestLineReaderNextTimeout(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-next-timeout-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb")
+
+	readLine(t, r, "a")
+
+	// Only part of the next line is written, so it should time out
+	// without losing what was read.
+	if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+		t.Fatalf("expected timeout, got %v and %v", ok, err)
+	}
+
+	writeString(t, writer, "c\n")
+
+	ok, err := r.NextTimeout(time.Second)
+	if !ok || err != nil {
+		t.Fatalf("expected a line, got %v and %v", ok, err)
+	}
+
+	if string(r.Bytes()) != "bc" {
+		t.Fatalf("expected line 'bc', got '%v'", string(r.Bytes()))
+	}
+
+	if pos := r.FileState().Position; pos != 5 {
+		t.Fatalf("expected position 5, got %v", pos)
+	}
+}
+
+func TestLineReaderSeekToAfterPartial(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-seek-partial-test")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Error(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "one\npart")
+
+	readLine(t, r, "one")
+
+	// Only part of the next line is read before giving up.
+	if ok, more := r.TryNext(); ok || !more {
+		t.Fatalf("expected no line but more, got %v and %v", ok, more)
+	}
+
+	if err := r.SeekTo(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The partial line isn't joined onto the first one.
+	readLine(t, r, "one")
+}
+
+func TestLineReaderTryNext(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-try-next-test")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is open yet, so there is nothing to return.
+	if ok, more := r.TryNext(); ok || !more {
+		t.Fatalf("expected no line but more, got %v and %v", ok, more)
+	}
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb\nc")
+
+	readLine(t, r, "a")
+
+	if ok, more := r.TryNext(); !ok || !more || string(r.Bytes()) != "b" {
+		t.Fatalf("expected line 'b', got %v, %v and '%v'", ok, more, string(r.Bytes()))
+	}
+
+	// Only part of the next line is available.
+	if ok, more := r.TryNext(); ok || !more {
+		t.Fatalf("expected no line but more, got %v and %v", ok, more)
+	}
+
+	writeString(t, writer, "d\n")
+	if ok, _ := r.TryNext(); !ok || string(r.Bytes()) != "cd" {
+		t.Fatalf("expected line 'cd', got %v and '%v'", ok, string(r.Bytes()))
+	}
+
+	r.Close()
+	if ok, more := r.TryNext(); ok || more {
+		t.Fatalf("expected no more after closing, got %v and %v", ok, more)
+	}
+}
+
+func TestLineReaderTruncated(t *testing.T) {
+
+	policies := map[string]TruncatePolicy{
+		"read from start": TruncateReadFromStart,
+		"seek end":        TruncateSeekEnd,
+		"error":           TruncateError,
+	}
+
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			h := NewWatcherHarness(t, "line-reader-truncated-test")
+
+			var handled []error
+			r, err := NewLineReader(Config{
+				Path:       h.Path(),
+				Interval:   time.Millisecond * 10,
+				Truncation: policy,
+			}, func(e error) error {
+				handled = append(handled, e)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			writer := h.Create()
+			writeString(t, writer, "hello\nworld\n")
+			writer.Close()
+
+			readLine(t, r, "hello")
+			readLine(t, r, "world")
+
+			if err := ioutil.WriteFile(h.Path(), []byte("x\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			expect, pos := "x", int64(2)
+			if policy == TruncateSeekEnd {
+				// Give it time to notice before writing what
+				// should be read.
+				if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+					t.Fatalf("expected timeout, got %v and %v", ok, err)
+				}
+				writer, err := os.OpenFile(h.Path(), os.O_APPEND|os.O_WRONLY, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeString(t, writer, "y\n")
+				writer.Close()
+				expect, pos = "y", 4
+			}
+
+			readLine(t, r, expect)
+			if p := r.FileState().Position; p != pos {
+				t.Fatalf("expected position %v, got %v", pos, p)
+			}
+
+			if policy == TruncateError {
+				if len(handled) != 1 || !errors.Is(handled[0], ErrTruncated) {
+					t.Fatalf("expected one ErrTruncated, got %v", handled)
+				}
+			} else if len(handled) != 0 {
+				t.Fatalf("unexpected errors: %v", handled)
+			}
+		})
+	}
+}
+
+func TestLineReaderStartMismatch(t *testing.T) {
+
+	policies := map[string]struct {
+		policy MismatchPolicy
+		choose bool
+	}{
+		"read from start": {policy: MismatchReadFromStart},
+		"seek end":        {policy: MismatchSeekEnd},
+		"error":           {policy: MismatchError},
+		"callback":        {policy: MismatchSeekEnd, choose: true},
+	}
+
+	for name, test := range policies {
+		t.Run(name, func(t *testing.T) {
+			h := NewWatcherHarness(t, "line-reader-start-mismatch-test")
+			writer := h.Create()
+			writeString(t, writer, "old\n")
+			writer.Close()
+
+			saved, err := NewFileStateFromPath(h.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved.Position = saved.Size
+
+			// The file is replaced while nothing is reading it.
+			h.Rotate()
+			writer = h.Create()
+			defer writer.Close()
+			writeString(t, writer, "a\n")
+
+			c := Config{
+				Path:          h.Path(),
+				Interval:      time.Millisecond * 10,
+				StartState:    saved,
+				StartMismatch: test.policy,
+			}
+			if test.choose {
+				c.StartMismatch = MismatchError
+				c.OnStartMismatch = func(s, found FileState) MismatchPolicy {
+					if s.Position != 4 || found.Size != 2 {
+						t.Errorf("unexpected states %v and %v", s, found)
+					}
+					return test.policy
+				}
+			}
+
+			var handled []error
+			r, err := NewLineReader(c, func(e error) error {
+				handled = append(handled, e)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			expect := "a"
+			if test.policy == MismatchSeekEnd {
+				if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+					t.Fatalf("expected timeout, got %v and %v", ok, err)
+				}
+				writeString(t, writer, "b\n")
+				expect = "b"
+			}
+			readLine(t, r, expect)
+
+			if test.policy == MismatchError {
+				if len(handled) != 1 || !errors.Is(handled[0], ErrStartMismatch) {
+					t.Fatalf("expected one ErrStartMismatch, got %v", handled)
+				}
+			} else if len(handled) != 0 {
+				t.Fatalf("unexpected errors: %v", handled)
+			}
+		})
+	}
+}
+
+func TestLineReaderDelimiter(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-delimiter-test")
+
+	r, err := NewLineReader(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		Delimiter: []byte("||"),
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb||c|d||e|")
+
+	readLine(t, r, "a\nb")
+	readLine(t, r, "c|d")
+
+	// The delimiter is split between writes.
+	writeString(t, writer, "|f||")
+	readLine(t, r, "e")
+	readLine(t, r, "f")
+
+	if pos := r.FileState().Position; pos != 16 {
+		t.Fatalf("expected position 16, got %v", pos)
+	}
+
+	// Right after a delimiter, so nothing should be skipped.
+	if err := r.SeekTo(5); err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "c|d")
+}
+
+func TestLineReaderTailLines(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-tail-lines-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb\nc\nd\n")
+
+	r, err := NewLineReader(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		TailLines: 2,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "c")
+	readLine(t, r, "d")
+
+	writeString(t, writer, "e\n")
+	readLine(t, r, "e")
+
+	// Only the first file is affected.
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "f\ng\nh\n")
+	readLine(t, r, "f")
+	readLine(t, r, "g")
+	readLine(t, r, "h")
+}
+
+func TestLineReaderOffset(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-offset-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb\nc\nd\n")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		Whence:   io.SeekEnd,
+		Offset:   -5,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Like tail -c, it can start partway through a line.
+	readLine(t, r, "")
+	readLine(t, r, "c")
+	readLine(t, r, "d")
+
+	// Only the first file is affected.
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "e\n")
+	readLine(t, r, "e")
+
+	for _, c := range []Config{
+		{Path: h.Path(), Whence: io.SeekEnd, Offset: 1},
+		{Path: h.Path(), Whence: io.SeekStart, Offset: -1},
+	} {
+		if _, err := NewLineReader(c, nil); err == nil {
+			t.Fatalf("expected an error for offset %v from %v", c.Offset, c.Whence)
+		}
+	}
+}
+
+func TestLineReaderMaxLineBytes(t *testing.T) {
+
+	policies := map[string]struct {
+		policy LongLinePolicy
+		expect []string
+	}{
+		"truncate": {LongLineTruncate, []string{"a", "01234", "b"}},
+		"skip":     {LongLineSkip, []string{"a", "b"}},
+		"error":    {LongLineError, []string{"a", "b"}},
+	}
+
+	for name, test := range policies {
+		t.Run(name, func(t *testing.T) {
+			h := NewWatcherHarness(t, "line-reader-max-line-bytes-test")
+
+			var handled []error
+			r, err := NewLineReader(Config{
+				Path:         h.Path(),
+				Interval:     time.Millisecond * 10,
+				MaxLineBytes: 5,
+				LongLines:    test.policy,
+			}, func(e error) error {
+				handled = append(handled, e)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			writer := h.Create()
+			defer writer.Close()
+			writeString(t, writer, "a\n0123456789")
+
+			readLine(t, r, test.expect[0])
+
+			// Only the start of the long line is kept while
+			// waiting for the rest of it.
+			if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+				t.Fatalf("expected timeout, got %v and %v", ok, err)
+			}
+			if n := len(r.lastBytes); n > 6 {
+				t.Fatalf("expected at most 6 bytes kept, got %v", n)
+			}
+
+			writeString(t, writer, "abcdef\nb\n")
+			for _, expect := range test.expect[1:] {
+				readLine(t, r, expect)
+			}
+
+			if pos := r.FileState().Position; pos != 21 {
+				t.Fatalf("expected position 21, got %v", pos)
+			}
+
+			if offset := r.StreamOffset(); offset != 21 && test.policy == LongLineTruncate {
+				t.Fatalf("expected stream offset 21, got %v", offset)
+			}
+
+			if test.policy == LongLineError {
+				var tooLong *LineTooLongError
+				if len(handled) != 1 || !errors.As(handled[0], &tooLong) || tooLong.Position != 2 {
+					t.Fatalf("expected one LineTooLongError at 2, got %v", handled)
+				}
+			} else if len(handled) != 0 {
+				t.Fatalf("unexpected errors: %v", handled)
+			}
+		})
+	}
+}
+
+// errWatcher always fails to Wait, to test error handling.
+type errWatcher struct {
+	closed chan struct{}
+}
+
+func (w *errWatcher) Wait() (WaitStatus, bool, error) {
+	select {
+	case <-w.closed:
+		return WaitStatus{}, true, nil
+	default:
+		return WaitStatus{}, false, errors.New("wait failed")
+	}
+}
+
+func (w *errWatcher) Close() error {
+	close(w.closed)
+	return nil
+}
+
+func TestLineReaderCloseDuringBackoff(t *testing.T) {
+
+	r, err := NewLineReader(Config{Path: "unused"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.r.Close()
+
+	handled := 0
+	r.r = &errWatcher{closed: make(chan struct{})}
+	r.onErr = func(error) error {
+		handled++
+		return nil
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- r.Next()
+	}()
+
+	// Next should now be sleeping for a second after the error.
+	time.Sleep(time.Millisecond * 20)
+	r.Close()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("expected Next to return false after closing")
+		}
+	case <-time.After(time.Millisecond * 200):
+		t.Fatal("Close didn't interrupt the error backoff")
+	}
+
+	if handled != 1 {
+		t.Fatalf("expected one error to be handled, got %v", handled)
+	}
+}
+
+func TestLineReaderPartialLineGrace(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-partial-grace-test")
+
+	c := Config{
+		Path:             h.Path(),
+		Interval:         time.Millisecond * 10,
+		PartialLineGrace: time.Second,
+	}
+
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb")
+
+	readLine(t, r, "a")
+
+	// Rotate in the middle of writing a line, then finish it in the
+	// old file after the new one already has data.
+	h.Rotate()
+	writer2 := h.Create()
+	writeString(t, writer2, "new\n")
+	writer2.Close()
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		if _, err := writer.WriteString("c\n"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	readLine(t, r, "bc")
+	readLine(t, r, "new")
+}
+
+func TestLineReaderPartialLineTimeout(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-partial-timeout-test")
+
+	r, err := NewLineReader(Config{
+		Path:               h.Path(),
+		Interval:           time.Millisecond * 10,
+		PartialLineTimeout: time.Millisecond * 100,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb")
+
+	readLine(t, r, "a")
+	if r.Partial() {
+		t.Fatal("expected a complete line")
+	}
+
+	// Writing more before the timeout keeps waiting for the rest.
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		if _, err := writer.WriteString("c"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	start := time.Now()
+	readLine(t, r, "bc")
+	if !r.Partial() {
+		t.Fatal("expected a partial line")
+	}
+	if n := r.LineNumber(); n != 2 {
+		t.Fatalf("expected line number 2, got %v", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Millisecond*150 {
+		t.Fatalf("expected the partial line after at least 150ms, got %v", elapsed)
+	}
+
+	if pos := r.FileState().Position; pos != 4 {
+		t.Fatalf("expected position 4, got %v", pos)
+	}
+
+	writeString(t, writer, "d\ne\n")
+	readLine(t, r, "d")
+	if r.Partial() {
+		t.Fatal("expected a complete line")
+	}
+
+	// The rest of the partial line is still the same line.
+	if n := r.LineNumber(); n != 2 {
+		t.Fatalf("expected line number 2, got %v", n)
+	}
+	readLine(t, r, "e")
+	if n := r.LineNumber(); n != 3 {
+		t.Fatalf("expected line number 3, got %v", n)
+	}
+}
+
+func TestLineReaderAppendLine(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-append-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "abc\nd\n")
+
+	r, err := NewLineReader(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		StopAtEOF: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	b, err := r.AppendLine([]byte("> "))
+	if err != nil || string(b) != "> abc" {
+		t.Fatalf("expected > abc, got %q, %v", b, err)
+	}
+
+	// The first line was copied, so reusing the LineReader's buffer
+	// for the second one doesn't change it.
+	d, err := r.AppendLine(nil)
+	if err != nil || string(d) != "d" || string(b) != "> abc" {
+		t.Fatalf("expected > abc and d, got %q and %q, %v", b, d, err)
+	}
+
+	if _, err = r.AppendLine(nil); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+// benchmarkLineReader reads b.N lines from a file with r.
+func benchmarkLineReader(b *testing.B, read func(r *LineReader) bool) {
+	name := filepath.Join(b.TempDir(), "bench")
+	line := bytes.Repeat([]byte("x"), 100)
+	line = append(line, '\n')
+	if err := ioutil.WriteFile(name, bytes.Repeat(line, b.N), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	r, err := NewLineReader(Config{Path: name, StopAtEOF: true}, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer r.Close()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(line)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if !read(r) {
+			b.Fatal(r.Err())
+		}
+	}
+}
+
+func BenchmarkLineReaderNext(b *testing.B) {
+	var buf []byte
+	benchmarkLineReader(b, func(r *LineReader) bool {
+		if !r.Next() {
+			return false
+		}
+		buf = append(buf[:0], r.Bytes()...)
+		return true
+	})
+}
+
+func BenchmarkLineReaderAppendLine(b *testing.B) {
+	var buf []byte
+	benchmarkLineReader(b, func(r *LineReader) bool {
+		var err error
+		buf, err = r.AppendLine(buf[:0])
+		return err == nil
+	})
+}
+
+func TestLineReaderBufferSize(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-buffer-size-test")
+	writer := h.Create()
+	writeString(t, writer, "a\n")
+
+	r, err := NewLineReader(Config{
+		Path:       h.Path(),
+		Interval:   time.Millisecond * 10,
+		BufferSize: 64 * 1024,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "a")
+	br := r.br
+	if br.Size() != 64*1024 {
+		t.Fatalf("expected a buffer of 65536, got %v", br.Size())
+	}
+
+	// The same buffer is used for the new file.
+	writer.Close()
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "b\n")
+
+	readLine(t, r, "b")
+	if r.br != br {
+		t.Fatal("expected the buffer to be reused after rotating")
+	}
+
+	if _, err := NewLineReader(Config{Path: h.Path(), BufferSize: -1}, nil); err == nil {
+		t.Fatal("expected an error for a negative buffer size")
+	}
+}
+
+func TestLineReaderLine(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-line-test")
+	writer := h.Create()
+	writeString(t, writer, "a\nbc\n")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	start := time.Now()
+	readLine(t, r, "a")
+	readLine(t, r, "bc")
+
+	line := r.Line()
+	if string(line.Bytes) != "bc" || line.Offset != 2 || line.State.Position != 5 {
+		t.Fatalf("expected bc from 2 to 5, got %q from %v to %v", line.Bytes, line.Offset, line.State.Position)
+	}
+	if line.Generation != 1 || line.Number != 2 || line.ReadTime.Before(start) {
+		t.Fatalf("unexpected line %+v", line)
+	}
+
+	writer.Close()
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "d\n")
+
+	readLine(t, r, "d")
+	if line = r.Line(); line.Generation != 2 || line.Offset != 0 {
+		t.Fatalf("expected generation 2 from 0, got %v from %v", line.Generation, line.Offset)
+	}
+}
+
+func TestLineReaderFilter(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-filter-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "info a\ndebug b\ntrace c\ninfo d\ndebug e\n")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		Filter:   RegexpFilter(regexp.MustCompile(`^(info|debug)`), regexp.MustCompile(`^debug`)),
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	readLine(t, r, "info a")
+	readLine(t, r, "info d")
+	if n := r.LineNumber(); n != 4 {
+		t.Fatalf("expected line 4, got %v", n)
+	}
+
+	if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+		t.Fatalf("expected a timeout, got %v, %v", ok, err)
+	}
+
+	// The last line was dropped, but isn't read again when resuming.
+	if pos := r.FileState().Position; pos != 38 {
+		t.Fatalf("expected position 38, got %v", pos)
+	}
+}
+
+func TestLineReaderStopConditions(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		c     Config
+		lines []string
+	}{
+		{"lines", Config{StopAfterLines: 2}, []string{"a", "bb"}},
+		{"position", Config{StopAtPosition: 3}, []string{"a", "bb"}},
+		{"predicate", Config{StopWhen: func(line []byte) bool { return string(line) == "a" }}, []string{"a"}},
+		{"time", Config{StopAt: time.Now().Add(time.Millisecond * 200)}, []string{"a", "bb", "c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewWatcherHarness(t, "line-reader-stop-test")
+			writer := h.Create()
+			defer writer.Close()
+			writeString(t, writer, "a\nbb\nc\n")
+
+			c := test.c
+			c.Path = h.Path()
+			c.Interval = time.Millisecond * 10
+			r, err := NewLineReader(c, func(e error) error {
+				t.Fatal(e)
+				return e
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			for _, line := range test.lines {
+				readLine(t, r, line)
+			}
+
+			if ok, err := r.NextTimeout(time.Second); ok || err != ErrStopped {
+				t.Fatalf("expected ErrStopped, got %v, %v", ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package tail
+
+// Logger is given debug messages about what is decided while following a
+// file, with alternating keys and values for context, like slog. A
+// *slog.Logger can be used as one.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// debug logs msg to the Logger if there is one, along with the path.
+func (c Config) debug(msg string, args ...interface{}) {
+	if c.Logger == nil {
+		return
+	}
+	c.Logger.Debug(msg, append([]interface{}{"path", c.Path}, args...)...)
+}
@@ -0,0 +1,67 @@
+package tail
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLogger records the messages it's given.
+type testLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, msg)
+}
+
+func (l *testLogger) logged(msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range l.msgs {
+		if m == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLogger(t *testing.T) {
+	h := NewWatcherHarness(t, "logger-test")
+	logger := &testLogger{}
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		Logger:   logger,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "aa\n")
+	writer.Close()
+	readLine(t, r, "aa")
+
+	if err := ioutil.WriteFile(h.Path(), []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "b")
+
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "c\n")
+	readLine(t, r, "c")
+
+	for _, msg := range []string{"opened file", "truncation detected", "rotation detected"} {
+		if !logger.logged(msg) {
+			t.Errorf("expected %q to be logged, got %q", msg, logger.msgs)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package tail
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// MultiTailer tails several files at once with a LineReader for each,
+// returning their lines as one stream in the order they're read. Unlike a
+// DirWatcher, the files are fixed, and each has its own Config.
+type MultiTailer struct {
+	lines chan multiLine
+	// cur is the line last returned by Next.
+	cur multiLine
+
+	readers []*LineReader
+	// done is closed once every LineReader has stopped.
+	done chan struct{}
+
+	// ctx is cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// mu protects the fields below, which are used by States and Err.
+	mu     sync.Mutex
+	states map[string]FileState
+	err    error
+	closed bool
+}
+
+type multiLine struct {
+	line Line
+	ack  chan struct{}
+}
+
+// NewMultiTailer returns a MultiTailer reading from a LineReader created
+// from each of configs, which must all have different paths. Errors from
+// each LineReader are passed to h like with NewLineReader.
+func NewMultiTailer(configs []Config, h ErrorHandler) (*MultiTailer, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &MultiTailer{
+		lines:  make(chan multiLine),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+		states: make(map[string]FileState),
+	}
+
+	for _, c := range configs {
+		if _, ok := t.states[c.Path]; ok {
+			t.Close()
+			return nil, errors.New("config value for path cannot be the same for more than one file")
+		}
+
+		r, err := NewLineReader(c, h)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.readers = append(t.readers, r)
+		t.states[c.Path] = r.FileState()
+	}
+
+	for _, r := range t.readers {
+		t.wg.Add(1)
+		go t.read(r)
+	}
+
+	go func() {
+		t.wg.Wait()
+		close(t.done)
+	}()
+	return t, nil
+}
+
+// read passes each line from r to Next, waiting for it to be consumed
+// before reading the next one.
+func (t *MultiTailer) read(r *LineReader) {
+	defer t.wg.Done()
+
+	ack := make(chan struct{})
+	for r.Next() {
+		select {
+		case t.lines <- multiLine{line: r.Line(), ack: ack}:
+		case <-t.ctx.Done():
+			return
+		}
+
+		select {
+		case <-ack:
+		case <-t.ctx.Done():
+			return
+		}
+	}
+
+	if err := r.Err(); err != nil {
+		t.mu.Lock()
+		if t.err == nil {
+			t.err = err
+		}
+		t.mu.Unlock()
+	}
+}
+
+// Next blocks until a line is available from any file and returns true,
+// or returns false once the MultiTailer is closed or every LineReader
+// has stopped.
+func (t *MultiTailer) Next() bool {
+	if t.cur.ack != nil {
+		select {
+		case t.cur.ack <- struct{}{}:
+		case <-t.ctx.Done():
+			return false
+		}
+		t.cur = multiLine{}
+	}
+
+	select {
+	case t.cur = <-t.lines:
+	case <-t.done:
+		return false
+	case <-t.ctx.Done():
+		return false
+	}
+
+	t.mu.Lock()
+	t.states[t.cur.line.Path] = t.cur.line.State
+	t.mu.Unlock()
+	return true
+}
+
+// Line returns the line last returned by Next, with the path it was read
+// from. Line.Bytes is only valid until Next is called again.
+func (t *MultiTailer) Line() Line {
+	return t.cur.line
+}
+
+// States returns the state of each file as of the end of the last line
+// returned from it, which can be set as the StartState of each Config to
+// resume. It is safe to call in parallel to Next.
+func (t *MultiTailer) States() map[string]FileState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	states := make(map[string]FileState, len(t.states))
+	for path, state := range t.states {
+		states[path] = state
+	}
+	return states
+}
+
+// Stats returns the Stats of the LineReader for each file by path, such
+// as how many times each was closed while idle for a Config.FileLimit.
+// It is safe to call in parallel to Next.
+func (t *MultiTailer) Stats() map[string]Stats {
+	stats := make(map[string]Stats, len(t.readers))
+	for _, r := range t.readers {
+		stats[r.c.Path] = r.Stats()
+	}
+	return stats
+}
+
+// Err returns the error that stopped the first LineReader to stop with
+// one, if any.
+func (t *MultiTailer) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Close closes every LineReader and waits for them to stop.
+func (t *MultiTailer) Close() error {
+	t.mu.Lock()
+	closed := t.closed
+	t.closed = true
+	t.mu.Unlock()
+
+	if closed {
+		return nil
+	}
+	t.cancel()
+
+	var err error
+	for _, r := range t.readers {
+		if e := r.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	t.wg.Wait()
+	return err
+}
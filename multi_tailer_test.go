@@ -0,0 +1,57 @@
+package tail
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMultiTailer(t *testing.T) {
+
+	a := NewWatcherHarness(t, "multi-tailer-a")
+	b := NewWatcherHarness(t, "multi-tailer-b")
+
+	wa := a.Create()
+	defer wa.Close()
+	writeString(t, wa, "a1\n")
+
+	mt, err := NewMultiTailer([]Config{
+		{Path: a.Path(), Interval: time.Millisecond * 10},
+		{Path: b.Path(), Interval: time.Millisecond * 10},
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mt.Close()
+
+	expectLine := func(path, s string) {
+		t.Helper()
+		if !mt.Next() {
+			t.Fatal("expected a line")
+		}
+		if line := mt.Line(); line.Path != path || string(line.Bytes) != s {
+			t.Fatalf("expected %q from %v, got %q from %v", s, path, line.Bytes, line.Path)
+		}
+	}
+
+	expectLine(a.Path(), "a1")
+
+	wb := b.Create()
+	defer wb.Close()
+	writeString(t, wb, "b1\n")
+	expectLine(b.Path(), "b1")
+
+	writeString(t, wa, "a2\n")
+	expectLine(a.Path(), "a2")
+
+	states := mt.States()
+	if states[a.Path()].Position != 6 || states[b.Path()].Position != 3 {
+		t.Fatalf("expected positions 6 and 3, got %v and %v", states[a.Path()].Position, states[b.Path()].Position)
+	}
+
+	if _, err := NewMultiTailer([]Config{{Path: a.Path()}, {Path: a.Path()}}, nil); err == nil {
+		t.Fatal("expected an error for the same path twice")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !illumos && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!illumos,!linux,!netbsd,!openbsd,!solaris
+
+package tail
+
+import "errors"
+
+// NewPipeWatcher isn't supported on this platform and always returns an
+// error.
+func NewPipeWatcher(c Config) (Watcher, error) {
+	return nil, errors.New("tailing pipes is only supported on unix")
+}
@@ -0,0 +1,212 @@
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd illumos linux netbsd openbsd solaris
+
+package tail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var _ ContextWatcher = (*pipeWatcher)(nil)
+
+type pipeWatcher struct {
+	c Config
+
+	f  *os.File
+	rc syscall.RawConn
+
+	// state identifies the pipe, and its Position is the number of
+	// bytes read from it.
+	state  FileState
+	opened bool
+	// hungUp is set once a read found nothing even though poll said it
+	// was readable, so there's never going to be more.
+	hungUp bool
+
+	closed bool
+	mu     sync.Mutex
+}
+
+// NewPipeWatcher configures a Watcher for a named pipe (FIFO) or character
+// device at c.Path, which is opened right away. Rather than polling, Wait
+// blocks until there is data to read. A named pipe is also opened for
+// writing, so it stays open while writers come and go instead of reaching
+// the end when the last one closes it. Only Path is used from c.
+//
+// A read that finds nothing even though the pipe or device was reported
+// readable means the writer is gone for good, such as for /dev/null or a
+// terminal that hung up, and Wait then returns closed.
+//
+// Pipes can't be seeked, so SeekTo isn't supported, and the Position of
+// the FileState is the number of bytes read, which can't be resumed from.
+func NewPipeWatcher(c Config) (Watcher, error) {
+	if c.Path == "" {
+		return nil, errors.New("config value for path cannot be empty")
+	}
+
+	stat, err := os.Stat(c.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	flag := os.O_RDONLY
+	switch mode := stat.Mode(); {
+	case mode&os.ModeNamedPipe != 0:
+		flag = os.O_RDWR
+	case mode&os.ModeCharDevice != 0:
+	default:
+		return nil, fmt.Errorf("file %s with mode %v is not a pipe or character device", c.Path, mode)
+	}
+
+	// Opening it non-blocking uses the runtime poller, so Close
+	// interrupts a Wait in progress.
+	f, err := os.OpenFile(c.Path, flag|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pipeWatcher{c: c, f: f}
+	if p.rc, err = f.SyscallConn(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if stat, err = f.Stat(); err == nil {
+		p.state.Device, p.state.Inode, err = fileIdentity(f, stat)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pipeWatcher) Wait() (s WaitStatus, closed bool, err error) {
+	return p.WaitContext(context.Background())
+}
+
+// longAgo is a deadline that has already passed, to interrupt a wait.
+var longAgo = time.Unix(1, 0)
+
+func (p *pipeWatcher) WaitContext(ctx context.Context) (s WaitStatus, closed bool, err error) {
+	if p.isClosed() {
+		return s, true, nil
+	}
+
+	if !p.opened {
+		p.opened = true
+		return p.status(Created), false, nil
+	}
+
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				p.f.SetReadDeadline(longAgo)
+			case <-stop:
+			}
+		}()
+
+		defer func() {
+			close(stop)
+			<-stopped
+			p.f.SetReadDeadline(time.Time{})
+		}()
+	}
+
+	err = p.rc.Read(func(fd uintptr) bool {
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, 0)
+		// If poll fails, let the read find out why.
+		return err != nil || n > 0
+	})
+
+	if p.isClosed() {
+		return s, true, nil
+	}
+
+	if ctx.Err() != nil {
+		return s, false, ctx.Err()
+	}
+
+	if err != nil {
+		return s, false, err
+	}
+	return p.status(DataAvailable), false, nil
+}
+
+func (p *pipeWatcher) status(event Event) WaitStatus {
+	return WaitStatus{
+		State:    p.state,
+		File:     p.f,
+		Reader:   pipeReader{p},
+		Event:    event,
+		ReOpened: event.reopened(),
+	}
+}
+
+func (p *pipeWatcher) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed || p.hungUp
+}
+
+func (p *pipeWatcher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	return p.f.Close()
+}
+
+// pipeReader reads what is available from the pipe without blocking,
+// returning io.EOF when there isn't anything so the reader calls Wait.
+type pipeReader struct {
+	p *pipeWatcher
+}
+
+func (r pipeReader) Read(b []byte) (int, error) {
+	var n int
+	var readErr error
+	err := r.p.rc.Read(func(fd uintptr) bool {
+		n, readErr = unix.Read(int(fd), b)
+		return true
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if readErr == nil && n == 0 && len(b) > 0 {
+		// Otherwise Wait would return right away again, forever.
+		r.p.mu.Lock()
+		r.p.hungUp = true
+		r.p.mu.Unlock()
+		return 0, io.EOF
+	}
+
+	if readErr == unix.EAGAIN || (readErr == nil && n == 0) {
+		return 0, io.EOF
+	} else if readErr != nil {
+		return 0, &os.PathError{Op: "read", Path: r.p.c.Path, Err: readErr}
+	}
+
+	r.p.state.Position += int64(n)
+	r.p.state.Size = r.p.state.Position
+	return n, nil
+}
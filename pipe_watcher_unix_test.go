@@ -0,0 +1,113 @@
+//go:build aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd illumos linux netbsd openbsd solaris
+
+package tail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestPipeWatcher(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "fifo")
+	if err := unix.Mkfifo(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{Path: path}
+	w, err := NewPipeWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewLineReaderFromWatcher(w, c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+
+	// Writers coming and going shouldn't end the pipe.
+	for _, s := range []string{"a\nb", "\n", "c\n"} {
+		writer, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeString(t, writer, s)
+		writer.Close()
+	}
+
+	readLine(t, r, "a")
+	readLine(t, r, "b")
+	readLine(t, r, "c")
+
+	if pos := r.FileState().Position; pos != 6 {
+		t.Fatalf("expected position 6, got %v", pos)
+	}
+
+	// Nothing else is written, so this has to be interrupted.
+	if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+		t.Fatalf("expected timeout, got %v and %v", ok, err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- r.Next()
+	}()
+
+	time.Sleep(time.Millisecond * 20)
+	r.Close()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("expected Next to return false after closing")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't interrupt waiting on the pipe")
+	}
+}
+
+func TestPipeWatcherHungUp(t *testing.T) {
+
+	c := Config{Path: os.DevNull}
+	w, err := NewPipeWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	r := NewLineReaderFromWatcher(w, c, func(e error) error {
+		t.Error(e)
+		return e
+	})
+
+	// It's always readable without anything to read, which ends it
+	// instead of waiting again forever.
+	done := make(chan bool)
+	go func() {
+		done <- r.Next()
+	}()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatalf("expected no line, got %q", r.Bytes())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Next to return once nothing could be read")
+	}
+}
+
+func TestPipeWatcherRegularFile(t *testing.T) {
+
+	h := NewWatcherHarness(t, "pipe-regular")
+	h.Create().Close()
+
+	if _, err := NewPipeWatcher(Config{Path: h.Path()}); err == nil {
+		t.Fatal("expected an error for a regular file")
+	}
+}
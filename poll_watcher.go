@@ -1,36 +1,179 @@
 package tail
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
-var _ Watcher = (*pollWatcher)(nil)
+var _ ContextWatcher = (*pollWatcher)(nil)
 
 type pollWatcher struct {
 	c Config
 
-	timer *time.Timer
-	f     *os.File
+	f *os.File
 
-	cancel chan struct{}
-	closed bool
+	// requests passes each Wait to the poll goroutine, which serves them
+	// one at a time until cancel is closed, then closes done.
+	requests chan pollRequest
+	cancel   chan struct{}
+	done     chan struct{}
+	closed   bool
+
+	// wake is optional and polls right away when sent to, rather
+	// than waiting for the next Interval.
+	wake chan struct{}
+
+	// onOpen is optional and called with the state of each file opened,
+	// such as to stop watching the ones before it.
+	onOpen func(FileState)
+
+	// sched polls instead of the poll goroutine when Config.Scheduler
+	// is set, and turn is held by the Wait it's polling for. Its mu
+	// guards waiting, the Wait being polled for, and woken, which is set
+	// by a wake while there isn't one.
+	sched   *Scheduler
+	turn    chan struct{}
+	waiting *schedWait
+	woken   bool
+
+	// graceStart is when a rotation was first seen while the open file
+	// ended with a partial line.
+	graceStart time.Time
+
+	// missing is how many polls in a row found no file at the path,
+	// and retryAt is when to check for it again with Config.Backoff.
+	missing int
+	retryAt time.Time
+
+	// idle is how many ticks the last poll was since the one before it
+	// with MaxInterval, and skip is how many ticks are left until the
+	// next one. Both are 0 while the file is active.
+	idle int
+	skip int
+
+	// created is when the watcher was created, and found is set once a
+	// file was opened, for MustExist.
+	created time.Time
+	found   bool
+
+	// rotatedName is where the last closed file was renamed to.
+	rotatedName string
+
+	// target is the name the open file was opened with, which is what
+	// the path resolved to with FollowSymlinks.
+	target string
+
+	// mode is the mode of the open file as of the last poll, so a change
+	// that makes it unreadable can be reported.
+	mode os.FileMode
+
+	// truncated is set once a TruncatedError has been returned for
+	// the open file.
+	truncated bool
+
+	// removed is set once a Removed event was returned for the open
+	// file, and
+	// finished is the state of the last file finished by a rotation
+	// until OnRotate is called with it.
+	removed  bool
+	finished *FileState
+
+	// parked is the state of the file closed while idle for a
+	// FileLimit, until it's opened again.
+	parked *FileState
+
+	// skipped is recorded when opening the first file with LazyBackfill.
+	skipped []SkippedRange
+
+	growth growthRate
 
+	// stats is counted while polling, and readTo is the position the
+	// open file was read up to as of the last poll, for BytesRead.
+	stats  Stats
+	readTo int64
+
+	// traced is the trace of recent decisions kept with TraceSize.
+	traced history
+
+	// notify is set before polling starts by the Watchers that try to
+	// use filesystem notifications.
+	notify NotifyStatus
+
+	// fp is the fingerprint of the first fpSize bytes of the open file,
+	// which is extended as it grows until it covers FingerprintBytes.
+	fp     uint64
+	fpSize int64
+
+	// mu guards the fields above while polling, so they can be read
+	// by other goroutines.
 	mu sync.Mutex
 }
 
+// NotifyStatus is whether a Watcher from NewAutoWatcher or
+// NewInotifyWatcher is using filesystem notifications, which is reported
+// by its NotifyStatus method. It can be reached from a LineReader with
+// LineReader.Watcher().(interface{ NotifyStatus() NotifyStatus }).
+type NotifyStatus struct {
+	// Notify is true if notifications are used, and false if changes
+	// are only found by polling every Interval.
+	Notify bool
+	// Reason is why it's only polling, such as the filesystem being one
+	// that notifications aren't reliable on. It's empty if notifications
+	// weren't tried.
+	Reason string
+}
+
+// NotifyStatus returns whether notifications are used.
+func (p *pollWatcher) NotifyStatus() NotifyStatus {
+	return p.notify
+}
+
+// pollRequest is a call to Wait, which is sent a pollResult once there
+// is more to read, ctx is done, or the watcher is closed.
+type pollRequest struct {
+	ctx    context.Context
+	result chan pollResult
+}
+
+type pollResult struct {
+	s      WaitStatus
+	closed bool
+	err    error
+}
+
 // NewPollingWatcher configures a Watcher that uses file polling
-// to determine when there is more data to read. It doesn't support
-// files that were truncated, and only supports regular files (no pipes).
+// to determine when there is more data to read. A file that's truncated
+// while open is handled according to Config.Truncation, and only regular
+// files are supported (no pipes). Polling happens on a goroutine that runs
+// until the Watcher is closed, or with Config.Scheduler if it's set.
 func NewPollingWatcher(c Config) (Watcher, error) {
+	p, err := newPollWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+	p.start()
+	return p, nil
+}
+
+// newPollWatcher validates c and returns a pollWatcher that doesn't poll
+// until it's started.
+func newPollWatcher(c Config) (*pollWatcher, error) {
 	if !(c.Whence == io.SeekStart ||
 		c.Whence == io.SeekCurrent ||
 		c.Whence == io.SeekEnd) {
-		return nil, fmt.Errorf("config value for whence of %v is invalid", c.Whence)
+		return nil, fmt.Errorf("config value for %w: %v", ErrBadWhence, c.Whence)
+	}
+
+	if err := c.checkOffset(); err != nil {
+		return nil, err
 	}
 
 	if c.Interval < 0 {
@@ -39,152 +182,727 @@ func NewPollingWatcher(c Config) (Watcher, error) {
 		c.Interval = time.Second
 	}
 
+	if c.NotifyDelay < 0 {
+		return nil, errors.New("config value for notify delay cannot be negative")
+	} else if c.NotifyDelay == 0 {
+		c.NotifyDelay = time.Millisecond * 10
+	}
+
+	if c.TraceSize < 0 {
+		return nil, errors.New("config value for trace size cannot be negative")
+	}
+
+	if c.MaxInterval < 0 {
+		return nil, errors.New("config value for max interval cannot be negative")
+	} else if c.MaxInterval > 0 && c.MaxInterval < c.Interval {
+		return nil, errors.New("config value for max interval cannot be less than interval")
+	}
+
+	if c.TailLines < 0 {
+		return nil, errors.New("config value for tail lines cannot be negative")
+	}
+
+	if c.FingerprintBytes < 0 {
+		return nil, errors.New("config value for fingerprint bytes cannot be negative")
+	}
+
+	if c.MustExistTimeout < 0 {
+		return nil, errors.New("config value for must exist timeout cannot be negative")
+	}
+
 	if c.Path == "" {
 		return nil, errors.New("config value for path cannot be empty")
 	}
 
+	if c.SameFile == nil {
+		c.SameFile = FileState.sameFile
+	}
+
 	p := &pollWatcher{
-		c:      c,
-		timer:  time.NewTimer(0),
-		cancel: make(chan struct{}),
+		c:        c,
+		requests: make(chan pollRequest),
+		cancel:   make(chan struct{}),
+		done:     make(chan struct{}),
+		traced:   history{max: c.TraceSize},
 	}
-	// No way to create a timer without an initial tick, so drain it.
-	<-p.timer.C
+
+	if c.Scheduler != nil {
+		if c.Clock == nil {
+			p.c.Clock = c.Scheduler.clock
+		} else if c.Clock != c.Scheduler.clock {
+			return nil, errors.New("config value for clock must be the same as the scheduler's")
+		}
+		p.sched = c.Scheduler
+		p.turn = make(chan struct{}, 1)
+	}
+
+	p.created = p.c.clock().Now()
 	return p, nil
 }
 
+// start polls until p is closed, on a goroutine of its own or with its
+// Scheduler.
+func (p *pollWatcher) start() {
+	if p.sched == nil {
+		go p.run()
+		return
+	}
+
+	// Notifications still need a goroutine each to pass them on.
+	if p.wake != nil {
+		go func() {
+			for {
+				select {
+				case <-p.cancel:
+					return
+				case <-p.wake:
+					if !p.delayWake() {
+						return
+					}
+					p.sched.hurry(p)
+				}
+			}
+		}()
+	}
+}
+
+// Wait is safe to call from multiple goroutines, though each call is
+// served in turn and they share the position of the open file.
 func (p *pollWatcher) Wait() (s WaitStatus, closed bool, err error) {
-	p.mu.Lock()
+	return p.WaitContext(context.Background())
+}
+
+// WaitContext is Wait, but returns ctx.Err() if ctx is done before
+// there is more data to read.
+func (p *pollWatcher) WaitContext(ctx context.Context) (s WaitStatus, closed bool, err error) {
+	if p.sched != nil {
+		return p.sched.wait(ctx, p)
+	}
+
+	req := pollRequest{ctx: ctx, result: make(chan pollResult, 1)}
+
+	select {
+	case p.requests <- req:
+	case <-p.done:
+		return s, true, nil
+	case <-ctx.Done():
+		return s, false, ctx.Err()
+	}
+
+	// Once accepted, a request is always answered.
+	r := <-req.result
+	return r.s, r.closed, r.err
+}
+
+func (p *pollWatcher) run() {
+	defer close(p.done)
+
+	ticker := p.c.clock().NewTicker(p.c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.cancel:
+			return
+		case req := <-p.requests:
+			req.result <- p.serve(req.ctx, ticker.C())
+		}
+	}
+}
+
+// delayWake waits for NotifyDelay after a wake, so any more during it are
+// coalesced into one poll. It returns false if p was closed first.
+func (p *pollWatcher) delayWake() bool {
+	timer := p.c.clock().NewTimer(p.c.NotifyDelay)
+	defer timer.Stop()
+
+	select {
+	case <-p.cancel:
+		return false
+	case <-timer.C():
+		return true
+	}
+}
+
+// serve polls on every tick or wake until there is a result for a request.
+// A wake only polls after NotifyDelay, and wakes before then are ignored.
+func (p *pollWatcher) serve(ctx context.Context, tick <-chan time.Time) pollResult {
+	var delay Timer
+	var delayed <-chan time.Time
 	defer func() {
-		if !p.timer.Stop() {
-			select {
-			case <-p.timer.C:
-			default:
-			}
+		if delay != nil {
+			delay.Stop()
 		}
-		p.mu.Unlock()
 	}()
 
 	for {
-		p.timer.Reset(p.c.Interval)
-
-		p.mu.Unlock()
 		select {
 		case <-p.cancel:
-		case <-p.timer.C:
+			return pollResult{closed: true}
+		case <-ctx.Done():
+			return pollResult{err: ctx.Err()}
+		case <-tick:
+			if p.c.clock().Now().Before(p.retryAt) {
+				continue
+			}
+			if p.skip > 0 {
+				p.skip--
+				continue
+			}
+		case <-p.wake:
+			if delay == nil {
+				delay = p.c.clock().NewTimer(p.c.NotifyDelay)
+				delayed = delay.C()
+			}
+			continue
+		case <-delayed:
+			delay, delayed = nil, nil
 		}
-		p.mu.Lock()
 
-		if p.closed {
-			return s, true, nil
+		r, ok := p.poll()
+		p.adapt(ok)
+		if ok {
+			return r
 		}
+	}
+}
 
-		if p.f == nil {
-			f, err := p.openAndSeek()
-			if os.IsNotExist(err) {
-				p.c.Whence = io.SeekStart
-				continue
-			}
+// adapt doubles the number of ticks until the next poll after one that
+// found nothing, up to MaxInterval, and polls every tick again after one
+// that found something.
+func (p *pollWatcher) adapt(found bool) {
+	if p.c.MaxInterval <= 0 || found {
+		p.idle, p.skip = 0, 0
+		return
+	}
+
+	max := int(p.c.MaxInterval / p.c.Interval)
+	p.idle *= 2
+	if p.idle == 0 {
+		p.idle = 1
+	}
+	if p.idle > max {
+		p.idle = max
+	}
+	p.skip = p.idle - 1
+}
+
+// poll checks the open file for more data, or the path for a new one,
+// returning false if there is nothing to report yet.
+func (p *pollWatcher) poll() (r pollResult, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return pollResult{closed: true}, true
+	}
 
-			if err != nil {
-				return s, p.closed, err
+	r, ok = p.check()
+	if !ok {
+		p.evict()
+	}
+	p.count(r, ok)
+	return r, ok
+}
+
+// check is poll with mu held.
+func (p *pollWatcher) check() (r pollResult, ok bool) {
+	if p.f == nil {
+		if p.parked != nil {
+			return p.resume()
+		}
+		if !p.c.FileLimit.acquire() {
+			p.trace("file limit reached")
+			return r, false
+		}
+
+		p.stats.Reopens++
+		f, err := p.openAndSeek()
+		if err != nil {
+			p.c.FileLimit.release()
+		}
+		if os.IsNotExist(err) {
+			p.trace("open found no file")
+			if p.c.MustExist && !p.found {
+				if waited := p.c.clock().Now().Sub(p.created); waited >= p.c.MustExistTimeout {
+					return pollResult{err: &FileNotFoundError{Path: p.c.Path, Waited: waited}}, true
+				}
 			}
 
-			// TODO: refactor openAndSeek to provide this.
-			s.State, err = NewFileState(f)
-			if err != nil {
-				return s, p.closed, err
+			p.c.Whence = io.SeekStart
+			p.c.Offset = 0
+			p.c.TailLines = 0
+			if p.c.Backoff != nil {
+				after := p.c.Backoff(p.missing)
+				p.retryAt = p.c.clock().Now().Add(after)
+				p.missing++
+				p.debug("file not found, retrying", "attempts", p.missing, "after", after)
 			}
+			return r, false
+		}
+		p.missing = 0
+		p.retryAt = time.Time{}
 
-			p.f = f
-			s.File = f
-			s.ReOpened = true
-			return s, false, err
+		if err != nil {
+			p.debug("opening file failed", "err", err)
+			return pollResult{err: err}, true
 		}
+		p.found = true
 
-		s.File = p.f
-		s.State, err = NewFileState(p.f)
+		// TODO: refactor openAndSeek to provide this.
+		p.fp, p.fpSize = 0, 0
+		r.s.State, err = p.fileState(f)
 		if err != nil {
-			return s, false, err
+			f.Close()
+			p.c.FileLimit.release()
+			return pollResult{err: err}, true
 		}
 
-		if s.State.Size > s.State.Position {
-			return s, false, nil
+		p.growth.sample(r.s.State, p.c.clock().Now())
+		p.readTo = r.s.State.Position
+
+		if stat, err := f.Stat(); err == nil {
+			p.mode = stat.Mode()
 		}
 
+		p.f = f
+		r.s.File = f
+		r.s.Event = Created
+		if p.finished != nil {
+			r.s.Event = Rotated
+		}
+		r.s.ReOpened = true
+		r.s.RotatedName = p.rotatedName
+		p.rotatedName = ""
+		p.removed = false
+		p.opened(r.s.State)
+		return r, true
+	}
+
+	var err error
+	r.s.File = p.f
+	r.s.State, err = p.fileState(p.f)
+	if err != nil {
+		return pollResult{s: r.s, err: err}, true
+	}
+	p.growth.sample(r.s.State, p.c.clock().Now())
+	p.countRead(r.s.State.Position)
+	p.trace("stat open file", "size", r.s.State.Size, "position", r.s.State.Position)
+
+	if r.s.State.Size < r.s.State.Position {
+		return p.truncate(r.s)
+	}
+	p.truncated = false
+
+	if r.s.State.Size > r.s.State.Position {
+		return r, true
+	}
+
+	if r, ok := p.checkReadable(r.s); ok {
+		return r, true
+	}
+
+	retargeted, err := p.retargeted()
+	if err != nil {
+		return pollResult{s: r.s, err: err}, true
+	}
+
+	if retargeted {
+		p.trace("path points to a different file")
+	} else {
 		stateNamed, err := NewFileStateFromPath(p.c.Path)
 		// Inode should never be the same if they are two different files
 		// since we have the old file open, keeping a reference to it on
 		// disk. Usually rotation moves files anyways, which should keep
 		// the inode in most situations.
-		if err == nil && s.State.Inode == stateNamed.Inode {
-			continue
+		if err == nil {
+			same := p.c.SameFile(r.s.State, *stateNamed)
+			p.trace("stat path", "state", stateNamed, "same", same)
+			if same {
+				return r, false
+			}
 		} else if os.IsNotExist(err) {
-			continue
+			p.trace("stat path found no file")
+			return p.remove(r)
 		} else if err != nil {
-			return s, false, err
-		}
-
-		// If we get here, the named file is different from the one
-		// currently open (it was rotated). However, it is possible
-		// for there to be a race. Between when the open file is checked
-		// for size, and the check for a replacement file, the current
-		// open file could have had bytes written to it before rotation.
-		// So to make sure we get all the data, ignore the latest file
-		// on disk until our position matches the size of the old file
-		// by checking the size again.
-		s.State, err = NewFileState(p.f)
+			return pollResult{s: r.s, err: err}, true
+		}
+	}
+
+	// If we get here, the named file is different from the one
+	// currently open (it was rotated). However, it is possible
+	// for there to be a race. Between when the open file is checked
+	// for size, and the check for a replacement file, the current
+	// open file could have had bytes written to it before rotation.
+	// So to make sure we get all the data, ignore the latest file
+	// on disk until our position matches the size of the old file
+	// by checking the size again.
+	r.s.State, err = p.fileState(p.f)
+	if err != nil {
+		return pollResult{s: r.s, err: err}, true
+	}
+
+	if r.s.State.Size > r.s.State.Position {
+		return r, true
+	}
+
+	if p.waitForNewline(r.s.State) {
+		p.trace("waiting for a newline before rotating", "position", r.s.State.Position)
+		return r, false
+	}
+
+	// There is a new file on disk and we have read up to the
+	// end of the open one, so close it and reset for the next.
+	if retargeted {
+		// The file wasn't renamed, the path just points somewhere else.
+		p.rotatedName = p.target
+	} else {
+		p.rotatedName = findRenamed(filepath.Dir(p.target), r.s.State)
+	}
+	p.f.Close()
+	p.f = nil
+	p.c.FileLimit.release()
+	p.graceStart = time.Time{}
+	p.stats.Rotations++
+	p.debug("rotation detected", "rotated_to", p.rotatedName, "position", r.s.State.Position, "retargeted", retargeted)
+	finished := r.s.State
+	p.finished = &finished
+
+	// The new file is already there, so open it right away.
+	return p.check()
+}
+
+// resolve returns the name to open for the path, which is what it links
+// to with FollowSymlinks.
+func (p *pollWatcher) resolve() (string, error) {
+	if !p.c.FollowSymlinks {
+		return p.c.Path, nil
+	}
+	return filepath.EvalSymlinks(p.c.Path)
+}
+
+// retargeted reports whether the path links to a different name than the
+// open file was opened with, when FollowSymlinks is set. A link that
+// doesn't resolve is left to be checked like a missing path.
+func (p *pollWatcher) retargeted() (bool, error) {
+	if !p.c.FollowSymlinks {
+		return false, nil
+	}
+
+	target, err := p.resolve()
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return target != p.target, nil
+}
+
+// fileState returns the state of f with its fingerprint, if
+// FingerprintBytes is set.
+func (p *pollWatcher) fileState(f *os.File) (FileState, error) {
+	s, err := NewFileState(f)
+	if err != nil || p.c.FingerprintBytes <= 0 {
+		return s, err
+	}
+
+	if p.fpSize < p.c.FingerprintBytes && s.Size > p.fpSize {
+		size := s.Size
+		if size > p.c.FingerprintBytes {
+			size = p.c.FingerprintBytes
+		}
+
+		sum, ok, err := fingerprint(f, size)
 		if err != nil {
-			return s, false, err
+			return s, err
+		} else if ok {
+			p.fp, p.fpSize = sum, size
 		}
+	}
+
+	s.Fingerprint, s.FingerprintSize = p.fp, p.fpSize
+	return s, nil
+}
 
-		if s.State.Size > s.State.Position {
-			return s, false, nil
+// truncate applies Config.Truncation to the open file, which s shows
+// is smaller than the position read up to.
+func (p *pollWatcher) truncate(s WaitStatus) (r pollResult, ok bool) {
+	// With TruncateError, this is called again after returning it.
+	if !p.truncated {
+		p.debug("truncation detected", "size", s.State.Size, "position", s.State.Position)
+		if p.c.OnTruncate != nil {
+			p.c.OnTruncate(s.State)
 		}
+	}
 
-		// There is a new file on disk and we have read up to the
-		// end of the open one, so close it and reset for the next.
-		p.f.Close()
-		p.f = nil
+	if p.c.Truncation == TruncateError && !p.truncated {
+		p.truncated = true
+		return pollResult{s: s, err: &TruncatedError{
+			Path:     p.c.Path,
+			Size:     s.State.Size,
+			Position: s.State.Position,
+		}}, true
+	}
+	p.truncated = false
+	// The start of the file is being rewritten.
+	p.fp, p.fpSize = 0, 0
+
+	whence := io.SeekStart
+	if p.c.Truncation == TruncateSeekEnd {
+		whence = io.SeekEnd
+	}
+
+	var err error
+	if s.State.Position, err = p.f.Seek(0, whence); err != nil {
+		return pollResult{s: s, err: err}, true
+	}
+
+	s.Event = Truncated
+	s.ReOpened = true
+	s.Truncated = true
+	return pollResult{s: s}, true
+}
+
+// checkReadable returns an UnreadableError once the mode of the open file
+// changes so that it couldn't be opened again, such as when it's chmodded
+// while it's read. The open file can still be read, but the same file
+// after a restart, or the next one the path refers to, likely couldn't.
+func (p *pollWatcher) checkReadable(s WaitStatus) (r pollResult, ok bool) {
+	stat, err := p.f.Stat()
+	if err != nil {
+		return pollResult{s: s, err: err}, true
+	} else if stat.Mode() == p.mode {
+		return r, false
+	}
+	p.mode = stat.Mode()
+
+	f, err := openFile(p.target)
+	if os.IsPermission(err) {
+		p.debug("open file became unreadable", "mode", p.mode)
+		return pollResult{s: s, err: &UnreadableError{Path: p.c.Path, Mode: p.mode, Err: err}}, true
+	} else if err == nil {
+		f.Close()
+	}
+	return r, false
+}
+
+// remove returns a Removed event the first time the open file is found
+// to be deleted while nothing is at the path. A file that was renamed
+// is still waited on to be replaced.
+func (p *pollWatcher) remove(r pollResult) (pollResult, bool) {
+	if p.removed {
+		return r, false
 	}
+
+	unlinked, err := fileUnlinked(p.f)
+	if err != nil {
+		return pollResult{s: r.s, err: err}, true
+	} else if !unlinked {
+		return r, false
+	}
+
+	p.removed = true
+	p.debug("file removed", "position", r.s.State.Position)
+	if p.c.OnRemove != nil {
+		p.c.OnRemove(r.s.State)
+	}
+	r.s.Event = Removed
+	return r, true
+}
+
+// opened calls OnOpen for the file just opened, and OnRotate if it
+// replaced one.
+func (p *pollWatcher) opened(state FileState) {
+	p.debug("opened file", "name", p.target, "position", state.Position, "size", state.Size)
+	if p.c.OnOpen != nil {
+		p.c.OnOpen(p.target, state)
+	}
+	if p.onOpen != nil {
+		p.onOpen(state)
+	}
+
+	if p.finished != nil {
+		if p.c.OnRotate != nil {
+			p.c.OnRotate(*p.finished, state)
+		}
+		p.finished = nil
+	}
+}
+
+// findRenamed looks for a file in dir with the same identity as state,
+// returning its path or an empty string if there isn't one.
+func findRenamed(dir string, state FileState) string {
+	if state.ID().IsZero() {
+		return ""
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		name := filepath.Join(dir, info.Name())
+		id, err := pathID(name, info)
+		if err == nil && id.Equal(state.ID()) {
+			return name
+		}
+	}
+	return ""
+}
+
+// waitForNewline returns true if the open file ends with a partial line
+// and it hasn't been PartialLineGrace since a rotation was first seen.
+func (p *pollWatcher) waitForNewline(s FileState) bool {
+	if p.c.PartialLineGrace <= 0 || s.Size == 0 {
+		return false
+	}
+
+	if p.graceStart.IsZero() {
+		p.graceStart = p.c.clock().Now()
+	}
+
+	if p.c.clock().Now().Sub(p.graceStart) >= p.c.PartialLineGrace {
+		return false
+	}
+
+	delim := p.c.delimiter()
+	if s.Size < int64(len(delim)) {
+		return true
+	}
+
+	b := make([]byte, len(delim))
+	if _, err := p.f.ReadAt(b, s.Size-int64(len(delim))); err != nil {
+		return false
+	}
+	return !bytes.Equal(b, delim)
+}
+
+// seekLastLines seeks f to the start of its last TailLines lines.
+func (p *pollWatcher) seekLastLines(f *os.File) error {
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset, err := lastLinesOffset(f, stat.Size(), p.c.TailLines, p.c.delimiter())
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Seek(offset, io.SeekStart)
+	return err
 }
 
 func (p *pollWatcher) openAndSeek() (f *os.File, err error) {
-	f, err = os.Open(p.c.Path)
+	target, err := p.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err = openFile(target)
+	if os.IsPermission(err) {
+		// Distinguish a file that exists but had its mode changed from
+		// one that is missing, so callers can apply their own policy.
+		unreadable := &UnreadableError{Path: p.c.Path, Err: err}
+		if stat, statErr := os.Stat(p.c.Path); statErr == nil {
+			unreadable.Mode = stat.Mode()
+		}
+		return nil, unreadable
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if p.c.StartState != nil {
-		_, _, err = p.c.StartState.SeekIfMatches(f)
+	if p.c.LazyBackfill {
+		if err = p.skipHistory(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		p.c.LazyBackfill = false
+		p.c.StartState = nil
+		p.c.TailLines = 0
+		p.c.Whence = io.SeekStart
+		p.c.Offset = 0
+	} else if p.c.StartState != nil {
+		var found FileState
+		var matches bool
+		found, matches, err = p.c.StartState.SeekIfMatches(f)
+		if err == nil && !matches {
+			var start int64
+			if start, err = p.c.mismatchStart(found); err == nil {
+				_, err = f.Seek(start, io.SeekStart)
+			} else if errors.Is(err, ErrStartMismatch) {
+				// It's only returned once, and the file is then read
+				// from the start.
+				p.c.StartState = nil
+				p.c.TailLines = 0
+				p.c.Whence = io.SeekStart
+				p.c.Offset = 0
+			}
+		}
 		if err != nil {
 			f.Close()
 			return nil, err
 		}
 
 		p.c.StartState = nil
+		p.c.TailLines = 0
+		p.c.Whence = io.SeekStart
+		p.c.Offset = 0
+	} else if p.c.TailLines > 0 {
+		if err = p.seekLastLines(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		p.c.TailLines = 0
 		p.c.Whence = io.SeekStart
-	} else if p.c.Whence != io.SeekStart {
-		_, err = f.Seek(0, p.c.Whence)
+		p.c.Offset = 0
+	} else if p.c.Whence != io.SeekStart || p.c.Offset != 0 {
+		var stat os.FileInfo
+		if stat, err = f.Stat(); err == nil {
+			_, err = f.Seek(p.c.startOffset(stat.Size()), io.SeekStart)
+		}
 		if err != nil {
 			f.Close()
 			return nil, err
 		}
 		p.c.Whence = io.SeekStart
+		p.c.Offset = 0
 	}
 
+	p.target = target
 	return f, nil
 }
 
 func (p *pollWatcher) Close() error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
 	if !p.closed {
 		p.closed = true
 		close(p.cancel)
+		if p.sched != nil {
+			close(p.done)
+		}
 	}
+	p.mu.Unlock()
+
+	// The file is only closed once the poll goroutine stops using it.
+	// A Scheduler only polls with mu held, which sees it's closed.
+	<-p.done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.f != nil {
-		return p.f.Close()
+		err := p.f.Close()
+		p.f = nil
+		p.c.FileLimit.release()
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,208 @@
+package tail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ReverseLineReader reads the lines of a file backwards from the end,
+// returning them newest first without reading the whole file, such as to
+// find the last few errors in a log. It reads what was in the file when
+// it was created, so lines written later and rotations aren't seen.
+type ReverseLineReader struct {
+	c     Config
+	f     *os.File
+	delim []byte
+	state FileState
+
+	// buf is what was read from the file but not returned yet, which
+	// starts at bufStart and ends with the line Next returns next.
+	// Delimiters can only start in the first unsearched bytes of it,
+	// since the rest was already searched.
+	buf        []byte
+	bufStart   int64
+	unsearched int
+
+	line       []byte
+	lineOffset int64
+	lineEnd    int64
+	terminated bool
+	readTime   time.Time
+
+	err error
+}
+
+// NewReverseLineReader opens the file at c.Path to read backwards from
+// its end. Lines end with c.Delimiter like with LineReader, and if
+// StartState is set and matches the file, reading starts from its
+// Position instead, such as to read back from where a LineReader left
+// off. Encoding isn't supported, and the other options don't apply.
+func NewReverseLineReader(c Config) (*ReverseLineReader, error) {
+	if c.Path == "" {
+		return nil, errors.New("config value for path cannot be empty")
+	}
+
+	if c.Encoding != nil {
+		return nil, errors.New("config value for encoding is not supported when reading backwards")
+	}
+
+	f, err := openFile(c.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := NewFileState(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	state.Position = state.Size
+	if c.StartState != nil {
+		s, ok, err := c.StartState.SeekIfMatches(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		} else if ok {
+			state = s
+		}
+	}
+
+	return &ReverseLineReader{
+		c:        c,
+		f:        f,
+		delim:    c.textDelimiter(),
+		state:    state,
+		bufStart: state.Position,
+	}, nil
+}
+
+// Next reads the line before the one it last returned, returning false
+// once the start of the file is reached or there was an error.
+func (r *ReverseLineReader) Next() bool {
+	end := r.bufStart + int64(len(r.buf))
+	if r.err != nil || end == 0 {
+		return false
+	}
+
+	// The line ends before its delimiter, which the last line may not
+	// have.
+	for len(r.buf) < len(r.delim) && r.bufStart > 0 {
+		if !r.readBlock() {
+			return false
+		}
+	}
+	contentEnd := len(r.buf)
+	r.terminated = bytes.HasSuffix(r.buf, r.delim)
+	if r.terminated {
+		contentEnd -= len(r.delim)
+	}
+
+	for {
+		// A delimiter starting in the unsearched bytes can end in the
+		// ones after them.
+		limit := r.unsearched + len(r.delim) - 1
+		if limit > contentEnd {
+			limit = contentEnd
+		}
+
+		if i := bytes.LastIndex(r.buf[:limit], r.delim); i >= 0 {
+			start := i + len(r.delim)
+			r.setLine(start, contentEnd, end)
+			r.buf = r.buf[:start]
+			r.unsearched = i
+			return true
+		}
+
+		if r.bufStart == 0 {
+			r.setLine(0, contentEnd, end)
+			r.buf = r.buf[:0]
+			r.unsearched = 0
+			return true
+		}
+
+		// The whole line isn't in buf yet.
+		r.unsearched = 0
+		n := len(r.buf)
+		if !r.readBlock() {
+			return false
+		}
+		contentEnd += len(r.buf) - n
+	}
+}
+
+// setLine sets the line returned by Next to buf[start:contentEnd], which
+// ends at end in the file including its delimiter.
+func (r *ReverseLineReader) setLine(start, contentEnd int, end int64) {
+	r.line = r.buf[start:contentEnd]
+	if len(r.c.Delimiter) == 0 && r.terminated {
+		r.line = bytes.TrimSuffix(r.line, []byte{'\r'})
+	}
+	r.lineOffset = r.bufStart + int64(start)
+	r.lineEnd = end
+	r.readTime = r.c.clock().Now()
+}
+
+// readBlock reads the block before buf into the start of it, which is
+// yet to be searched, returning false if there was an error.
+func (r *ReverseLineReader) readBlock() bool {
+	start := r.bufStart - reverseBlockSize
+	if start < 0 {
+		start = 0
+	}
+
+	buf := make([]byte, int(r.bufStart-start)+len(r.buf))
+	if _, err := r.f.ReadAt(buf[:r.bufStart-start], start); err != nil && err != io.EOF {
+		r.err = err
+		return false
+	}
+
+	copy(buf[r.bufStart-start:], r.buf)
+	r.unsearched += int(r.bufStart - start)
+	r.buf = buf
+	r.bufStart = start
+	return true
+}
+
+// Bytes returns the line last returned by Next without its delimiter,
+// which is only valid until Next is called again.
+func (r *ReverseLineReader) Bytes() []byte {
+	return r.line
+}
+
+// Line returns the line last returned by Next along with where it was
+// read from. Its State has the Position of the end of the line, and
+// Partial is set for a last line that didn't end with the delimiter.
+// Line.Bytes is only valid until Next is called again.
+func (r *ReverseLineReader) Line() Line {
+	state := r.state
+	state.Position = r.lineEnd
+	return Line{
+		Path:       r.c.Path,
+		Bytes:      r.line,
+		Offset:     r.lineOffset,
+		State:      state,
+		Generation: 1,
+		Partial:    !r.terminated,
+		ReadTime:   r.readTime,
+	}
+}
+
+// FileState returns the state of the file when it was opened, with the
+// Position reading backwards started from.
+func (r *ReverseLineReader) FileState() FileState {
+	return r.state
+}
+
+// Err returns the error that caused Next to return false, if any.
+func (r *ReverseLineReader) Err() error {
+	return r.err
+}
+
+// Close closes the file.
+func (r *ReverseLineReader) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,94 @@
+package tail
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readReverse(t *testing.T, c Config) []string {
+	t.Helper()
+
+	r, err := NewReverseLineReader(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	lines := []string{}
+	for r.Next() {
+		lines = append(lines, string(r.Bytes()))
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestReverseLineReader(t *testing.T) {
+	long := strings.Repeat("x", reverseBlockSize*2+10)
+	// The first delimiter is split between the last two blocks.
+	split := strings.Repeat("y", reverseBlockSize-4)
+
+	for _, tc := range []struct {
+		name     string
+		contents string
+		delim    string
+		expected []string
+	}{
+		{"empty", "", "", []string{}},
+		{"terminated", "a\nb\nc\n", "", []string{"c", "b", "a"}},
+		{"unterminated", "a\nb\nc", "", []string{"c", "b", "a"}},
+		{"empty lines", "\n\na\n\n", "", []string{"", "a", "", ""}},
+		{"crlf", "a\r\nb\r\n", "", []string{"b", "a"}},
+		{"long line", "a\n" + long + "\nb\n", "", []string{"b", long, "a"}},
+		{"delimiter", "a||b||c", "||", []string{"c", "b", "a"}},
+		{"split delimiter", "a||" + split + "||b", "||", []string{"b", split, "a"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "reverse")
+			if err := ioutil.WriteFile(path, []byte(tc.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := readReverse(t, Config{Path: path, Delimiter: []byte(tc.delim)})
+			if !reflect.DeepEqual(lines, tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, lines)
+			}
+		})
+	}
+}
+
+func TestReverseLineReaderStartState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reverse")
+	if err := ioutil.WriteFile(path, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReverseLineReader(Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if !r.Next() {
+		t.Fatal("expected a line")
+	}
+	line := r.Line()
+	if string(line.Bytes) != "c" || line.Offset != 4 || line.State.Position != 6 {
+		t.Fatalf("expected c at 4 ending at 6, got %q at %v ending at %v", line.Bytes, line.Offset, line.State.Position)
+	}
+
+	// Starting from the end of b only reads the lines before it.
+	if !r.Next() {
+		t.Fatal("expected a line")
+	}
+	state := r.Line().State
+
+	lines := readReverse(t, Config{Path: path, StartState: &state})
+	if expected := []string{"b", "a"}; !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected %q, got %q", expected, lines)
+	}
+}
@@ -0,0 +1,383 @@
+package tail
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Scheduler polls files for many polling Watchers with a fixed number of
+// goroutines and a single timer, rather than a goroutine and ticker for
+// each, for following hundreds or thousands of files such as every file
+// in a directory. Set it as Config.Scheduler for each Watcher or
+// LineReader that should share it.
+//
+// Each Wait is polled every Interval on one of the workers until there's
+// something to return, so a slow filesystem holds up the polls of other
+// files once every worker is busy. The waits that are due are polled in
+// the order they became due, so no file is polled twice while another
+// is left waiting.
+type Scheduler struct {
+	clock Clock
+	work  chan *schedWait
+	// reads holds a value for each read in progress with Readers, or is
+	// nil if reads aren't limited.
+	reads chan struct{}
+	// wake is sent to when the earliest wait changes, to reset the
+	// timer.
+	wake   chan struct{}
+	cancel chan struct{}
+	wg     sync.WaitGroup
+
+	// mu guards the fields below, and the waiting and woken fields of
+	// each pollWatcher using the Scheduler.
+	mu     sync.Mutex
+	waits  waitHeap
+	closed bool
+}
+
+// SchedulerConfig configures a Scheduler.
+type SchedulerConfig struct {
+	// Workers is how many goroutines poll files. If 0, GOMAXPROCS is
+	// used.
+	Workers int
+
+	// Readers caps how many files using the Scheduler are read from at
+	// once by LineReaders, each reading at most Config.BufferSize before
+	// the files waiting to read take their turns in order. If 0, reads
+	// aren't limited, and only polling is spread over the workers.
+	Readers int
+
+	// Clock is optional and replaces the time package, like
+	// Config.Clock, which defaults to it for Watchers using the
+	// Scheduler.
+	Clock Clock
+}
+
+// NewScheduler validates c and starts the goroutines of a Scheduler,
+// which run until it's closed.
+func NewScheduler(c SchedulerConfig) (*Scheduler, error) {
+	if c.Workers < 0 {
+		return nil, errors.New("config value for workers cannot be negative")
+	} else if c.Workers == 0 {
+		c.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	if c.Readers < 0 {
+		return nil, errors.New("config value for readers cannot be negative")
+	}
+
+	if c.Clock == nil {
+		c.Clock = realClock{}
+	}
+
+	s := &Scheduler{
+		clock:  c.Clock,
+		work:   make(chan *schedWait),
+		wake:   make(chan struct{}, 1),
+		cancel: make(chan struct{}),
+	}
+
+	if c.Readers > 0 {
+		s.reads = make(chan struct{}, c.Readers)
+	}
+
+	s.wg.Add(c.Workers + 1)
+	for i := 0; i < c.Workers; i++ {
+		go s.poll()
+	}
+	go s.run()
+	return s, nil
+}
+
+// Close stops the Scheduler once the polls in progress finish. Wait
+// returns closed for every Watcher using it from then on, though each
+// Watcher still needs to be closed.
+func (s *Scheduler) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.cancel)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, w := range s.waits {
+		w.p.waiting = nil
+		w.result <- pollResult{closed: true}
+	}
+	s.waits = nil
+	return nil
+}
+
+// schedWait is a call to Wait on a pollWatcher using a Scheduler, which
+// is polled every Interval until there's a result for it.
+type schedWait struct {
+	p      *pollWatcher
+	ctx    context.Context
+	result chan pollResult
+
+	// at is when to poll next, and woken is set to poll without waiting
+	// for Backoff or MaxInterval, like a wake does.
+	at    time.Time
+	woken bool
+	// index is where it is in the heap, or -1 when it isn't in it.
+	index int
+}
+
+// wait is WaitContext for p, which only has one Wait polled at a time.
+func (s *Scheduler) wait(ctx context.Context, p *pollWatcher) (WaitStatus, bool, error) {
+	select {
+	case p.turn <- struct{}{}:
+	case <-p.done:
+		return WaitStatus{}, true, nil
+	case <-ctx.Done():
+		return WaitStatus{}, false, ctx.Err()
+	}
+	defer func() { <-p.turn }()
+
+	w := &schedWait{p: p, ctx: ctx, result: make(chan pollResult, 1), index: -1}
+	s.mu.Lock()
+	w.at = s.clock.Now().Add(p.c.Interval)
+	s.add(w)
+	s.mu.Unlock()
+
+	var r pollResult
+	select {
+	case r = <-w.result:
+	case <-ctx.Done():
+		r = s.withdraw(w, pollResult{err: ctx.Err()})
+	case <-p.cancel:
+		r = s.withdraw(w, pollResult{closed: true})
+	}
+	return r.s, r.closed, r.err
+}
+
+// add schedules w to be polled at w.at, with mu held. It's answered
+// right away instead if it was cancelled or there's nothing left to poll.
+func (s *Scheduler) add(w *schedWait) {
+	select {
+	case <-w.p.cancel:
+		w.result <- pollResult{closed: true}
+		return
+	default:
+	}
+
+	if s.closed {
+		w.result <- pollResult{closed: true}
+		return
+	} else if err := w.ctx.Err(); err != nil {
+		w.result <- pollResult{err: err}
+		return
+	}
+
+	if w.p.woken {
+		w.p.woken = false
+		w.woken = true
+		w.at = s.clock.Now()
+	}
+
+	w.p.waiting = w
+	heap.Push(&s.waits, w)
+	if w.index == 0 {
+		s.reset()
+	}
+}
+
+// withdraw takes w out of the heap and returns r, unless it's being
+// polled, in which case it returns the result of that.
+func (s *Scheduler) withdraw(w *schedWait, r pollResult) pollResult {
+	s.mu.Lock()
+	if w.index >= 0 {
+		heap.Remove(&s.waits, w.index)
+		w.p.waiting = nil
+		s.mu.Unlock()
+		return r
+	}
+	s.mu.Unlock()
+
+	// Polls in progress are always answered.
+	return <-w.result
+}
+
+// hurry polls p right away for the Wait in progress, or the next one if
+// there isn't one waiting.
+func (s *Scheduler) hurry(p *pollWatcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := p.waiting
+	if w == nil {
+		p.woken = true
+		return
+	}
+
+	w.woken = true
+	w.at = s.clock.Now()
+	heap.Fix(&s.waits, w.index)
+	if w.index == 0 {
+		s.reset()
+	}
+}
+
+// reset wakes run to set the timer for the earliest wait, with mu held.
+func (s *Scheduler) reset() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run passes the waits that are due to the workers, then sleeps until
+// the next one is.
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	for {
+		s.mu.Lock()
+		now := s.clock.Now()
+		var due []*schedWait
+		for len(s.waits) > 0 && !s.waits[0].at.After(now) {
+			w := heap.Pop(&s.waits).(*schedWait)
+			w.p.waiting = nil
+			due = append(due, w)
+		}
+
+		var timer Timer
+		var next <-chan time.Time
+		if len(s.waits) > 0 {
+			timer = s.clock.NewTimer(s.waits[0].at.Sub(now))
+			next = timer.C()
+		}
+		s.mu.Unlock()
+
+		for i, w := range due {
+			select {
+			case s.work <- w:
+			case <-s.cancel:
+				for _, w := range due[i:] {
+					w.result <- pollResult{closed: true}
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+		}
+
+		select {
+		case <-next:
+		case <-s.wake:
+		case <-s.cancel:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// poll is a worker, which polls each wait it's passed and either answers
+// it or schedules it again for the next Interval.
+func (s *Scheduler) poll() {
+	defer s.wg.Done()
+
+	for {
+		var w *schedWait
+		select {
+		case <-s.cancel:
+			return
+		case w = <-s.work:
+		}
+
+		// Like serve, the retries of Backoff and the ticks skipped with
+		// MaxInterval are only waited for without a wake.
+		p := w.p
+		switch {
+		case !w.woken && s.clock.Now().Before(p.retryAt):
+		case !w.woken && p.skip > 0:
+			p.skip--
+		default:
+			r, ok := p.poll()
+			p.adapt(ok)
+			if ok {
+				w.result <- r
+				continue
+			}
+		}
+
+		s.mu.Lock()
+		w.woken = false
+		now := s.clock.Now()
+		for !w.at.After(now) {
+			w.at = w.at.Add(p.c.Interval)
+		}
+		s.add(w)
+		s.mu.Unlock()
+	}
+}
+
+// reader returns r, limited to Readers reads at once with the other
+// files using the Scheduler.
+func (s *Scheduler) reader(r io.Reader) io.Reader {
+	if s.reads == nil {
+		return r
+	}
+	return &schedReader{s: s, r: r}
+}
+
+// schedReader waits its turn for each read from r.
+type schedReader struct {
+	s *Scheduler
+	r io.Reader
+}
+
+func (r *schedReader) Read(b []byte) (int, error) {
+	// Blocked sends are served in order, so files take turns. Reads
+	// aren't limited once the Scheduler is closed.
+	select {
+	case r.s.reads <- struct{}{}:
+		defer func() { <-r.s.reads }()
+	case <-r.s.cancel:
+	}
+	return r.r.Read(b)
+}
+
+// waitHeap orders waits by when they're polled next.
+type waitHeap []*schedWait
+
+func (h waitHeap) Len() int           { return len(h) }
+func (h waitHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h waitHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waitHeap) Push(x interface{}) {
+	w := x.(*schedWait)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waitHeap) Pop() interface{} {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
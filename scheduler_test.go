@@ -0,0 +1,244 @@
+package tail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	s, err := NewScheduler(SchedulerConfig{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	onErr := func(e error) error {
+		t.Fatal(e)
+		return e
+	}
+
+	dir := t.TempDir()
+	readers := make([]*LineReader, 50)
+	for i := range readers {
+		c := Config{
+			Path:      filepath.Join(dir, fmt.Sprintf("file-%v", i)),
+			Interval:  time.Millisecond * 10,
+			Scheduler: s,
+		}
+
+		r, err := NewLineReader(c, onErr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		readers[i] = r
+	}
+
+	for i, r := range readers {
+		line := fmt.Sprintf("line %v", i)
+		if err := ioutil.WriteFile(r.c.Path, []byte(line+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		readLine(t, r, line)
+	}
+}
+
+func TestSchedulerRotation(t *testing.T) {
+	s, err := NewScheduler(SchedulerConfig{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	h := NewWatcherHarness(t, "scheduler-rotation-test")
+	w, err := NewPollingWatcher(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		Scheduler: s,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "a")
+	r := h.Wait(w, true, false, nil)
+	expectString(t, r, "a")
+	writer.Close()
+
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "b")
+	r = h.Wait(w, true, false, nil)
+	expectString(t, r, "b")
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s, err := NewScheduler(SchedulerConfig{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	h := NewWatcherHarness(t, "scheduler-cancel-test")
+	w, err := NewPollingWatcher(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		Scheduler: s,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, _, err := w.(ContextWatcher).WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+
+	// Closing the Scheduler ends Waits on every Watcher using it.
+	result := make(chan bool)
+	go func() {
+		_, closed, _ := w.Wait()
+		result <- closed
+	}()
+
+	time.Sleep(time.Millisecond * 20)
+	s.Close()
+	if !<-result {
+		t.Fatal("expected Wait to return closed")
+	}
+}
+
+func TestSchedulerClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	s, err := NewScheduler(SchedulerConfig{Workers: 1, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	h := NewWatcherHarness(t, "scheduler-clock-test")
+	c := Config{
+		Path:      h.Path(),
+		Interval:  time.Second,
+		Scheduler: s,
+	}
+
+	if _, err := NewPollingWatcher(Config{Path: c.Path, Clock: NewManualClock(time.Unix(0, 0)), Scheduler: s}); err == nil {
+		t.Fatal("expected an error for a different clock")
+	}
+
+	w, err := NewPollingWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a")
+
+	result := make(chan error)
+	go func() {
+		_, _, err := w.Wait()
+		result <- err
+	}()
+
+	// The file is only polled once a second has passed on the clock.
+	clock.BlockUntil(1)
+	select {
+	case <-result:
+		t.Fatal("expected Wait to block until the clock advanced")
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	clock.Advance(time.Second)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerConfig(t *testing.T) {
+	if _, err := NewScheduler(SchedulerConfig{Workers: -1}); err == nil {
+		t.Fatal("expected an error for negative workers")
+	}
+	if _, err := NewScheduler(SchedulerConfig{Readers: -1}); err == nil {
+		t.Fatal("expected an error for negative readers")
+	}
+}
+
+// slowReader counts how many of its reads are in progress at once.
+type slowReader struct {
+	mu     sync.Mutex
+	active int
+	max    int
+}
+
+func (r *slowReader) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	r.active++
+	if r.active > r.max {
+		r.max = r.active
+	}
+	r.mu.Unlock()
+
+	time.Sleep(time.Millisecond * 5)
+
+	r.mu.Lock()
+	r.active--
+	r.mu.Unlock()
+	return 0, io.EOF
+}
+
+func TestSchedulerReaders(t *testing.T) {
+	s, err := NewScheduler(SchedulerConfig{Workers: 2, Readers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	slow := &slowReader{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.reader(slow).Read(make([]byte, 1))
+		}()
+	}
+	wg.Wait()
+
+	if slow.max != 2 {
+		t.Fatalf("expected at most 2 reads at once, got %v", slow.max)
+	}
+
+	// Files are still all read with fewer readers than files.
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("file-%v", i))
+		if err := ioutil.WriteFile(path, []byte("line\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewLineReader(Config{
+			Path:      path,
+			Interval:  time.Millisecond * 10,
+			Scheduler: s,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		readLine(t, r, "line")
+	}
+}
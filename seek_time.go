@@ -0,0 +1,115 @@
+package tail
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+// SeekToTime moves the reader within the currently open file so the next
+// call to Next returns the first line with a time at or after t, for files
+// with lines in order of time, like most logs. parse returns the time of a
+// line, without its Delimiter, or false for lines without one, such as the
+// rest of a message that spans lines, which are skipped while searching.
+// The line is found with a binary search, so only a few lines are read
+// even from large files. Otherwise it's like SeekTo, and if every line is
+// before t, the next line returned is the next one written.
+func (l *LineReader) SeekToTime(parse func(line []byte) (time.Time, bool), t time.Time) error {
+	if l.br == nil || l.s.File == nil {
+		return errors.New("no file is open to seek in")
+	}
+
+	stat, err := l.s.File.Stat()
+	if err != nil {
+		return err
+	}
+	size := stat.Size()
+
+	// Find the first offset where the next line with a time isn't
+	// before t, then start from that line, skipping any lines without
+	// a time before it, which belong to an earlier one.
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		lineTime, _, ok, err := l.timeAfter(mid, size, parse)
+		if err != nil {
+			return err
+		}
+
+		if !ok || !lineTime.Before(t) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	_, start, ok, err := l.timeAfter(lo, size, parse)
+	if err != nil {
+		return err
+	} else if !ok {
+		start = size
+	}
+	return l.SeekTo(start)
+}
+
+// timeAfter returns the time and start of the first complete line starting
+// at or after offset that parse finds a time in, or false if there isn't
+// one.
+func (l *LineReader) timeAfter(offset, size int64, parse func([]byte) (time.Time, bool)) (time.Time, int64, bool, error) {
+	// Like SeekTo, start a delimiter early, so offset counts as the
+	// start of a line if those bytes are the delimiter.
+	start := offset - int64(len(l.delim))
+	if start < 0 {
+		start = 0
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(l.s.File, start, size-start))
+	skip := offset > 0
+
+	for pos := start; ; {
+		line, err := readDelimited(br, l.delim)
+		if err == io.EOF {
+			// What's left isn't a complete line.
+			return time.Time{}, 0, false, nil
+		} else if err != nil {
+			return time.Time{}, 0, false, err
+		}
+
+		lineStart := pos
+		pos += int64(len(line))
+		if skip {
+			skip = false
+			continue
+		}
+
+		line = line[:len(line)-len(l.delim)]
+		if len(l.c.Delimiter) == 0 && l.text == nil {
+			line = bytes.TrimSuffix(line, []byte{'\r'})
+		}
+
+		if t, ok := parse(line); ok {
+			return t, lineStart, true, nil
+		}
+	}
+}
+
+// readDelimited reads from br through the next delim.
+func readDelimited(br *bufio.Reader, delim []byte) ([]byte, error) {
+	var line []byte
+	for {
+		b, err := br.ReadSlice(delim[len(delim)-1])
+		line = append(line, b...)
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if err != nil {
+			return line, err
+		}
+
+		if bytes.HasSuffix(line, delim) {
+			return line, nil
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package tail
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLineReaderSeekToTime(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-seek-time-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "1 a\n2 b\ncontinued\n3 c\n5 d\n")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// The time of each line is the seconds it starts with.
+	parse := func(line []byte) (time.Time, bool) {
+		i := bytes.IndexByte(line, ' ')
+		if i < 0 {
+			return time.Time{}, false
+		}
+		sec, err := strconv.Atoi(string(line[:i]))
+		return time.Unix(int64(sec), 0), err == nil
+	}
+
+	if err := r.SeekToTime(parse, time.Unix(1, 0)); err == nil {
+		t.Fatal("expected an error before a file is open")
+	}
+	readLine(t, r, "1 a")
+
+	for _, test := range []struct {
+		sec  int64
+		line string
+	}{
+		{3, "3 c"},
+		{0, "1 a"},
+		{4, "5 d"},
+		{2, "2 b"},
+	} {
+		if err := r.SeekToTime(parse, time.Unix(test.sec, 0)); err != nil {
+			t.Fatal(err)
+		}
+		readLine(t, r, test.line)
+	}
+
+	// Every line is before it, so only new lines are returned.
+	if err := r.SeekToTime(parse, time.Unix(9, 0)); err != nil {
+		t.Fatal(err)
+	}
+	writeString(t, writer, "9 e\n")
+	readLine(t, r, "9 e")
+}
@@ -0,0 +1,499 @@
+package tail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var _ ContextWatcher = (*sharedView)(nil)
+
+// SharedWatcher polls a single path on behalf of any number of Watchers
+// created with NewWatcher. Each file the path refers to over time is only
+// opened once and shared between them, and there is only one poll loop,
+// while each Watcher tracks its own position. This is useful when several
+// independent LineReaders need to consume the same file.
+type SharedWatcher struct {
+	c Config
+
+	// gens holds every file still in use, oldest first. The last one
+	// is the file currently named by the path.
+	gens []*generation
+
+	// changed is closed and replaced whenever gens or their sizes change.
+	changed chan struct{}
+
+	// err is the last error polling, and errSeq is incremented for each
+	// so every view can return it once.
+	err    error
+	errSeq int
+
+	cancel chan struct{}
+	closed bool
+
+	mu sync.Mutex
+}
+
+// generation is one file the path has referred to.
+type generation struct {
+	f     *os.File
+	state FileState
+	// start is where views that were waiting for the first file begin
+	// reading it, which is only non-zero when Whence, Offset, TailLines
+	// or StartState apply. resumed is set if it's from StartState.
+	start   int64
+	resumed bool
+	refs    int
+}
+
+// NewSharedWatcher validates c the same way as NewPollingWatcher, and starts
+// polling the file at Path every Interval until it is closed.
+func NewSharedWatcher(c Config) (*SharedWatcher, error) {
+	if !(c.Whence == io.SeekStart ||
+		c.Whence == io.SeekCurrent ||
+		c.Whence == io.SeekEnd) {
+		return nil, fmt.Errorf("config value for %w: %v", ErrBadWhence, c.Whence)
+	}
+
+	if err := c.checkOffset(); err != nil {
+		return nil, err
+	}
+
+	if c.Interval < 0 {
+		return nil, errors.New("config value for interval cannot be negative")
+	} else if c.Interval == 0 {
+		c.Interval = time.Second
+	}
+
+	if c.TailLines < 0 {
+		return nil, errors.New("config value for tail lines cannot be negative")
+	}
+
+	if c.Path == "" {
+		return nil, errors.New("config value for path cannot be empty")
+	}
+
+	if c.SameFile == nil {
+		c.SameFile = FileState.sameFile
+	}
+
+	w := &SharedWatcher{
+		c:       c,
+		changed: make(chan struct{}),
+		cancel:  make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *SharedWatcher) run() {
+	ticker := w.c.clock().NewTicker(w.c.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll()
+
+		select {
+		case <-w.cancel:
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+func (w *SharedWatcher) poll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	changed := false
+	for _, g := range w.gens {
+		stat, err := g.f.Stat()
+		if err != nil {
+			w.setErr(err)
+			return
+		}
+
+		if stat.Size() != g.state.Size {
+			g.state.Size = stat.Size()
+			changed = true
+		}
+	}
+
+	named, err := NewFileStateFromPath(w.c.Path)
+	if err != nil && !os.IsNotExist(err) {
+		w.setErr(err)
+		return
+	}
+
+	if err == nil && (len(w.gens) == 0 || !w.c.SameFile(w.gens[len(w.gens)-1].state, *named)) {
+		g, err := w.open()
+		if err != nil {
+			w.setErr(err)
+			return
+		}
+		w.gens = append(w.gens, g)
+		w.prune()
+		changed = true
+	}
+
+	if changed {
+		w.broadcast()
+	}
+}
+
+// open opens the file at the path, applying Whence, Offset, TailLines
+// and StartState if it is the first one.
+func (w *SharedWatcher) open() (*generation, error) {
+	f, err := openFile(w.c.Path)
+	if os.IsPermission(err) {
+		return nil, &UnreadableError{Path: w.c.Path, Err: err}
+	} else if err != nil {
+		return nil, err
+	}
+
+	g := &generation{f: f}
+	if g.state, err = NewFileState(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if len(w.gens) == 0 {
+		if w.c.StartState != nil {
+			found, matches, err := w.c.StartState.SeekIfMatches(f)
+			if err == nil && matches {
+				g.start = w.c.StartState.Position
+				g.resumed = true
+			} else if err == nil {
+				g.start, err = w.c.mismatchStart(found)
+				if errors.Is(err, ErrStartMismatch) {
+					// It's only returned once, and the file is then
+					// read from the start.
+					w.c.StartState = nil
+					w.c.TailLines = 0
+					w.c.Whence = io.SeekStart
+					w.c.Offset = 0
+				}
+			}
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+		} else if w.c.TailLines > 0 {
+			g.start, err = lastLinesOffset(f, g.state.Size, w.c.TailLines, w.c.delimiter())
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+		} else {
+			g.start = w.c.startOffset(g.state.Size)
+		}
+	}
+	return g, nil
+}
+
+// viewStart is where a view created while g is the latest file starts
+// reading it. Unless g was resumed from StartState, it's worked out from
+// the size g has now, so a view created later doesn't start where the
+// first ones did.
+func (w *SharedWatcher) viewStart(g *generation) (int64, error) {
+	if g.resumed {
+		return g.start, nil
+	}
+
+	stat, err := g.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if w.c.TailLines > 0 {
+		return lastLinesOffset(g.f, stat.Size(), w.c.TailLines, w.c.delimiter())
+	}
+	return w.c.startOffset(stat.Size()), nil
+}
+
+// prune closes files that are no longer named by the path and that no
+// view is reading from.
+func (w *SharedWatcher) prune() {
+	gens := w.gens[:0]
+	for i, g := range w.gens {
+		if g.refs == 0 && i < len(w.gens)-1 {
+			g.f.Close()
+			continue
+		}
+		gens = append(gens, g)
+	}
+	w.gens = gens
+}
+
+func (w *SharedWatcher) setErr(err error) {
+	w.err = err
+	w.errSeq++
+	w.broadcast()
+}
+
+func (w *SharedWatcher) broadcast() {
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// next returns the generation after g, or nil if g is the latest.
+func (w *SharedWatcher) next(g *generation) *generation {
+	for i, gen := range w.gens {
+		if gen == g && i+1 < len(w.gens) {
+			return w.gens[i+1]
+		}
+	}
+	return nil
+}
+
+// NewWatcher returns a Watcher that reads from the shared files with its
+// own position, starting at the file currently named by the path. Whence,
+// Offset and TailLines apply to that file as of when NewWatcher is called.
+// Closing it doesn't affect the SharedWatcher or other Watchers. The
+// returned Watcher provides WaitStatus.Reader, which must be used instead
+// of File.
+func (w *SharedWatcher) NewWatcher() Watcher {
+	v := &sharedView{
+		w:      w,
+		cancel: make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.gens) > 0 && !w.closed {
+		g := w.gens[len(w.gens)-1]
+		if start, err := w.viewStart(g); err != nil {
+			v.err = err
+		} else {
+			v.attach(g, start)
+		}
+	}
+	return v
+}
+
+// Close stops polling and closes every file. Watchers created from it
+// will return closed from Wait.
+func (w *SharedWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	close(w.cancel)
+
+	var err error
+	for _, g := range w.gens {
+		if e := g.f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	w.gens = nil
+	w.broadcast()
+	return err
+}
+
+// sharedView is a Watcher over a SharedWatcher with its own position.
+type sharedView struct {
+	w *SharedWatcher
+
+	g      *generation
+	pos    int64
+	errSeq int
+
+	// created is set once Created was returned for the first file, and
+	// err is returned once if its start couldn't be found.
+	created bool
+	err     error
+
+	// truncated is set once a TruncatedError has been returned for the
+	// file being read.
+	truncated bool
+
+	cancel chan struct{}
+	closed bool
+}
+
+func (v *sharedView) Wait() (s WaitStatus, closed bool, err error) {
+	return v.WaitContext(context.Background())
+}
+
+// WaitContext is Wait, but returns ctx.Err() if ctx is done before
+// there is more data to read.
+func (v *sharedView) WaitContext(ctx context.Context) (s WaitStatus, closed bool, err error) {
+	w := v.w
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for {
+		if w.closed || v.closed {
+			return s, true, nil
+		}
+
+		if w.errSeq > v.errSeq {
+			v.errSeq = w.errSeq
+			return s, false, w.err
+		}
+
+		if v.err != nil {
+			err, v.err = v.err, nil
+			return s, false, err
+		}
+
+		if v.g == nil && len(w.gens) > 0 {
+			g := w.gens[len(w.gens)-1]
+			v.attach(g, g.start)
+		}
+
+		if v.g != nil && !v.created {
+			v.created = true
+			return v.status(Created), false, nil
+		}
+
+		if v.g != nil {
+			if v.g.state.Size < v.pos {
+				return v.truncate()
+			}
+
+			if v.g.state.Size > v.pos {
+				return v.status(DataAvailable), false, nil
+			}
+
+			if next := w.next(v.g); next != nil {
+				// The old file could have been written to since it was
+				// last polled, so check once more before moving on.
+				if stat, err := v.g.f.Stat(); err != nil {
+					return s, false, err
+				} else if stat.Size() > v.pos {
+					v.g.state.Size = stat.Size()
+					return v.status(DataAvailable), false, nil
+				}
+
+				v.detach()
+				v.attach(next, 0)
+				return v.status(Rotated), false, nil
+			}
+		}
+
+		changed := w.changed
+		w.mu.Unlock()
+		select {
+		case <-changed:
+		case <-v.cancel:
+		case <-ctx.Done():
+		}
+		w.mu.Lock()
+
+		if ctx.Err() != nil && !w.closed && !v.closed {
+			return s, false, ctx.Err()
+		}
+	}
+}
+
+// truncate applies Config.Truncation to the file being read, which is
+// smaller than the view's position.
+func (v *sharedView) truncate() (s WaitStatus, closed bool, err error) {
+	c := v.w.c
+	if c.Truncation == TruncateError && !v.truncated {
+		v.truncated = true
+		return s, false, &TruncatedError{
+			Path:     c.Path,
+			Size:     v.g.state.Size,
+			Position: v.pos,
+		}
+	}
+	v.truncated = false
+
+	v.pos = 0
+	if c.Truncation == TruncateSeekEnd {
+		v.pos = v.g.state.Size
+	}
+
+	s = v.status(Truncated)
+	s.Truncated = true
+	return s, false, nil
+}
+
+func (v *sharedView) attach(g *generation, pos int64) {
+	g.refs++
+	v.g = g
+	v.pos = pos
+}
+
+func (v *sharedView) detach() {
+	v.g.refs--
+	v.g = nil
+	v.truncated = false
+	v.w.prune()
+}
+
+func (v *sharedView) status(event Event) WaitStatus {
+	state := v.g.state
+	state.Position = v.pos
+	return WaitStatus{
+		State:    state,
+		File:     v.g.f,
+		Reader:   &viewReader{v: v, f: v.g.f},
+		Event:    event,
+		ReOpened: event.reopened(),
+	}
+}
+
+func (v *sharedView) Close() error {
+	v.w.mu.Lock()
+	defer v.w.mu.Unlock()
+
+	if v.closed {
+		return nil
+	}
+
+	v.closed = true
+	close(v.cancel)
+	if v.g != nil && !v.w.closed {
+		v.detach()
+	}
+	return nil
+}
+
+// viewReader reads from a shared file at the view's position without
+// changing the offset of the file descriptor.
+type viewReader struct {
+	v *sharedView
+	f *os.File
+}
+
+func (r *viewReader) Read(p []byte) (int, error) {
+	n, err := r.f.ReadAt(p, r.v.pos)
+	r.v.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *viewReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.v.pos
+	default:
+		return 0, fmt.Errorf("%w: %v isn't supported by a SharedWatcher", ErrBadWhence, whence)
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	r.v.pos = offset
+	return offset, nil
+}
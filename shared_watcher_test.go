@@ -0,0 +1,234 @@
+package tail
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSharedWatcher(t *testing.T) {
+
+	h := NewWatcherHarness(t, "shared-watcher-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}
+
+	sw, err := NewSharedWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+
+	onErr := func(e error) error {
+		t.Fatal(e)
+		return e
+	}
+
+	r1 := NewLineReaderFromWatcher(sw.NewWatcher(), c, onErr)
+	r2 := NewLineReaderFromWatcher(sw.NewWatcher(), c, onErr)
+
+	writer := h.Create()
+	writeString(t, writer, "a\nb\n")
+	writer.Close()
+
+	// Each reader keeps its own position in the same file.
+	readLine(t, r1, "a")
+	readLine(t, r1, "b")
+	readLine(t, r2, "a")
+
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "c\n")
+
+	readLine(t, r1, "c")
+	readLine(t, r2, "b")
+	readLine(t, r2, "c")
+
+	if pos := r2.FileState().Position; pos != 2 {
+		t.Fatalf("expected position 2 in the new file, got %v", pos)
+	}
+
+	// Closing one reader shouldn't affect the other.
+	r1.Close()
+
+	writeString(t, writer, "d\n")
+
+	readLine(t, r2, "d")
+	r2.Close()
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if len(sw.gens) != 1 {
+		t.Fatalf("expected only the live file to be open, got %v", len(sw.gens))
+	}
+}
+
+func TestLineReaderNextContext(t *testing.T) {
+
+	h := NewWatcherHarness(t, "next-context-test")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}
+
+	sw, err := NewSharedWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+
+	r := NewLineReaderFromWatcher(sw.NewWatcher(), c, nil)
+	defer r.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb")
+
+	readLine(t, r, "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond * 30)
+		cancel()
+	}()
+
+	if ok, err := r.NextContext(ctx); ok || err != context.Canceled {
+		t.Fatalf("expected cancelled, got %v and %v", ok, err)
+	}
+
+	writeString(t, writer, "c\n")
+
+	ok, err := r.NextContext(context.Background())
+	if !ok || err != nil || string(r.Bytes()) != "bc" {
+		t.Fatalf("expected line 'bc', got %v, %v and '%v'", ok, err, string(r.Bytes()))
+	}
+}
+
+func TestSharedWatcherTruncate(t *testing.T) {
+
+	h := NewWatcherHarness(t, "shared-watcher-truncate")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}
+
+	sw, err := NewSharedWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+
+	r := NewLineReaderFromWatcher(sw.NewWatcher(), c, func(e error) error {
+		t.Error(e)
+		return e
+	})
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "aaaa\n")
+	readLine(t, r, "aaaa")
+
+	if err := writer.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	writeString(t, writer, "b\n")
+
+	// It's read from the start again instead of waiting for the file to
+	// grow past where it was.
+	readLine(t, r, "b")
+}
+
+func TestSharedWatcherLateView(t *testing.T) {
+
+	h := NewWatcherHarness(t, "shared-watcher-late")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		Whence:   io.SeekEnd,
+	}
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\n")
+
+	sw, err := NewSharedWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+
+	onErr := func(e error) error {
+		t.Error(e)
+		return e
+	}
+
+	// The file has to be open before it's written to again for the
+	// first view to start before it.
+	sw.poll()
+
+	r1 := NewLineReaderFromWatcher(sw.NewWatcher(), c, onErr)
+	writeString(t, writer, "b\n")
+	readLine(t, r1, "b")
+
+	// A view created now starts at the end as it is now, not where the
+	// first one started.
+	r2 := NewLineReaderFromWatcher(sw.NewWatcher(), c, onErr)
+	writeString(t, writer, "c\n")
+	readLine(t, r2, "c")
+	readLine(t, r1, "c")
+}
+
+func TestSharedWatcherClock(t *testing.T) {
+
+	h := NewWatcherHarness(t, "shared-watcher-clock")
+	clock := NewManualClock(time.Unix(0, 0))
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Hour,
+		Clock:    clock,
+	}
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\n")
+
+	sw, err := NewSharedWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		clock.BlockUntil(1)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected polling to use the clock")
+	}
+
+	r := NewLineReaderFromWatcher(sw.NewWatcher(), c, func(e error) error {
+		t.Error(e)
+		return e
+	})
+	readLine(t, r, "a")
+
+	// Only advancing the clock polls again.
+	writeString(t, writer, "b\n")
+	clock.Advance(time.Hour)
+	readLine(t, r, "b")
+}
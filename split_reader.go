@@ -0,0 +1,261 @@
+package tail
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+// splitBufferSize is the initial size of a SplitReader's buffer, which
+// grows as needed for larger tokens.
+const splitBufferSize = 4096
+
+// SplitReader reads tokens with a bufio.SplitFunc, like bufio.Scanner, from
+// the files a Watcher opens, so existing split functions like
+// bufio.ScanWords or a protocol's framing can be used while still following
+// rotations. Each file is split on its own, and when it's rotated away from,
+// what's left of it is passed to the SplitFunc with atEOF set, so a final
+// token without a terminator is still returned.
+type SplitReader struct {
+	w     Watcher
+	onErr ErrorHandler
+	split bufio.SplitFunc
+	max   int
+	retry time.Duration
+
+	r io.Reader
+	// buf[start:end] has been read but not split into tokens yet.
+	buf        []byte
+	start, end int
+	// next is the file to read once the open one is finished.
+	next  *WaitStatus
+	token []byte
+
+	// file is the state of the open file as of the end of buf, and
+	// state is as of the end of the last token.
+	file  FileState
+	state FileState
+	err   error
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSplitReader returns a SplitReader using split on the files a polling
+// Watcher created from c opens. If maxSize is greater than 0 and a token
+// grows larger than it, Next returns false and Err returns
+// ErrRecordTooLarge. Errors are handled the same as by NewLineReader.
+func NewSplitReader(c Config, split bufio.SplitFunc, maxSize int, h ErrorHandler) (*SplitReader, error) {
+	w, err := NewPollingWatcher(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSplitReaderFromWatcher(w, c, split, maxSize, h), nil
+}
+
+// NewSplitReaderFromWatcher is like NewSplitReader, but reads from the
+// provided Watcher instead of creating one.
+func NewSplitReaderFromWatcher(w Watcher, c Config, split bufio.SplitFunc, maxSize int, h ErrorHandler) *SplitReader {
+	if h == nil {
+		h = DiscardErrorHandler
+	}
+
+	retry := c.Interval
+	if retry <= 0 {
+		retry = time.Second
+	}
+
+	r := &SplitReader{
+		w:     w,
+		onErr: h,
+		split: split,
+		max:   maxSize,
+		retry: retry,
+		stop:  make(chan struct{}),
+	}
+
+	if c.StartState != nil {
+		r.state = *c.StartState
+	}
+	return r
+}
+
+// Next blocks until a token is available and returns true, or returns
+// false if the SplitReader was closed or stopped with an error.
+func (r *SplitReader) Next() bool {
+	for r.err == nil {
+		atEOF := r.next != nil
+		if r.end > r.start || atEOF {
+			advance, token, err := r.split(r.buf[r.start:r.end], atEOF)
+			if err != nil && err != bufio.ErrFinalToken {
+				r.err = err
+				return false
+			}
+
+			if advance < 0 || advance > r.end-r.start {
+				r.err = bufio.ErrBadReadCount
+				return false
+			}
+
+			r.start += advance
+			r.state = r.file
+			r.state.Position -= int64(r.end - r.start)
+
+			if err == bufio.ErrFinalToken {
+				r.err = err
+				r.token = token
+				return token != nil
+			}
+
+			if token != nil {
+				r.token = token
+				return true
+			}
+
+			if advance > 0 {
+				continue
+			}
+		}
+
+		if atEOF {
+			// The rest of the old file didn't have a token.
+			r.open(*r.next)
+			r.next = nil
+			continue
+		}
+
+		if !r.read() {
+			return false
+		}
+	}
+	return false
+}
+
+// read adds more of the open file to the buffer, waiting on the Watcher
+// if it was read to the end, and returns false if it was closed.
+func (r *SplitReader) read() bool {
+	select {
+	case <-r.stop:
+		return false
+	default:
+	}
+
+	if r.r != nil {
+		if r.start > 0 {
+			r.end = copy(r.buf, r.buf[r.start:r.end])
+			r.start = 0
+		}
+
+		if r.end == len(r.buf) {
+			if r.max > 0 && r.end >= r.max {
+				r.err = ErrRecordTooLarge
+				return false
+			}
+
+			size := len(r.buf) * 2
+			if size == 0 {
+				size = splitBufferSize
+			}
+			if r.max > 0 && size > r.max {
+				size = r.max
+			}
+
+			buf := make([]byte, size)
+			copy(buf, r.buf[:r.end])
+			r.buf = buf
+		}
+
+		n, err := r.r.Read(r.buf[r.end:])
+		r.end += n
+		r.file.Position += int64(n)
+		if n > 0 {
+			return true
+		}
+
+		if err != nil && err != io.EOF {
+			r.handle(err)
+			return true
+		}
+	}
+
+	s, closed, err := r.w.Wait()
+	if closed {
+		return false
+	}
+
+	if err != nil {
+		r.handle(err)
+		return true
+	}
+
+	switch {
+	case s.Truncated:
+		// Part of a token from before it was truncated won't ever be
+		// completed.
+		r.start, r.end = 0, 0
+		r.file = s.State
+	case s.ReOpened && r.r != nil:
+		// Finish splitting the old file first.
+		r.next = &s
+	case s.ReOpened:
+		r.open(s)
+	default:
+		r.file = s.State
+	}
+	return true
+}
+
+// open starts reading from the file in s.
+func (r *SplitReader) open(s WaitStatus) {
+	r.r = s.reader()
+	r.start, r.end = 0, 0
+	r.file = s.State
+	r.state = s.State
+}
+
+// handle passes err to the ErrorHandler, then waits before retrying
+// unless it returned an error.
+func (r *SplitReader) handle(err error) {
+	if r.err = r.onErr(err); r.err != nil {
+		return
+	}
+
+	timer := time.NewTimer(r.retry)
+	defer timer.Stop()
+
+	select {
+	case <-r.stop:
+	case <-timer.C:
+	}
+}
+
+// Bytes returns the last token, which is only valid until the next call
+// to Next.
+func (r *SplitReader) Bytes() []byte {
+	return r.token
+}
+
+// Err returns the error that caused Next to return false, from the
+// ErrorHandler, the SplitFunc or ErrRecordTooLarge. It's nil if the
+// SplitFunc returned bufio.ErrFinalToken.
+func (r *SplitReader) Err() error {
+	if r.err == bufio.ErrFinalToken {
+		return nil
+	}
+	return r.err
+}
+
+// FileState returns the state as of the end of the last token, which
+// Config.StartState can resume from. It isn't safe to call in parallel to
+// Next.
+func (r *SplitReader) FileState() FileState {
+	return r.state
+}
+
+// Close closes the Watcher, which causes Next to return false.
+func (r *SplitReader) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	return r.w.Close()
+}
@@ -0,0 +1,89 @@
+package tail
+
+import (
+	"bufio"
+	"testing"
+	"time"
+)
+
+func TestSplitReader(t *testing.T) {
+
+	h := NewWatcherHarness(t, "split-reader-test")
+
+	r, err := NewSplitReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, bufio.ScanWords, 0, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	next := func(expected string) {
+		t.Helper()
+		if !r.Next() {
+			t.Fatalf("expected a token, got error %v", r.Err())
+		}
+		if string(r.Bytes()) != expected {
+			t.Fatalf("expected %q, got %q", expected, r.Bytes())
+		}
+	}
+
+	writer := h.Create()
+	writeString(t, writer, "one two\nthr")
+
+	next("one")
+	next("two")
+	if pos := r.FileState().Position; pos != 8 {
+		t.Fatalf("expected position 8, got %v", pos)
+	}
+
+	// The word is completed by a later write.
+	writeString(t, writer, "ee ")
+	next("three")
+
+	// The last word of a rotated file doesn't need a terminator.
+	writeString(t, writer, "four")
+	writer.Close()
+	h.Rotate()
+
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "five ")
+
+	next("four")
+	next("five")
+	if pos := r.FileState().Position; pos != 5 {
+		t.Fatalf("expected position 5, got %v", pos)
+	}
+}
+
+func TestSplitReaderTooLarge(t *testing.T) {
+
+	h := NewWatcherHarness(t, "split-reader-too-large-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "short verylongword ")
+
+	r, err := NewSplitReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, bufio.ScanWords, 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if !r.Next() || string(r.Bytes()) != "short" {
+		t.Fatalf("expected 'short', got %q", r.Bytes())
+	}
+	if r.Next() {
+		t.Fatalf("expected no token, got %q", r.Bytes())
+	}
+	if r.Err() != ErrRecordTooLarge {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", r.Err())
+	}
+}
@@ -0,0 +1,211 @@
+package tail
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// LeaseStore holds a lease that only one owner has at a time, so only
+// one of several processes sharing a CheckpointStore reads the files and
+// saves their states. Implementations must be safe to call from multiple
+// goroutines.
+type LeaseStore interface {
+	// Acquire takes the lease for owner until now plus ttl, if it's free,
+	// expired at now, or already held by owner, and reports whether it
+	// did. Acquiring it again before then renews it.
+	Acquire(owner string, now time.Time, ttl time.Duration) (bool, error)
+	// Release gives up the lease if owner holds it.
+	Release(owner string) error
+}
+
+// StandbyConfig configures a Standby.
+type StandbyConfig struct {
+	// Store is the lease shared with the other processes, usually the
+	// same as the CheckpointStore they share, like a FileCheckpointStore.
+	Store LeaseStore
+
+	// Owner names this process, and must be different for each one
+	// sharing Store.
+	Owner string
+
+	// TTL is how long the lease lasts without being renewed, and so how
+	// long the active process can stop before another takes over. It
+	// defaults to 10 seconds.
+	TTL time.Duration
+
+	// Interval is how often the lease is tried for and then renewed. It
+	// defaults to a third of TTL, and must be less than it.
+	Interval time.Duration
+
+	// Clock is optional and replaces the time package, like Config.Clock.
+	Clock Clock
+}
+
+// Standby takes over from another process reading the same files, for an
+// active and standby pair that each use one, where the first to call Wait
+// is active. Wait blocks until the active process stops renewing the
+// lease, within TTL plus Interval, then keeps it renewed, and LineReaders
+// created after it returns resume from the last states the active process
+// saved, with Config.Checkpoint.
+type Standby struct {
+	c     StandbyConfig
+	onErr ErrorHandler
+
+	// lost is closed if the lease couldn't be renewed before it expired.
+	lost   chan struct{}
+	cancel chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	// mu guards held.
+	mu   sync.Mutex
+	held bool
+}
+
+// NewStandby validates c and returns a Standby that doesn't hold the
+// lease yet. Errors from the Store are passed to h, and if it returns an
+// error, Wait returns it or renewing stops.
+func NewStandby(c StandbyConfig, h ErrorHandler) (*Standby, error) {
+	if c.Store == nil {
+		return nil, errors.New("config value for store cannot be nil")
+	}
+
+	if c.Owner == "" {
+		return nil, errors.New("config value for owner cannot be empty")
+	}
+
+	if c.TTL < 0 {
+		return nil, errors.New("config value for ttl cannot be negative")
+	} else if c.TTL == 0 {
+		c.TTL = time.Second * 10
+	}
+
+	if c.Interval < 0 {
+		return nil, errors.New("config value for interval cannot be negative")
+	} else if c.Interval == 0 {
+		c.Interval = c.TTL / 3
+	} else if c.Interval >= c.TTL {
+		return nil, errors.New("config value for interval must be less than ttl")
+	}
+
+	if c.Clock == nil {
+		c.Clock = realClock{}
+	}
+
+	if h == nil {
+		h = DiscardErrorHandler
+	}
+
+	return &Standby{
+		c:      c,
+		onErr:  h,
+		lost:   make(chan struct{}),
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// errStandbyClosed is returned by Wait once the Standby is closed.
+var errStandbyClosed = errors.New("standby is closed")
+
+// Wait blocks until the lease is acquired, trying every Interval, and
+// then renews it every Interval until Close. It returns ctx.Err() if ctx
+// is done first, or the error from the ErrorHandler.
+func (s *Standby) Wait(ctx context.Context) error {
+	ticker := s.c.Clock.NewTicker(s.c.Interval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := s.c.Store.Acquire(s.c.Owner, s.c.Clock.Now(), s.c.TTL)
+		if err != nil {
+			if err = s.onErr(err); err != nil {
+				return err
+			}
+		} else if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.cancel:
+			return errStandbyClosed
+		case <-ticker.C():
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Close was called while acquiring it.
+	select {
+	case <-s.cancel:
+		s.c.Store.Release(s.c.Owner)
+		return errStandbyClosed
+	default:
+	}
+
+	s.held = true
+	go s.renew(s.c.Clock.Now().Add(s.c.TTL))
+	return nil
+}
+
+// renew renews the lease every Interval, closing lost if it expires
+// first or another owner took it.
+func (s *Standby) renew(expires time.Time) {
+	defer close(s.done)
+
+	ticker := s.c.Clock.NewTicker(s.c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.cancel:
+			return
+		case <-ticker.C():
+		}
+
+		now := s.c.Clock.Now()
+		ok, err := s.c.Store.Acquire(s.c.Owner, now, s.c.TTL)
+		switch {
+		case err != nil:
+			if s.onErr(err) == nil && now.Before(expires) {
+				continue
+			}
+		case ok:
+			expires = now.Add(s.c.TTL)
+			continue
+		}
+
+		close(s.lost)
+		return
+	}
+}
+
+// Lost returns a channel that's closed if the lease couldn't be renewed
+// before it expired, or was taken by another owner, so another process
+// may be reading the files and this one should stop.
+func (s *Standby) Lost() <-chan struct{} {
+	return s.lost
+}
+
+// Close stops renewing the lease and releases it, so another process can
+// take over right away. LineReaders using the shared CheckpointStore
+// should be closed first, so their last states are saved.
+func (s *Standby) Close() error {
+	s.mu.Lock()
+	s.once.Do(func() {
+		close(s.cancel)
+	})
+	held := s.held
+	s.held = false
+	s.mu.Unlock()
+
+	if !held {
+		return nil
+	}
+	<-s.done
+	return s.c.Store.Release(s.c.Owner)
+}
@@ -0,0 +1,126 @@
+package tail
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStandby(t *testing.T) {
+
+	h := NewWatcherHarness(t, "standby-test")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "a\nb\n")
+
+	// Each process has its own store for the same file.
+	name := filepath.Join(t.TempDir(), "checkpoints.json")
+	active, err := NewFileCheckpointStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standby, err := NewFileCheckpointStore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The active process takes the lease once and never renews it, as
+	// if it crashed.
+	ttl := time.Millisecond * 200
+	if ok, err := active.Acquire("active", time.Now(), ttl); err != nil || !ok {
+		t.Fatalf("expected the lease to be acquired, got %v, %v", ok, err)
+	}
+
+	c := Config{
+		Path:       h.Path(),
+		Interval:   time.Millisecond * 10,
+		Checkpoint: &CheckpointConfig{Store: active},
+	}
+	r, err := NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readLine(t, r, "a")
+	readLine(t, r, "b")
+	r.Close()
+
+	s, err := NewStandby(StandbyConfig{
+		Store:    standby,
+		Owner:    "standby",
+		TTL:      ttl,
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	start := time.Now()
+	if err := s.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited > ttl+time.Millisecond*100 {
+		t.Fatalf("expected to take over within the ttl, took %v", waited)
+	}
+
+	// The standby resumes from the last state the active one saved, which
+	// can't save over it anymore.
+	writeString(t, writer, "c\n")
+	c.Checkpoint = &CheckpointConfig{Store: standby}
+	r, err = NewLineReader(c, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	readLine(t, r, "c")
+
+	if err := active.Save(h.Path(), FileState{}); err != ErrLeaseLost {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+
+	// It's kept renewed.
+	time.Sleep(ttl * 2)
+	if ok, err := active.Acquire("active", time.Now(), ttl); err != nil || ok {
+		t.Fatalf("expected the lease to still be held, got %v, %v", ok, err)
+	}
+	select {
+	case <-s.Lost():
+		t.Fatal("expected the lease to not be lost")
+	default:
+	}
+
+	// Closing releases it.
+	s.Close()
+	if ok, err := active.Acquire("active", time.Now(), ttl); err != nil || !ok {
+		t.Fatalf("expected the lease to be released, got %v, %v", ok, err)
+	}
+}
+
+func TestStandbyConfig(t *testing.T) {
+
+	store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []StandbyConfig{
+		{Owner: "a"},
+		{Store: store},
+		{Store: store, Owner: "a", TTL: -1},
+		{Store: store, Owner: "a", TTL: time.Second, Interval: time.Second},
+	} {
+		if _, err := NewStandby(c, nil); err == nil {
+			t.Fatalf("expected an error for %+v", c)
+		}
+	}
+}
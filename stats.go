@@ -0,0 +1,159 @@
+package tail
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Stats are counts of what a StatsWatcher has done, for monitoring that
+// it's keeping up with its file.
+type Stats struct {
+	// Polls is how many times the path or open file was checked, by
+	// the Interval or a notification.
+	Polls int64
+	// Lines is how many lines were returned, which is only counted by
+	// LineReader.Stats.
+	Lines int64
+	// BytesRead is how far each file was read past where it was opened,
+	// as of the last poll of it.
+	BytesRead int64
+	// Rotations is how many times a file was finished because a new one
+	// replaced it at the path.
+	Rotations int64
+	// Reopens is how many times a file was tried to be opened at the
+	// path, including each time there wasn't one yet.
+	Reopens int64
+	// Evictions is how many times the open file was closed while idle
+	// for a FileLimit.
+	Evictions int64
+	// Errors is how many errors Wait returned, by category: "not found"
+	// for MustExist, "unreadable", "truncated", or otherwise the failed
+	// operation of an *os.PathError, like ErrorEvent.Op, or "wait".
+	Errors map[string]int64
+	// LastActivity is when a poll last found more data or a new file,
+	// or the zero time if none has.
+	LastActivity time.Time
+	// PollBytes is how many bytes were read from the open file between
+	// each poll and the one before it, for the polls after some were.
+	PollBytes Histogram
+	// Latency is how many seconds after the poll that found more data
+	// each line from it was returned, which is only counted by
+	// LineReader.Stats.
+	Latency Histogram
+}
+
+// Histogram is the distribution of some measurement, counted into
+// buckets.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in
+	// increasing order, and Counts are how many measurements were in
+	// each, with one more for those above the last bound.
+	Bounds []float64
+	Counts []int64
+	// Count and Sum are the number of measurements and their total.
+	Count int64
+	Sum   float64
+}
+
+var (
+	// pollBytesBounds range from a short line to a large burst.
+	pollBytesBounds = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+	// latencyBounds range from a notification to a slow poll.
+	latencyBounds = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+)
+
+func newHistogram(bounds []float64) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]int64, len(bounds)+1)}
+}
+
+// observe counts v into its bucket.
+func (h *Histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+}
+
+// copy returns h with its own Counts.
+func (h Histogram) copy() Histogram {
+	if h.Counts != nil {
+		h.Counts = append([]int64(nil), h.Counts...)
+	}
+	return h
+}
+
+// Stats returns the counts so far.
+func (p *pollWatcher) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.stats
+	s.Errors = make(map[string]int64, len(p.stats.Errors))
+	for category, n := range p.stats.Errors {
+		s.Errors[category] = n
+	}
+	s.PollBytes = p.stats.PollBytes.copy()
+	return s
+}
+
+// count records a poll's result, with mu held.
+func (p *pollWatcher) count(r pollResult, ok bool) {
+	p.stats.Polls++
+
+	switch {
+	case r.err != nil:
+		if p.stats.Errors == nil {
+			p.stats.Errors = make(map[string]int64)
+		}
+		p.stats.Errors[errorCategory(r.err)]++
+	case ok:
+		p.stats.LastActivity = p.c.clock().Now()
+	}
+}
+
+// countRead adds how far the open file was read since the last poll to
+// BytesRead. A position before the last one means it was truncated, and
+// reading starts over from there.
+func (p *pollWatcher) countRead(position int64) {
+	if position > p.readTo {
+		n := position - p.readTo
+		p.stats.BytesRead += n
+
+		if p.stats.PollBytes.Counts == nil {
+			p.stats.PollBytes = newHistogram(pollBytesBounds)
+		}
+		p.stats.PollBytes.observe(float64(n))
+	}
+	p.readTo = position
+}
+
+// errorCategory returns the key in Stats.Errors for err.
+func errorCategory(err error) string {
+	switch {
+	case errors.Is(err, ErrFileNotFound):
+		return "not found"
+	case errors.Is(err, ErrUnreadable):
+		return "unreadable"
+	case errors.Is(err, ErrTruncated):
+		return "truncated"
+	}
+	return errorOp(err, "wait")
+}
+
+// Stats returns the counts of the Watcher, or the zero Stats if it isn't
+// a StatsWatcher, along with how many lines were returned. BytesRead only
+// includes what the LineReader read up to the last time it waited for
+// more.
+func (l *LineReader) Stats() Stats {
+	var s Stats
+	if w, ok := l.r.(StatsWatcher); ok {
+		s = w.Stats()
+	}
+
+	l.mu.Lock()
+	s.Lines = l.returned
+	s.Latency = l.latency.copy()
+	l.mu.Unlock()
+	return s
+}
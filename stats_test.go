@@ -0,0 +1,129 @@
+package tail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLineReaderStats(t *testing.T) {
+
+	h := NewWatcherHarness(t, "line-reader-stats-test")
+	writer := h.Create()
+	writeString(t, writer, "a\nbc\n")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	start := time.Now()
+	readLine(t, r, "a")
+	readLine(t, r, "bc")
+
+	writer.Close()
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "d\n")
+	readLine(t, r, "d")
+
+	// Waiting again polls the position "d" was read up to.
+	if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != ErrTimeout {
+		t.Fatalf("expected a timeout, got %v, %v", ok, err)
+	}
+
+	s := r.Stats()
+	if s.Lines != 3 || s.BytesRead != 7 || s.Rotations != 1 || s.Reopens < 2 || s.Polls < s.Reopens {
+		t.Fatalf("expected 3 lines and 7 bytes read from 2 files, got %+v", s)
+	}
+	if len(s.Errors) != 0 || s.LastActivity.Before(start) {
+		t.Fatalf("expected recent activity without errors, got %+v", s)
+	}
+}
+
+func TestLineReaderStatsLatency(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "latency.log")
+	if err := ioutil.WriteFile(p, []byte("a\nbc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewManualClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	r, err := NewLineReader(Config{
+		Path:               p,
+		Interval:           time.Second,
+		PartialLineTimeout: time.Minute,
+		Clock:              c,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	done := make(chan bool)
+	go func() {
+		done <- r.Next()
+		done <- r.Next()
+	}()
+
+	// The first line is returned as soon as the file is opened, and
+	// the partial line a minute after that.
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	if !<-done {
+		t.Fatal(r.Err())
+	}
+	c.BlockUntil(2)
+	c.Advance(time.Minute)
+	if !<-done {
+		t.Fatal(r.Err())
+	}
+
+	h := r.Stats().Latency
+	if h.Count != 2 || h.Sum != 60 || h.Counts[0] != 1 || h.Counts[len(h.Counts)-1] != 1 {
+		t.Fatalf("expected latencies of 0 and 60 seconds, got %+v", h)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 10})
+	for _, v := range []float64{0, 1, 5, 10, 11} {
+		h.observe(v)
+	}
+
+	if !reflect.DeepEqual(h.Counts, []int64{2, 2, 1}) || h.Count != 5 || h.Sum != 27 {
+		t.Fatalf("expected bounds to be inclusive, got %+v", h)
+	}
+
+	// Copies don't change with the original.
+	c := h.copy()
+	h.observe(0)
+	if c.Counts[0] != 2 {
+		t.Fatalf("expected the copy to be unchanged, got %+v", c)
+	}
+}
+
+func TestErrorCategory(t *testing.T) {
+
+	for err, expect := range map[error]string{
+		&FileNotFoundError{}:                        "not found",
+		&UnreadableError{Err: os.ErrPermission}:     "unreadable",
+		&TruncatedError{}:                           "truncated",
+		&os.PathError{Op: "stat", Err: os.ErrExist}: "stat",
+		os.ErrClosed:                                "wait",
+	} {
+		if category := errorCategory(err); category != expect {
+			t.Errorf("expected %v for %v, got %v", expect, err, category)
+		}
+	}
+}
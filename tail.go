@@ -1,8 +1,15 @@
 package tail
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"time"
+
+	"golang.org/x/text/encoding"
 )
 
 // ErrorHandler allows you to log errors with your logger of choice.
@@ -25,20 +32,499 @@ type Config struct {
 	// also how long to wait before retrying on errors.
 	Interval time.Duration
 
+	// MaxInterval is optional and makes the polling watcher check an idle
+	// file less often, doubling the time between polls each time one
+	// finds nothing new, up to MaxInterval. As soon as a poll finds more
+	// data or a rotation, it goes back to polling every Interval. This
+	// cuts down on stats when following many files that are mostly idle,
+	// at the cost of noticing new data up to MaxInterval late. It's
+	// rounded down to a multiple of Interval, and can't be less than it.
+	MaxInterval time.Duration
+
+	// NotifyDelay is how long a Watcher from NewInotifyWatcher or
+	// NewAutoWatcher waits after a filesystem notification before
+	// polling, so a burst of writes is read after one poll instead of
+	// one per write. Notifications during the wait are coalesced into
+	// it, so it also bounds how often a busy file wakes the reader.
+	// Polls every Interval aren't affected. If 0, it's 10ms.
+	NotifyDelay time.Duration
+
+	// Clock is optional and replaces the time package for the polling
+	// Watcher and LineReader, including their intervals, timeouts and
+	// the times they report, so tests can control it with a
+	// ManualClock. StopAt, NextTimeout and NextBatch are measured on
+	// it as well.
+	Clock Clock
+
+	// Scheduler is optional and polls the file with a Scheduler shared
+	// with other Watchers, rather than a goroutine and ticker of its
+	// own. Clock defaults to the Scheduler's, and must be the same if
+	// it's set. Closing the Watcher doesn't close the Scheduler.
+	Scheduler *Scheduler
+
+	// FileLimit is optional and counts the polling Watcher's open file
+	// against a limit shared with other Watchers, closing it while it's
+	// idle. See FileLimit.
+	FileLimit *FileLimit
+
+	// Backoff is optional and decides how long the LineReader waits before
+	// retrying after an error, instead of a second or UnreadableInterval,
+	// and how long the polling watcher waits between checks for a file
+	// that doesn't exist, instead of Interval. Those checks still happen
+	// on the Interval, so it should be shorter than the waits. See
+	// ConstantBackoff and ExponentialBackoff.
+	Backoff Backoff
+
+	// UnreadableInterval is how long the LineReader waits before retrying
+	// after the file exists but couldn't be opened due to its permissions
+	// (see ErrUnreadable). If 0, it's a second, the same as for other
+	// errors.
+	UnreadableInterval time.Duration
+
 	// Whence can be set to one of the Seek constants from the IO package.
 	// It only applies to the first file opened, as subsequent files will always be
 	// read from the beginning. io.SeekCurrent will behave the same as io.SeekStart.
 	// This will also be ignored if the file doesn't initially exist on disk.
 	Whence int
 
+	// Offset moves where the first file opened starts being read by
+	// this many bytes from Whence, like Seek. A negative Offset with
+	// io.SeekEnd starts that many bytes before the end, like tail -c,
+	// and a positive one with io.SeekStart skips that many bytes, which
+	// with StopAtPosition reads only a range of the file. It's limited
+	// to the size of the file, and like Whence, it's ignored if the file
+	// doesn't initially exist. TailLines and StartState take precedence
+	// over it.
+	Offset int64
+
+	// TailLines starts reading the first file opened at its last
+	// TailLines lines, like tail -n, instead of at Whence. Like Whence,
+	// it's ignored if the file doesn't initially exist, and StartState
+	// takes precedence over it.
+	TailLines int
+
 	// StartState is optional and allows you to resume reading where
 	// you left off. This will only look at the file named in Path
 	// and will not check for older files.
 	StartState *FileState
 
+	// StartMismatch decides where reading starts when StartState doesn't
+	// match the file at Path, such as when it was replaced while nothing
+	// was reading it. By default it's read from the start. LazyBackfill
+	// records the whole file as skipped instead, and ignores it.
+	StartMismatch MismatchPolicy
+
+	// OnStartMismatch is optional and chooses the MismatchPolicy instead
+	// of StartMismatch, given StartState and the state of the file found
+	// at Path.
+	OnStartMismatch func(saved, found FileState) MismatchPolicy
+
+	// Checkpoint is optional and saves the FileState of a LineReader as
+	// it reads, see CheckpointConfig. NewLineReader resumes from the
+	// state saved for Path when StartState isn't set.
+	Checkpoint *CheckpointConfig
+
+	// ManualCommit keeps the LineReader's FileState, including what
+	// CloseAndState returns and the Checkpoint saves, at the last line
+	// committed with LineReader.Commit or CommitLine instead of the last
+	// line returned. Lines that were returned but not committed are then
+	// read again after a restart, for delivering each at least once.
+	ManualCommit bool
+
+	// FingerprintBytes is how many bytes from the start of each file the
+	// polling watcher hashes into the Fingerprint of its FileState, so
+	// StartState isn't resumed in a different file that reused the inode.
+	// Files shorter than that are hashed as far as they go, and it's
+	// extended as they grow. Larger values tell apart files that begin
+	// the same way, like with a common header. 0 disables it.
+	FingerprintBytes int64
+
+	// ChecksumBytes is how many bytes before the Position a LineReader
+	// hashes into the Checksum of the FileState it saves to Checkpoint
+	// and returns from CloseAndState, so it isn't resumed in a file that
+	// was rewritten with the same inode. It's left out if the file was
+	// rotated away from Path by then. 0 disables it.
+	ChecksumBytes int64
+
+	// LazyBackfill starts reading at the end of the first file opened,
+	// regardless of Whence, so only new data is read right away. The data
+	// that was skipped, from StartState or the start of the file, is
+	// recorded so it can be read later. See LineReader.Skipped.
+	LazyBackfill bool
+
+	// Backfill is optional and, when the file StartState was saved for
+	// has since been rotated, has NewLineReader read it from there and
+	// every file rotated after it before the file at Path, which is then
+	// read from the start. Compressed files can only be recognized by
+	// their Fingerprint, so FingerprintBytes must have been set when
+	// StartState was saved for them to be found. It can't be used with
+	// LazyBackfill. With BackfillConfig.All, it can also read all of them
+	// when there's no StartState.
+	Backfill *BackfillConfig
+
+	// Delimiter is what lines end with. By default it's \n, and a \r
+	// before it is also removed from each line. Set it to read records
+	// that end with something else instead, like a NUL byte or \x1e,
+	// which is removed from each one as is.
+	Delimiter []byte
+
+	// Encoding is optional and is the encoding files are written in, such
+	// as UTF-16 or Latin-1 from golang.org/x/text/encoding, which lines
+	// are decoded from to UTF-8. The Delimiter is still given in UTF-8,
+	// and a byte order mark at the start of a file is removed. Positions
+	// and MaxLineBytes are in bytes of the file, before decoding. Set it
+	// to AutoEncoding to detect the encoding of each file instead.
+	Encoding encoding.Encoding
+
+	// MaxLineBytes limits how long a line can be, not counting the
+	// Delimiter, so one that is never completed can't use unbounded
+	// memory. Longer lines are handled according to LongLines. 0 means
+	// there is no limit.
+	MaxLineBytes int
+
+	// LongLines decides what happens to lines over MaxLineBytes.
+	LongLines LongLinePolicy
+
+	// Filter is optional and is called with each line, without its
+	// Delimiter, before it's returned by a LineReader. Lines it returns
+	// false for are dropped, though the FileState still moves past them
+	// so they aren't read again when resuming. See RegexpFilter.
+	Filter func(line []byte) bool
+
+	// MalformedJSON decides what LineReader.NextJSON does with lines that
+	// aren't valid JSON.
+	MalformedJSON MalformedPolicy
+
+	// BufferSize is the size of the buffer a LineReader reads files
+	// with, which is kept across rotations. A larger one means fewer
+	// reads from high-volume files. 0 uses the bufio default of 4096.
+	BufferSize int
+
+	// OnErrorEvent is optional and is called with each error a LineReader
+	// runs into instead of its ErrorHandler, along with what it was doing
+	// and how many times in a row that failed, so it can decide per
+	// operation, like giving up after too many failed opens. Like the
+	// ErrorHandler, returning an error stops the LineReader.
+	OnErrorEvent func(ErrorEvent) error
+
+	// OnOpen, OnRotate, OnTruncate and OnRemove are optional and are
+	// called by the polling Watcher as it follows the file, to log or
+	// react to what happens to it. They're called from the Watcher's
+	// goroutine while it's polling, so they should return quickly and
+	// must not call the Watcher or a LineReader using it.
+	//
+	// OnOpen is called with the name and state of each file opened,
+	// which is what Path resolved to with FollowSymlinks. OnRotate is
+	// called once a file is finished and the one that replaced it is
+	// opened, after OnOpen for the new one. OnTruncate is called with the
+	// state of the open file when it's found to be smaller than the
+	// position read up to. OnRemove is called along with the Removed
+	// event, once the open file is deleted and nothing has replaced it.
+	OnOpen     func(path string, state FileState)
+	OnRotate   func(old, new FileState)
+	OnTruncate func(state FileState)
+	OnRemove   func(state FileState)
+
+	// Logger is optional and is given debug messages about what the
+	// polling Watcher and LineReader decide as they follow the file, such
+	// as opening it, noticing it was rotated, truncated or closed while
+	// idle, and retrying after errors, without having to handle them all
+	// with callbacks or the ErrorHandler. Like the callbacks, it's called
+	// from the Watcher's goroutine while it's polling.
+	Logger Logger
+
+	// TraceSize is how many of its most recent decisions the polling
+	// Watcher keeps, such as what stat found for the open file and the
+	// path, how their identities compared, and attempts to open the file,
+	// for diagnosing why it stopped returning data with DumpTrace. 0
+	// disables it.
+	TraceSize int
+
+	// MustExist makes Wait return a *FileNotFoundError if no file is found
+	// at Path within MustExistTimeout of the polling Watcher being
+	// created, instead of waiting for it to be created. The error is
+	// returned each time until it is, which a LineReader passes to its
+	// ErrorHandler, so returning the error there stops it. After the first
+	// file is opened, a missing path is waited for as usual, since it's
+	// expected during a rotation.
+	MustExist        bool
+	MustExistTimeout time.Duration
+
+	// FollowSymlinks resolves Path each poll when it's a symlink, and
+	// treats it pointing somewhere else as a rotation, finishing the file
+	// it pointed to before opening the new one, even if they're the same
+	// according to SameFile. This is for stable links to changing files,
+	// like the ones Kubernetes keeps in /var/log/containers. RotatedName
+	// is then the name the link pointed to. Only the polling Watchers
+	// use it.
+	FollowSymlinks bool
+
 	// StopAtEOF will cause a tail to exit when it gets the first EOF.
 	// Useful for consumers to build tests.
 	StopAtEOF bool
+
+	// StopAfterLines, StopAtPosition, StopAt and StopWhen stop a
+	// LineReader once they're reached, after which Next returns false and
+	// Err returns ErrStopped. They're useful for replaying part of a file.
+	//
+	// StopAfterLines stops after this many lines are returned, and
+	// StopAtPosition stops after a line that ends at or past this position
+	// in its file. 0 disables them.
+	StopAfterLines int64
+	StopAtPosition int64
+
+	// StopAt stops at this time, even while waiting for more to be
+	// written. The zero time disables it.
+	StopAt time.Time
+
+	// StopWhen is optional and stops after a line it returns true for,
+	// which is still returned.
+	StopWhen func(line []byte) bool
+
+	// PartialLineGrace is how long to keep reading a rotated file that
+	// doesn't end with the Delimiter before moving on to the new file. Writers
+	// that don't write lines atomically can be rotated away from in the
+	// middle of one and finish it shortly after, which would otherwise
+	// be lost. 0 disables waiting.
+	PartialLineGrace time.Duration
+
+	// PartialLineTimeout is how long the LineReader waits for more to be
+	// written to a line that doesn't end with the Delimiter yet, before
+	// returning it from Next anyway with LineReader.Partial set, such as
+	// when the writer crashed in the middle of it. Anything written to
+	// the line after that is returned as a separate line. It only
+	// interrupts a Wait with a ContextWatcher, like the polling watcher.
+	// 0 disables it.
+	PartialLineTimeout time.Duration
+
+	// SameFile is optional and decides if the file currently named by
+	// Path (b) is still the file open for reading (a). The default
+	// compares inodes, which may not be meaningful on some filesystems.
+	// Returning false while a is fully read causes b to be opened.
+	SameFile func(a, b FileState) bool
+
+	// Truncation decides what the polling watcher does when the open file
+	// gets smaller than the position read up to, such as with logrotate's
+	// copytruncate. Truncation is only noticed if the file is still
+	// smaller by the time it's polled, so lines written right after may
+	// be skipped. The default reads the file again from the start.
+	Truncation TruncatePolicy
+
+	// OnViolation is optional and enables checking that the Watcher
+	// behaves as expected while the LineReader reads, such as positions
+	// never going backwards or skipping ahead within a file, and
+	// rotations never going back to a file already finished. It is called
+	// with each problem found, which usually means data was lost or will
+	// be read twice. Useful when testing custom Watchers.
+	OnViolation func(Violation)
+}
+
+// newline is the default Config.Delimiter.
+var newline = []byte{'\n'}
+
+// defaultBufferSize is the default Config.BufferSize, the same as bufio's.
+const defaultBufferSize = 4096
+
+// delimiter returns what lines end with, as it's written in the file.
+func (c Config) delimiter() []byte {
+	if c.Encoding != nil {
+		return newTextEncoding(c.Encoding, c.textDelimiter()).delim
+	}
+	return c.textDelimiter()
+}
+
+// checkOffset validates Offset against Whence.
+func (c Config) checkOffset() error {
+	if c.Whence == io.SeekEnd && c.Offset > 0 {
+		return errors.New("config value for offset cannot be positive from the end")
+	} else if c.Whence != io.SeekEnd && c.Offset < 0 {
+		return errors.New("config value for offset cannot be negative from the start")
+	}
+	return nil
+}
+
+// startOffset returns where to start reading a file of size with Whence
+// and Offset.
+func (c Config) startOffset(size int64) int64 {
+	start := c.Offset
+	if c.Whence == io.SeekEnd {
+		start += size
+	}
+
+	if start < 0 {
+		return 0
+	} else if start > size {
+		return size
+	}
+	return start
+}
+
+// textDelimiter returns what lines end with before Encoding.
+func (c Config) textDelimiter() []byte {
+	if len(c.Delimiter) == 0 {
+		return newline
+	}
+	return c.Delimiter
+}
+
+// RegexpFilter returns a Config.Filter that keeps lines matching include
+// and drops those matching exclude. Either can be nil to not check it.
+func RegexpFilter(include, exclude *regexp.Regexp) func(line []byte) bool {
+	return func(line []byte) bool {
+		if include != nil && !include.Match(line) {
+			return false
+		}
+		return exclude == nil || !exclude.Match(line)
+	}
+}
+
+// MalformedPolicy is what to do with a line LineReader.NextJSON can't
+// decode.
+type MalformedPolicy int
+
+const (
+	// MalformedSkip drops the line.
+	MalformedSkip MalformedPolicy = iota
+
+	// MalformedRaw returns the line from NextJSON without decoding it,
+	// with LineReader.JSONErr set, so it can still be used from Bytes.
+	MalformedRaw
+
+	// MalformedError passes a *MalformedJSONError to the ErrorHandler. If
+	// it's handled, the line is dropped like MalformedSkip.
+	MalformedError
+)
+
+// bufferSize returns the size of the LineReader's read buffer.
+func (c Config) bufferSize() int {
+	if c.BufferSize <= 0 {
+		return defaultBufferSize
+	}
+	return c.BufferSize
+}
+
+// LongLinePolicy is what to do with a line over Config.MaxLineBytes.
+type LongLinePolicy int
+
+const (
+	// LongLineTruncate returns the first MaxLineBytes of the line.
+	LongLineTruncate LongLinePolicy = iota
+
+	// LongLineSkip drops the line.
+	LongLineSkip
+
+	// LongLineError passes a *LineTooLongError to the ErrorHandler as
+	// soon as the line is too long. If it's handled, the line is
+	// dropped like LongLineSkip.
+	LongLineError
+)
+
+// TruncatePolicy is what to do when a file is truncated while it's read.
+type TruncatePolicy int
+
+const (
+	// TruncateReadFromStart reads the truncated file from the start,
+	// which is what was written to it since it was truncated.
+	TruncateReadFromStart TruncatePolicy = iota
+
+	// TruncateSeekEnd skips to the end of the truncated file, only
+	// reading what is written to it after it was noticed.
+	TruncateSeekEnd
+
+	// TruncateError returns a *TruncatedError from Wait. If it's
+	// handled and waited on again, reading continues from the start
+	// like TruncateReadFromStart.
+	TruncateError
+)
+
+// MismatchPolicy is what to do when Config.StartState doesn't match the
+// file at Path.
+type MismatchPolicy int
+
+const (
+	// MismatchReadFromStart reads the file from the start, since none of
+	// it was read before.
+	MismatchReadFromStart MismatchPolicy = iota
+
+	// MismatchSeekEnd skips to the end of the file, only reading what is
+	// written to it later.
+	MismatchSeekEnd
+
+	// MismatchError returns a *StartMismatchError from Wait. If it's
+	// handled and waited on again, the file is read from the start like
+	// MismatchReadFromStart.
+	MismatchError
+)
+
+// mismatchStart returns where to start reading the file at Path when
+// StartState doesn't match it, given its state.
+func (c Config) mismatchStart(found FileState) (int64, error) {
+	policy := c.StartMismatch
+	if c.OnStartMismatch != nil {
+		policy = c.OnStartMismatch(*c.StartState, found)
+	}
+
+	switch policy {
+	case MismatchSeekEnd:
+		return found.Size, nil
+	case MismatchError:
+		return 0, &StartMismatchError{Path: c.Path, Saved: *c.StartState, Found: found}
+	}
+	return 0, nil
+}
+
+// Event is what a Watcher found that made Wait return.
+type Event int
+
+const (
+	// DataAvailable means the open file has more to read.
+	DataAvailable Event = iota
+
+	// Created means the first file was opened.
+	Created
+
+	// Rotated means the open file was read to its end and the file that
+	// replaced it at the path was opened.
+	Rotated
+
+	// Truncated means the open file got smaller than the position it
+	// was read up to, and reading starts over according to
+	// Config.Truncation.
+	Truncated
+
+	// Removed means the open file was deleted and nothing has replaced
+	// it yet. It's still open and there may be more to read from it.
+	// Watchers that can't tell a deleted file from a renamed one never
+	// return it.
+	Removed
+
+	// Resumed means the open file was closed while idle for a
+	// FileLimit, and was opened again where it was read up to because
+	// it has more to read. It's the same file, so only File changes.
+	Resumed
+)
+
+func (e Event) String() string {
+	switch e {
+	case DataAvailable:
+		return "data available"
+	case Created:
+		return "created"
+	case Rotated:
+		return "rotated"
+	case Truncated:
+		return "truncated"
+	case Removed:
+		return "removed"
+	case Resumed:
+		return "resumed"
+	}
+	return fmt.Sprintf("Event(%d)", int(e))
+}
+
+// reopened reports whether ReOpened is set along with e.
+func (e Event) reopened() bool {
+	return e == Created || e == Rotated || e == Truncated || e == Resumed
 }
 
 // WaitStatus is the result of Watcher.Wait and should contain enough
@@ -53,13 +539,42 @@ type WaitStatus struct {
 	// closed by the consumer, as it should always be closed
 	// when a Watcher no longer considers it the latest to read
 	// from or the Watcher is closed.
-	// TODO: provide io.Reader instead?
 	File *os.File
 
+	// Reader is optional, and if set should be read from instead of
+	// File, which is then only valid for stat. This allows a Watcher
+	// to share File with others while tracking its own position.
+	Reader io.Reader
+
+	// Event is what happened to the file, which tells a new file from
+	// a rotation or a truncation.
+	Event Event
+
 	// ReOpened, if true, indicates the file returned has just been
 	// opened. This will also be true for the first file opened, even
-	// though there wasn't one previously.
+	// though there wasn't one previously. It's set along with the
+	// Created, Rotated, Truncated and Resumed events, and is kept for
+	// consumers that don't need to tell them apart.
 	ReOpened bool
+
+	// RotatedName is set along with ReOpened when the previously open
+	// file was renamed rather than removed, to the path it now has. It
+	// is only found if it's in the same directory as the configured
+	// path, and Watchers that can't determine it leave it empty.
+	RotatedName string
+
+	// Truncated is set along with ReOpened when the open file was
+	// truncated, rather than a new one being opened, and reading
+	// starts over according to Config.Truncation.
+	Truncated bool
+}
+
+// reader returns what should be read from for the file.
+func (s WaitStatus) reader() io.Reader {
+	if s.Reader != nil {
+		return s.Reader
+	}
+	return s.File
 }
 
 // Watcher provides a simple interface to handle reading rotated files.
@@ -76,3 +591,23 @@ type Watcher interface {
 	// is open.
 	Close() error
 }
+
+// ContextWatcher is a Watcher that can also stop waiting when a context
+// is done, which LineReader.NextContext uses if it's available.
+type ContextWatcher interface {
+	Watcher
+
+	// WaitContext is the same as Wait, but returns ctx.Err() if ctx
+	// is done before there is more data to read.
+	WaitContext(ctx context.Context) (s WaitStatus, closed bool, err error)
+}
+
+// StatsWatcher is a Watcher that counts what it has done, which
+// LineReader.Stats uses if it's available. The polling Watchers, including
+// the ones using inotify, implement it.
+type StatsWatcher interface {
+	Watcher
+
+	// Stats returns the counts since the Watcher was created.
+	Stats() Stats
+}
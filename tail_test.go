@@ -1,10 +1,14 @@
 package tail
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -18,7 +22,7 @@ func newFileList(name string) fileList {
 	return fileList{name: name}
 }
 
-func (l fileList) push() error {
+func (l *fileList) push() error {
 
 	if len(l.files) == 0 {
 		l.files = []string{l.name}
@@ -37,7 +41,7 @@ func (l fileList) push() error {
 
 func (l *fileList) removeAll() error {
 	for _, name := range l.files {
-		if err := os.Remove(name); err != nil {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
 			return err
 		}
 	}
@@ -179,3 +183,664 @@ func TestReadAfterWatcher(t *testing.T) {
 	reader = h.Wait(r, false, false, nil)
 	expectString(t, reader, "baz")
 }
+
+func TestWatcherSameFile(t *testing.T) {
+
+	h := NewWatcherHarness(t, "same-file")
+
+	var calls int
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 50,
+		SameFile: func(a, b FileState) bool {
+			calls++
+			return a.Inode == b.Inode
+		},
+	}
+
+	r, err := NewPollingWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "foo")
+	writer.Close()
+
+	reader := h.Wait(r, true, false, nil)
+	expectString(t, reader, "foo")
+
+	h.Rotate()
+	writer = h.Create()
+	writeString(t, writer, "bar")
+	writer.Close()
+
+	reader = h.Wait(r, true, false, nil)
+	expectString(t, reader, "bar")
+
+	if calls == 0 {
+		t.Fatal("expected SameFile to be called when checking for rotation")
+	}
+
+	// When it reports the new file is the same one, the rotated file
+	// keeps being read instead.
+	h = NewWatcherHarness(t, "same-file-always")
+	c = Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 50,
+		SameFile: func(a, b FileState) bool {
+			return true
+		},
+	}
+
+	r, err = NewPollingWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "foo")
+
+	reader = h.Wait(r, true, false, nil)
+	expectString(t, reader, "foo")
+
+	h.Rotate()
+	next := h.Create()
+	writeString(t, next, "bar")
+	next.Close()
+	writeString(t, writer, "baz")
+
+	s, closed, err := r.Wait()
+	if err != nil || closed {
+		t.Fatalf("expected more to read, got %v, %v", closed, err)
+	}
+	if s.ReOpened || s.Event == Rotated {
+		t.Fatalf("expected the rotated file to not be reopened, got %v", s.Event)
+	}
+	expectString(t, s.File, "baz")
+}
+
+func TestWatcherUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions aren't enforced for root")
+	}
+
+	h := NewWatcherHarness(t, "unreadable")
+
+	c := Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 50,
+	}
+
+	r, err := NewPollingWatcher(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "foo")
+	writer.Close()
+
+	if err := os.Chmod(h.Path(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = r.Wait()
+	if !errors.Is(err, ErrUnreadable) {
+		t.Fatalf("expected ErrUnreadable, got %v", err)
+	}
+
+	var unreadable *UnreadableError
+	if !errors.As(err, &unreadable) || unreadable.Mode.Perm() != 0 {
+		t.Fatalf("expected UnreadableError with mode 0, got %v", err)
+	}
+
+	if err := os.Chmod(h.Path(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := h.Wait(r, true, false, nil)
+	expectString(t, reader, "foo")
+}
+
+func TestWatcherBecameUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions aren't enforced for root")
+	}
+
+	h := NewWatcherHarness(t, "became-unreadable")
+
+	r, err := NewPollingWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "foo")
+
+	reader := h.Wait(r, true, false, nil)
+	expectString(t, reader, "foo")
+
+	if err := os.Chmod(h.Path(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = r.Wait()
+	var unreadable *UnreadableError
+	if !errors.As(err, &unreadable) || unreadable.Mode.Perm() != 0 {
+		t.Fatalf("expected UnreadableError with mode 0, got %v", err)
+	}
+
+	// The file that's already open is still read.
+	writeString(t, writer, "bar")
+	h.Wait(r, false, false, nil)
+	expectString(t, reader, "bar")
+}
+
+func TestWatcherReplaced(t *testing.T) {
+
+	replacements := map[string]func(t *testing.T, h *WatcherHarness){
+		"rename onto path": func(t *testing.T, h *WatcherHarness) {
+			other := h.Path() + ".tmp"
+			if err := ioutil.WriteFile(other, []byte("bar"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(other, h.Path()); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"recreate after delete": func(t *testing.T, h *WatcherHarness) {
+			if err := os.Remove(h.Path()); err != nil {
+				t.Fatal(err)
+			}
+			writer := h.Create()
+			writeString(t, writer, "bar")
+			writer.Close()
+		},
+	}
+
+	for name, replace := range replacements {
+		t.Run(name, func(t *testing.T) {
+			h := NewWatcherHarness(t, "replaced")
+
+			r, err := NewPollingWatcher(Config{
+				Path:     h.Path(),
+				Interval: time.Millisecond * 10,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			writer := h.Create()
+			writeString(t, writer, "foo")
+			writer.Close()
+
+			reader := h.Wait(r, true, false, nil)
+			expectString(t, reader, "foo")
+
+			replace(t, h)
+
+			reader = h.Wait(r, true, false, nil)
+			expectString(t, reader, "bar")
+		})
+	}
+}
+
+func TestWatcherRotatedName(t *testing.T) {
+
+	h := NewWatcherHarness(t, "rotated-name")
+
+	r, err := NewPollingWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "foo")
+	writer.Close()
+
+	s, _, err := r.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RotatedName != "" {
+		t.Fatalf("expected no rotated name for the first file, got %v", s.RotatedName)
+	}
+	expectString(t, s.File, "foo")
+
+	h.Rotate()
+	writer = h.Create()
+	writeString(t, writer, "bar")
+	writer.Close()
+
+	s, _, err = r.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.ReOpened || s.RotatedName != h.Path()+".1" {
+		t.Fatalf("expected rotated name %v.1, got %v", h.Path(), s.RotatedName)
+	}
+}
+
+func TestWatcherFollowSymlinks(t *testing.T) {
+
+	dir := t.TempDir()
+	link := filepath.Join(dir, "current.log")
+	first := filepath.Join(dir, "0.log")
+	second := filepath.Join(dir, "1.log")
+
+	if err := ioutil.WriteFile(first, []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(first, link); err != nil {
+		t.Skip("symlinks aren't supported: ", err)
+	}
+
+	r, err := NewPollingWatcher(Config{
+		Path:           link,
+		Interval:       time.Millisecond * 10,
+		FollowSymlinks: true,
+		// Only the change of target should count as a rotation.
+		SameFile: func(a, b FileState) bool { return true },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	s, _, err := r.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectString(t, s.File, "foo")
+
+	if err := ioutil.WriteFile(second, []byte("bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(second, link+".tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(link+".tmp", link); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _, err = r.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The temporary directory may be behind a symlink itself.
+	if first, err = filepath.EvalSymlinks(first); err != nil {
+		t.Fatal(err)
+	}
+	if !s.ReOpened || s.RotatedName != first {
+		t.Fatalf("expected a rotation from %v, got %+v", first, s)
+	}
+	expectString(t, s.File, "bar")
+}
+
+func TestWatcherConcurrentWait(t *testing.T) {
+
+	h := NewWatcherHarness(t, "concurrent-wait")
+
+	r, err := NewPollingWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A cancelled wait shouldn't affect the ones after it.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*30)
+	defer cancel()
+	if _, _, err := r.(ContextWatcher).WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	results := make(chan bool)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, closed, _ := r.Wait()
+			results <- closed
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if closed := <-results; !closed {
+			t.Fatal("expected every wait to return closed")
+		}
+	}
+}
+
+func TestWatcherMustExist(t *testing.T) {
+
+	h := NewWatcherHarness(t, "must-exist")
+
+	r, err := NewPollingWatcher(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		MustExist: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	_, _, err = r.Wait()
+	if !errors.Is(err, ErrFileNotFound) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ErrFileNotFound, got %v", err)
+	}
+
+	// It's found if it's created within the timeout.
+	r2, err := NewPollingWatcher(Config{
+		Path:             h.Path(),
+		Interval:         time.Millisecond * 10,
+		MustExist:        true,
+		MustExistTimeout: time.Second * 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+
+	go func() {
+		time.Sleep(time.Millisecond * 30)
+		writer := h.Create()
+		writeString(t, writer, "foo")
+		writer.Close()
+	}()
+
+	reader := h.Wait(r2, true, false, nil)
+	expectString(t, reader, "foo")
+
+	// Once found, the path can be missing during a rotation.
+	h.Rotate()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if s, _, err := r2.(ContextWatcher).WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout, got %+v, %v", s, err)
+	}
+}
+
+func TestWatcherMaxInterval(t *testing.T) {
+
+	h := NewWatcherHarness(t, "max-interval")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "foo\n")
+
+	p, err := newPollWatcher(Config{
+		Path:        h.Path(),
+		Interval:    time.Millisecond * 10,
+		MaxInterval: time.Millisecond * 80,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The ticks are sent by hand rather than by run.
+	close(p.done)
+	defer p.Close()
+
+	tick := make(chan time.Time)
+
+	// serve sends ticks until it has a result, returning how many it took.
+	serve := func(ctx context.Context) (pollResult, int) {
+		result := make(chan pollResult, 1)
+		go func() { result <- p.serve(ctx, tick) }()
+
+		for n := 0; ; {
+			select {
+			case tick <- time.Now():
+				n++
+			case r := <-result:
+				return r, n
+			}
+		}
+	}
+
+	r, _ := serve(context.Background())
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if _, err := ioutil.ReadAll(r.s.File); err != nil {
+		t.Fatal(err)
+	}
+
+	// While idle, polls happen on ticks 1, 2, 4, 8, 16 and 24.
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan pollResult, 1)
+	go func() { result <- p.serve(ctx, tick) }()
+	for i := 0; i < 30; i++ {
+		tick <- time.Now()
+	}
+	cancel()
+	if r := <-result; r.err != context.Canceled {
+		t.Fatalf("expected the idle polls to be canceled, got %+v", r)
+	}
+	if p.idle != 8 || p.skip != 1 {
+		t.Fatalf("expected 8 ticks between polls with 1 left, got %v with %v left", p.idle, p.skip)
+	}
+
+	// New data is found on the next poll, which goes back to every tick.
+	writeString(t, writer, "bar\n")
+	r, n := serve(context.Background())
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if n != 2 {
+		t.Fatalf("expected data to be found on the 2nd tick, got %v", n)
+	}
+	if p.idle != 0 || p.skip != 0 {
+		t.Fatalf("expected polling every tick, got %v ticks with %v left", p.idle, p.skip)
+	}
+
+	if _, err := NewPollingWatcher(Config{
+		Path:        h.Path(),
+		Interval:    time.Second,
+		MaxInterval: time.Millisecond,
+	}); err == nil {
+		t.Fatal("expected a max interval less than interval to fail")
+	}
+}
+
+func TestWatcherNotifyDelay(t *testing.T) {
+
+	h := NewWatcherHarness(t, "notify-delay")
+	writer := h.Create()
+	defer writer.Close()
+	writeString(t, writer, "foo\n")
+
+	clock := NewManualClock(time.Now())
+	p, err := newPollWatcher(Config{
+		Path:        h.Path(),
+		Interval:    time.Hour,
+		NotifyDelay: time.Millisecond * 50,
+		Clock:       clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only wakes are sent, by hand rather than by a backend.
+	close(p.done)
+	defer p.Close()
+	p.wake = make(chan struct{}, 1)
+
+	result := make(chan pollResult, 1)
+	go func() { result <- p.serve(context.Background(), nil) }()
+
+	// A burst of wakes only starts one delay, and nothing is polled
+	// until it's over.
+	for i := 0; i < 3; i++ {
+		p.wake <- struct{}{}
+	}
+	clock.BlockUntil(1)
+
+	select {
+	case r := <-result:
+		t.Fatalf("expected no poll before the notify delay, got %+v", r)
+	default:
+	}
+
+	clock.Advance(time.Millisecond * 50)
+	r := <-result
+	if r.err != nil || r.s.File == nil {
+		t.Fatalf("expected the file to be opened, got %+v", r)
+	}
+
+	if _, err := NewPollingWatcher(Config{
+		Path:        h.Path(),
+		NotifyDelay: -time.Millisecond,
+	}); err == nil {
+		t.Fatal("expected a negative notify delay to fail")
+	}
+}
+
+func TestWatcherLifecycleHooks(t *testing.T) {
+
+	h := NewWatcherHarness(t, "lifecycle-hooks")
+	writer := h.Create()
+	writeString(t, writer, "foo")
+
+	var mu sync.Mutex
+	var events []string
+	event := func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf(format, args...))
+	}
+
+	r, err := NewPollingWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		OnOpen: func(path string, state FileState) {
+			event("open %v %v", filepath.Base(path), state.Size)
+		},
+		OnRotate: func(old, new FileState) {
+			event("rotate %v to %v", old.Size, new.Size)
+		},
+		OnTruncate: func(state FileState) {
+			event("truncate %v", state.Size)
+		},
+		OnRemove: func(state FileState) {
+			event("remove %v", state.Size)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	reader := h.Wait(r, true, false, nil)
+	expectString(t, reader, "foo")
+
+	if err := writer.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	writeString(t, writer, "ab")
+	reader = h.Wait(r, true, false, nil)
+	expectString(t, reader, "ab")
+
+	// Removing the file is only reported once.
+	writer.Close()
+	if err := os.Remove(h.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if s, _, err := r.Wait(); err != nil || s.Event != Removed || s.ReOpened {
+		t.Fatalf("expected a removed event, got %v, %v", s, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if s, _, err := r.(ContextWatcher).WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout, got %+v, %v", s, err)
+	}
+
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "bar!")
+	reader = h.Wait(r, true, false, nil)
+	expectString(t, reader, "bar!")
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		"open lifecycle-hooks 3",
+		"truncate 2",
+		"remove 2",
+		"open lifecycle-hooks 4",
+		"rotate 2 to 4",
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Fatalf("expected events %q, got %q", expected, events)
+	}
+}
+
+func TestWatcherEvents(t *testing.T) {
+
+	h := NewWatcherHarness(t, "events")
+	writer := h.Create()
+	writeString(t, writer, "foo")
+
+	r, err := NewPollingWatcher(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	expectEvent := func(expect Event, read string) {
+		t.Helper()
+		s, _, err := r.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Event != expect || s.ReOpened != (expect != DataAvailable) {
+			t.Fatalf("expected %v, got %v", expect, s)
+		}
+		expectString(t, s.File, read)
+	}
+
+	expectEvent(Created, "foo")
+	writeString(t, writer, "bar")
+	expectEvent(DataAvailable, "bar")
+
+	if err := writer.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.WriteAt([]byte("ab"), 0); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(Truncated, "ab")
+
+	// Renaming the file isn't a removal, so it's a rotation once there's
+	// a new one.
+	writer.Close()
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "baz")
+	expectEvent(Rotated, "baz")
+}
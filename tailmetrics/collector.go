@@ -0,0 +1,152 @@
+// Package tailmetrics exports the Stats and Lag of LineReaders and
+// Watchers as Prometheus metrics, labeled with the path of each file.
+//
+//	c := tailmetrics.NewCollector("")
+//	c.AddReader("/var/log/app.log", r)
+//	prometheus.MustRegister(c)
+package tailmetrics
+
+import (
+	"sync"
+
+	tail "github.com/jacobcase/gotail"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector for the LineReaders and Watchers
+// added to it. Lines read and lag are only exported for LineReaders.
+type Collector struct {
+	lines     *prometheus.Desc
+	bytes     *prometheus.Desc
+	lag       *prometheus.Desc
+	rotations *prometheus.Desc
+	evictions *prometheus.Desc
+	errors    *prometheus.Desc
+	pollBytes *prometheus.Desc
+	latency   *prometheus.Desc
+
+	mu       sync.Mutex
+	readers  map[string]*tail.LineReader
+	watchers map[string]tail.StatsWatcher
+}
+
+// NewCollector returns an empty Collector whose metrics are prefixed with
+// namespace, or gotail if it's empty.
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = "gotail"
+	}
+
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", name),
+			help,
+			append([]string{"path"}, labels...),
+			nil,
+		)
+	}
+
+	return &Collector{
+		lines:     desc("lines_read_total", "Lines returned by the reader."),
+		bytes:     desc("bytes_read_total", "Bytes read from the files, as of the last poll."),
+		lag:       desc("lag_bytes", "Bytes written to the file that haven't been read yet."),
+		rotations: desc("rotations_total", "Times the file was replaced by a new one."),
+		evictions: desc("evictions_total", "Times the file was closed while idle for a file limit."),
+		errors:    desc("errors_total", "Errors waiting for the file, by category.", "category"),
+		pollBytes: desc("poll_bytes", "Bytes read from the file between polls that found more."),
+		latency:   desc("latency_seconds", "Seconds from the poll that found each line to it being returned."),
+		readers:   make(map[string]*tail.LineReader),
+		watchers:  make(map[string]tail.StatsWatcher),
+	}
+}
+
+// AddReader exports the metrics of r labeled with path, replacing
+// anything already added for path.
+func (c *Collector) AddReader(path string, r *tail.LineReader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.watchers, path)
+	c.readers[path] = r
+}
+
+// AddWatcher exports the metrics of w labeled with path, replacing
+// anything already added for path.
+func (c *Collector) AddWatcher(path string, w tail.StatsWatcher) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.readers, path)
+	c.watchers[path] = w
+}
+
+// Remove stops exporting the metrics for path, such as once its reader
+// is closed.
+func (c *Collector) Remove(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.readers, path)
+	delete(c.watchers, path)
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lines
+	ch <- c.bytes
+	ch <- c.lag
+	ch <- c.rotations
+	ch <- c.evictions
+	ch <- c.errors
+	ch <- c.pollBytes
+	ch <- c.latency
+}
+
+// Collect implements prometheus.Collector. A reader whose Lag can't be
+// found, such as after it was closed, is exported without it.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for path, r := range c.readers {
+		s := r.Stats()
+		ch <- prometheus.MustNewConstMetric(c.lines, prometheus.CounterValue, float64(s.Lines), path)
+		if lag, err := r.Lag(); err == nil {
+			ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(lag), path)
+		}
+		collectHistogram(ch, c.latency, path, s.Latency)
+		c.collectStats(ch, path, s)
+	}
+
+	for path, w := range c.watchers {
+		c.collectStats(ch, path, w.Stats())
+	}
+}
+
+// collectStats sends the metrics readers and watchers have in common.
+func (c *Collector) collectStats(ch chan<- prometheus.Metric, path string, s tail.Stats) {
+	ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(s.BytesRead), path)
+	ch <- prometheus.MustNewConstMetric(c.rotations, prometheus.CounterValue, float64(s.Rotations), path)
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(s.Evictions), path)
+	for category, n := range s.Errors {
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(n), path, category)
+	}
+	collectHistogram(ch, c.pollBytes, path, s.PollBytes)
+}
+
+// collectHistogram sends h as a Prometheus histogram, unless it hasn't
+// been started yet.
+func collectHistogram(ch chan<- prometheus.Metric, desc *prometheus.Desc, path string, h tail.Histogram) {
+	if h.Counts == nil {
+		return
+	}
+
+	// Prometheus buckets count everything up to their bound.
+	buckets := make(map[float64]uint64, len(h.Bounds))
+	var n uint64
+	for i, bound := range h.Bounds {
+		n += uint64(h.Counts[i])
+		buckets[bound] = n
+	}
+	ch <- prometheus.MustNewConstHistogram(desc, uint64(h.Count), h.Sum, buckets, path)
+}
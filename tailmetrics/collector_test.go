@@ -0,0 +1,120 @@
+package tailmetrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	if err := ioutil.WriteFile(p, []byte("a\nbc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := tail.NewLineReader(tail.Config{Path: p, Interval: time.Millisecond * 10}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for i := 0; i < 2; i++ {
+		if !r.Next() {
+			t.Fatal(r.Err())
+		}
+	}
+	// Waiting polls the position the lines were read up to.
+	if ok, err := r.NextTimeout(time.Millisecond * 50); ok || err != tail.ErrTimeout {
+		t.Fatalf("expected a timeout, got %v, %v", ok, err)
+	}
+
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("de"); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(dir, "missing.log")
+	w, err := tail.NewPollingWatcher(tail.Config{Path: missing, Interval: time.Millisecond * 10, MustExist: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, _, err := w.Wait(); err == nil {
+		t.Fatal("expected the missing file to fail")
+	}
+
+	c := NewCollector("")
+	c.AddReader(p, r)
+	c.AddWatcher(missing, w.(tail.StatsWatcher))
+
+	expected := strings.NewReplacer("APP", p, "MISSING", missing).Replace(`
+# HELP gotail_bytes_read_total Bytes read from the files, as of the last poll.
+# TYPE gotail_bytes_read_total counter
+gotail_bytes_read_total{path="APP"} 5
+gotail_bytes_read_total{path="MISSING"} 0
+# HELP gotail_errors_total Errors waiting for the file, by category.
+# TYPE gotail_errors_total counter
+gotail_errors_total{category="not found",path="MISSING"} 1
+# HELP gotail_evictions_total Times the file was closed while idle for a file limit.
+# TYPE gotail_evictions_total counter
+gotail_evictions_total{path="APP"} 0
+gotail_evictions_total{path="MISSING"} 0
+# HELP gotail_lag_bytes Bytes written to the file that haven't been read yet.
+# TYPE gotail_lag_bytes gauge
+gotail_lag_bytes{path="APP"} 2
+# HELP gotail_lines_read_total Lines returned by the reader.
+# TYPE gotail_lines_read_total counter
+gotail_lines_read_total{path="APP"} 2
+# HELP gotail_poll_bytes Bytes read from the file between polls that found more.
+# TYPE gotail_poll_bytes histogram
+gotail_poll_bytes_bucket{path="APP",le="64"} 1
+gotail_poll_bytes_bucket{path="APP",le="256"} 1
+gotail_poll_bytes_bucket{path="APP",le="1024"} 1
+gotail_poll_bytes_bucket{path="APP",le="4096"} 1
+gotail_poll_bytes_bucket{path="APP",le="16384"} 1
+gotail_poll_bytes_bucket{path="APP",le="65536"} 1
+gotail_poll_bytes_bucket{path="APP",le="262144"} 1
+gotail_poll_bytes_bucket{path="APP",le="1.048576e+06"} 1
+gotail_poll_bytes_bucket{path="APP",le="4.194304e+06"} 1
+gotail_poll_bytes_bucket{path="APP",le="+Inf"} 1
+gotail_poll_bytes_sum{path="APP"} 5
+gotail_poll_bytes_count{path="APP"} 1
+# HELP gotail_rotations_total Times the file was replaced by a new one.
+# TYPE gotail_rotations_total counter
+gotail_rotations_total{path="APP"} 0
+gotail_rotations_total{path="MISSING"} 0
+`)
+	// Latency depends on how long the lines took to read, so only its
+	// count is checked.
+	names := []string{
+		"gotail_bytes_read_total",
+		"gotail_errors_total",
+		"gotail_evictions_total",
+		"gotail_lag_bytes",
+		"gotail_lines_read_total",
+		"gotail_poll_bytes",
+		"gotail_rotations_total",
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(c, "gotail_latency_seconds"); n != 1 {
+		t.Fatalf("expected latency for 1 path, got %v", n)
+	}
+
+	c.Remove(missing)
+	if n := testutil.CollectAndCount(c, "gotail_bytes_read_total"); n != 1 {
+		t.Fatalf("expected 1 path after removing one, got %v", n)
+	}
+}
@@ -0,0 +1,174 @@
+// Package tailtest helps test code that follows files with the tail
+// package, by simulating what log rotation does to a file and faking a
+// Watcher.
+package tailtest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+// Harness manages a file in a temporary directory that's removed when
+// the test ends, and rotates it the ways logging tools do. Every method
+// fails the test on an error.
+type Harness struct {
+	t    testing.TB
+	path string
+	// rotated is the names the file was rotated to, newest first.
+	rotated []string
+}
+
+// NewHarness returns a Harness for a file called name in a temporary
+// directory, which isn't created until Create or Append is called.
+func NewHarness(t testing.TB, name string) *Harness {
+	return &Harness{
+		t:    t,
+		path: filepath.Join(t.TempDir(), name),
+	}
+}
+
+// Path returns the path of the file, to use as Config.Path.
+func (h *Harness) Path() string {
+	return h.path
+}
+
+// Rotated returns the names the file was rotated to by Rotate and
+// CopyTruncate, newest first, which are Path with .1, .2 and so on added.
+func (h *Harness) Rotated() []string {
+	return append([]string(nil), h.rotated...)
+}
+
+// Create creates the file, which must not exist yet, and returns it open
+// for writing. It's closed when the test ends if it isn't already.
+func (h *Harness) Create() *os.File {
+	h.t.Helper()
+
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// Append writes s to the end of the file, creating it if it doesn't
+// exist.
+func (h *Harness) Append(s string) {
+	h.t.Helper()
+
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer f.Close()
+	WriteString(h.t, f, s)
+}
+
+// Rotate renames the file to Path.1, after renaming the files rotated
+// before it to the next number up, like logrotate does by default. A new
+// file isn't created.
+func (h *Harness) Rotate() {
+	h.t.Helper()
+
+	if err := os.Rename(h.path, h.shift()); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// CopyTruncate copies the file to Path.1 like Rotate, then truncates it
+// in place, like logrotate's copytruncate option, so it's still the same
+// file.
+func (h *Harness) CopyTruncate() {
+	h.t.Helper()
+
+	b, err := ioutil.ReadFile(h.path)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(h.shift(), b, 0644); err != nil {
+		h.t.Fatal(err)
+	}
+	h.Truncate(0)
+}
+
+// shift renames the rotated files to the next number up, and returns
+// Path.1 for the file being rotated.
+func (h *Harness) shift() string {
+	h.t.Helper()
+
+	h.rotated = append(h.rotated, fmt.Sprintf("%s.%v", h.path, len(h.rotated)+1))
+	for i := len(h.rotated) - 1; i > 0; i-- {
+		if err := os.Rename(h.rotated[i-1], h.rotated[i]); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+	return h.rotated[0]
+}
+
+// Truncate truncates the file to size.
+func (h *Harness) Truncate(size int64) {
+	h.t.Helper()
+
+	if err := os.Truncate(h.path, size); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// Remove deletes the file.
+func (h *Harness) Remove() {
+	h.t.Helper()
+
+	if err := os.Remove(h.path); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// Wait calls w.Wait and fails the test if it returns an error, is
+// closed, or the event isn't expect. It returns what to read from for
+// the file.
+func (h *Harness) Wait(w tail.Watcher, expect tail.Event) io.Reader {
+	h.t.Helper()
+
+	s, closed, err := w.Wait()
+	if err != nil {
+		h.t.Fatalf("watcher returned %v", err)
+	} else if closed {
+		h.t.Fatal("watcher was closed")
+	} else if s.Event != expect {
+		h.t.Fatalf("watcher returned %v, expected %v", s.Event, expect)
+	}
+
+	if s.Reader != nil {
+		return s.Reader
+	}
+	return s.File
+}
+
+// WriteString writes s to w, failing the test on an error.
+func WriteString(t testing.TB, w io.Writer, s string) {
+	t.Helper()
+
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ExpectString reads len(expect) bytes from r and fails the test if they
+// aren't expect.
+func ExpectString(t testing.TB, r io.Reader, expect string) {
+	t.Helper()
+
+	b := make([]byte, len(expect))
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatalf("reading %q: %v", expect, err)
+	}
+	if string(b) != expect {
+		t.Fatalf("read %q, expected %q", b, expect)
+	}
+}
@@ -0,0 +1,51 @@
+package tailtest
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+func TestHarness(t *testing.T) {
+	h := NewHarness(t, "app.log")
+	h.Append("foo\n")
+
+	w, err := tail.NewPollingWatcher(tail.Config{Path: h.Path(), Interval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ExpectString(t, h.Wait(w, tail.Created), "foo\n")
+
+	h.Rotate()
+	h.Append("bar\n")
+	ExpectString(t, h.Wait(w, tail.Rotated), "bar\n")
+
+	h.Append("baz\n")
+	ExpectString(t, h.Wait(w, tail.DataAvailable), "baz\n")
+
+	h.CopyTruncate()
+	h.Append("qux\n")
+	ExpectString(t, h.Wait(w, tail.Truncated), "qux\n")
+
+	h.Remove()
+	h.Wait(w, tail.Removed)
+
+	expected := []string{h.Path() + ".1", h.Path() + ".2"}
+	if rotated := h.Rotated(); !reflect.DeepEqual(rotated, expected) {
+		t.Fatalf("expected rotated files %v, got %v", expected, rotated)
+	}
+
+	for name, content := range map[string]string{
+		expected[0]: "bar\nbaz\n",
+		expected[1]: "foo\n",
+	} {
+		if b, err := ioutil.ReadFile(name); err != nil || string(b) != content {
+			t.Errorf("expected %v to have %q, got %q, %v", name, content, b, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package tailtest
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+// Step is one result of Wait for a ScriptedWatcher.
+type Step struct {
+	// Event is what Wait returns. Created and Rotated start a new file
+	// containing Data, Truncated replaces the content of the current
+	// one with Data, and any other event appends Data to it.
+	Event tail.Event
+	Data  string
+	// Err is returned from Wait instead, without changing the file.
+	Err error
+}
+
+// ScriptedWatcher is a tail.Watcher that returns each of its Steps in
+// order from Wait, reading from files in memory, so consumers of a
+// LineReader can be tested without a filesystem. Use it with
+// tail.NewLineReaderFromWatcher. Once every step is returned, Wait
+// returns closed, which stops a LineReader after it reads the rest.
+type ScriptedWatcher struct {
+	mu     sync.Mutex
+	steps  []Step
+	file   *memFile
+	files  int
+	closed bool
+}
+
+// NewScriptedWatcher returns a ScriptedWatcher that returns steps.
+func NewScriptedWatcher(steps ...Step) *ScriptedWatcher {
+	return &ScriptedWatcher{steps: steps}
+}
+
+func (w *ScriptedWatcher) Wait() (tail.WaitStatus, bool, error) {
+	return w.WaitContext(context.Background())
+}
+
+func (w *ScriptedWatcher) WaitContext(ctx context.Context) (tail.WaitStatus, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return tail.WaitStatus{}, false, err
+	}
+	if w.closed || len(w.steps) == 0 {
+		return tail.WaitStatus{}, true, nil
+	}
+
+	step := w.steps[0]
+	w.steps = w.steps[1:]
+	if step.Err != nil {
+		return tail.WaitStatus{}, false, step.Err
+	}
+
+	switch {
+	case step.Event == tail.Created || step.Event == tail.Rotated || w.file == nil:
+		w.files++
+		w.file = &memFile{inode: uint64(w.files)}
+		w.file.write(step.Data)
+	case step.Event == tail.Truncated:
+		w.file.truncate(step.Data)
+	default:
+		w.file.write(step.Data)
+	}
+
+	reOpened := step.Event == tail.Created || step.Event == tail.Rotated || step.Event == tail.Truncated
+	return tail.WaitStatus{
+		State:     w.file.state(),
+		Reader:    w.file,
+		Event:     step.Event,
+		ReOpened:  reOpened,
+		Truncated: step.Event == tail.Truncated,
+	}, false, nil
+}
+
+// Remaining returns how many steps haven't been returned yet.
+func (w *ScriptedWatcher) Remaining() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.steps)
+}
+
+// Close makes Wait return closed.
+func (w *ScriptedWatcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+// memFile is a file in memory, read from pos.
+type memFile struct {
+	mu    sync.Mutex
+	data  []byte
+	pos   int
+	inode uint64
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.pos >= len(f.data) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[f.pos:])
+	f.pos += n
+	return n, nil
+}
+
+func (f *memFile) write(s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data = append(f.data, s...)
+}
+
+func (f *memFile) truncate(s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data = append(f.data[:0], s...)
+	f.pos = 0
+}
+
+// state returns a FileState for the file, which has a different inode
+// than every other file from the same ScriptedWatcher.
+func (f *memFile) state() tail.FileState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return tail.FileState{
+		Inode:    f.inode,
+		Size:     int64(len(f.data)),
+		Position: int64(f.pos),
+	}
+}
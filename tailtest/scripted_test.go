@@ -0,0 +1,52 @@
+package tailtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+func TestScriptedWatcher(t *testing.T) {
+	oops := errors.New("oops")
+	w := NewScriptedWatcher(
+		Step{Event: tail.Created, Data: "a\nb"},
+		Step{Event: tail.DataAvailable, Data: "c\n"},
+		Step{Err: oops},
+		Step{Event: tail.Rotated, Data: "d\n"},
+		Step{Event: tail.Truncated, Data: "ef\n"},
+	)
+
+	var errs []error
+	r := tail.NewLineReaderFromWatcher(w, tail.Config{
+		Path:    "scripted",
+		Backoff: tail.ConstantBackoff(time.Millisecond),
+	}, func(err error) error {
+		errs = append(errs, err)
+		return nil
+	})
+	defer r.Close()
+
+	var lines []string
+	var inodes []uint64
+	for r.Next() {
+		lines = append(lines, string(r.Bytes()))
+		inodes = append(inodes, r.FileState().Inode)
+	}
+
+	if strings.Join(lines, ",") != "a,bc,d,ef" {
+		t.Fatalf("expected lines a,bc,d,ef, got %q", lines)
+	}
+	// Truncating keeps the same file.
+	if inodes[1] != 1 || inodes[2] != 2 || inodes[3] != 2 {
+		t.Fatalf("expected the files to be 1, 2 and 2, got %v", inodes)
+	}
+	if len(errs) != 1 || errs[0] != oops {
+		t.Fatalf("expected the error to be handled, got %v", errs)
+	}
+	if r.FileState().Position != 3 || w.Remaining() != 0 {
+		t.Fatalf("expected to stop at 3 after every step, got %v with %v left", r.FileState(), w.Remaining())
+	}
+}
@@ -0,0 +1,63 @@
+package tailtest
+
+import (
+	"context"
+	"sync"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+// Watcher is a fake tail.Watcher whose Wait returns what's sent to it,
+// so tests decide exactly when a LineReader sees more to read, a new
+// file or an error. Use it with tail.NewLineReaderFromWatcher.
+type Watcher struct {
+	results chan result
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+type result struct {
+	s   tail.WaitStatus
+	err error
+}
+
+// NewWatcher returns a Watcher with nothing sent to it yet.
+func NewWatcher() *Watcher {
+	return &Watcher{
+		results: make(chan result),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Send blocks until a call to Wait returns s and err, and returns true,
+// or returns false if the Watcher is closed first.
+func (w *Watcher) Send(s tail.WaitStatus, err error) bool {
+	select {
+	case w.results <- result{s, err}:
+		return true
+	case <-w.closed:
+		return false
+	}
+}
+
+func (w *Watcher) Wait() (tail.WaitStatus, bool, error) {
+	return w.WaitContext(context.Background())
+}
+
+func (w *Watcher) WaitContext(ctx context.Context) (tail.WaitStatus, bool, error) {
+	select {
+	case r := <-w.results:
+		return r.s, false, r.err
+	case <-w.closed:
+		return tail.WaitStatus{}, true, nil
+	case <-ctx.Done():
+		return tail.WaitStatus{}, false, ctx.Err()
+	}
+}
+
+// Close makes Wait return closed, and Send return false.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() { close(w.closed) })
+	return nil
+}
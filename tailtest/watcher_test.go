@@ -0,0 +1,53 @@
+package tailtest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+func TestWatcher(t *testing.T) {
+	w := NewWatcher()
+
+	var errs []error
+	r := tail.NewLineReaderFromWatcher(w, tail.Config{
+		Path:    "fake",
+		Backoff: tail.ConstantBackoff(time.Millisecond),
+	}, func(err error) error {
+		errs = append(errs, err)
+		return nil
+	})
+
+	go func() {
+		w.Send(tail.WaitStatus{
+			Reader:   strings.NewReader("a\nb\n"),
+			Event:    tail.Created,
+			ReOpened: true,
+		}, nil)
+		w.Send(tail.WaitStatus{}, errors.New("oops"))
+		w.Send(tail.WaitStatus{
+			Reader:   strings.NewReader("c\n"),
+			Event:    tail.Rotated,
+			ReOpened: true,
+		}, nil)
+		w.Close()
+	}()
+
+	var lines []string
+	for r.Next() {
+		lines = append(lines, string(r.Bytes()))
+	}
+
+	if strings.Join(lines, ",") != "a,b,c" {
+		t.Fatalf("expected lines a,b,c, got %q", lines)
+	}
+	if len(errs) != 1 || errs[0].Error() != "oops" {
+		t.Fatalf("expected the error to be handled, got %v", errs)
+	}
+	if w.Send(tail.WaitStatus{}, nil) {
+		t.Fatal("expected Send to fail after Close")
+	}
+}
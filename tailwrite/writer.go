@@ -0,0 +1,198 @@
+// Package tailwrite provides a rotating file writer using the same
+// numbered naming scheme the tail package expects, where the live file
+// is Path and older files are renamed to Path.1, Path.2, and so on.
+package tailwrite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Config configures when and how a Writer rotates.
+type Config struct {
+	// Path is the name of the live file being written to.
+	Path string
+
+	// MaxSize is the size in bytes a file can grow to before it is
+	// rotated. A single write is never split between files, so a file
+	// can exceed MaxSize if one write is larger than it. 0 disables
+	// rotating by size.
+	MaxSize int64
+
+	// MaxAge is how long a file is written to before it is rotated,
+	// measured from when the Writer opened it. 0 disables rotating by age.
+	MaxAge time.Duration
+
+	// MaxBackups is how many rotated files to keep. Older files are
+	// removed on rotation. 0 keeps all of them.
+	MaxBackups int
+
+	// Perm is the permission used when creating files. Defaults to 0644.
+	Perm os.FileMode
+}
+
+// Writer is an io.WriteCloser that rotates the file at Path by size and
+// age. It is safe to use from multiple goroutines.
+type Writer struct {
+	c Config
+
+	f      *os.File
+	size   int64
+	opened time.Time
+
+	mu sync.Mutex
+}
+
+// NewWriter validates c and opens or creates the file at Path,
+// appending to it if it exists.
+func NewWriter(c Config) (*Writer, error) {
+	if c.Path == "" {
+		return nil, errors.New("config value for path cannot be empty")
+	}
+
+	if c.MaxSize < 0 || c.MaxAge < 0 || c.MaxBackups < 0 {
+		return nil, errors.New("config values for max size, age, and backups cannot be negative")
+	}
+
+	if c.Perm == 0 {
+		c.Perm = 0644
+	}
+
+	w := &Writer{c: c}
+	return w, w.open()
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.c.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, w.c.Perm)
+	if err != nil {
+		return err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.f = f
+	w.size = stat.Size()
+	w.opened = time.Now()
+	return nil
+}
+
+// Write writes p to the live file, rotating first if writing p would
+// exceed MaxSize or the file is older than MaxAge.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *Writer) shouldRotate(n int64) bool {
+	// Never rotate an empty file, there is nothing to gain.
+	if w.size == 0 {
+		return false
+	}
+
+	if w.c.MaxSize > 0 && w.size+n > w.c.MaxSize {
+		return true
+	}
+
+	return w.c.MaxAge > 0 && time.Since(w.opened) >= w.c.MaxAge
+}
+
+// Rotate forces the live file to be rotated, regardless of its size or age.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+// rotate renames the files and opens a new live file. If that fails, the
+// live file is opened again wherever renaming stopped, so writes can go
+// on and the error is only returned once.
+func (w *Writer) rotate() error {
+	err := w.f.Close()
+	w.f = nil
+	if err == nil {
+		err = w.shift()
+	}
+
+	if err != nil {
+		if openErr := w.open(); openErr != nil {
+			return fmt.Errorf("%w, and reopening failed: %v", err, openErr)
+		}
+		return err
+	}
+	return w.open()
+}
+
+// shift renames every file to the next number, removing the ones past
+// MaxBackups, with the live file closed.
+func (w *Writer) shift() error {
+	// Find the oldest existing file so everything can be shifted up by one.
+	last := 0
+	for {
+		if _, err := os.Lstat(w.name(last + 1)); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return err
+		}
+		last++
+	}
+
+	for i := last; i >= 0; i-- {
+		if w.c.MaxBackups > 0 && i >= w.c.MaxBackups {
+			if err := os.Remove(w.name(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.Rename(w.name(i), w.name(i+1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) name(i int) string {
+	if i == 0 {
+		return w.c.Path
+	}
+	return fmt.Sprintf("%s.%v", w.c.Path, i)
+}
+
+// Close closes the live file. Writes after Close return os.ErrClosed.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
@@ -0,0 +1,139 @@
+package tailwrite
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tail "github.com/jacobcase/gotail"
+)
+
+func TestWriterRotateSize(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "rotate-size")
+
+	w, err := NewWriter(Config{
+		Path:       p,
+		MaxSize:    8,
+		MaxBackups: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := map[string]string{
+		p:        "dddd\n",
+		p + ".1": "cccc\n",
+		p + ".2": "bbbb\n",
+	}
+	for name, content := range expected {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Fatalf("expected %s to contain %q, got %q", name, content, string(b))
+		}
+	}
+
+	if _, err := os.Stat(p + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected %s.3 to be removed, got %v", p, err)
+	}
+}
+
+func TestWriterRotateError(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "rotate-error")
+
+	w, err := NewWriter(Config{
+		Path:       p,
+		MaxBackups: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The oldest backup can't be removed, since it's a directory with
+	// something in it.
+	if err := os.MkdirAll(filepath.Join(p+".1", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err == nil {
+		t.Fatal("expected rotating to fail")
+	}
+
+	// The live file is still written to.
+	if _, err := w.Write([]byte("b\n")); err != nil {
+		t.Fatalf("expected writing after a failed rotation to work, got %v", err)
+	}
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "a\nb\n" {
+		t.Fatalf("expected %q, got %q", "a\nb\n", b)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "round-trip")
+
+	w, err := NewWriter(Config{
+		Path:    p,
+		MaxSize: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	r, err := tail.NewLineReader(tail.Config{
+		Path:     p,
+		Interval: time.Millisecond * 5,
+	}, func(e error) error {
+		t.Fatal(e)
+		return e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	const count = 50
+
+	// Rotated files are only followed once the reader has the live file
+	// open, so make sure that happens before rotating.
+	fmt.Fprintf(w, "line 0\n")
+	if !r.Next() || string(r.Bytes()) != "line 0" {
+		t.Fatalf("failed to read first line: %v", r.Err())
+	}
+
+	go func() {
+		for i := 1; i < count; i++ {
+			fmt.Fprintf(w, "line %v\n", i)
+			time.Sleep(time.Millisecond * 5)
+		}
+	}()
+
+	for i := 1; i < count; i++ {
+		if !r.Next() {
+			t.Fatalf("Next() returned false after %v lines: %v", i, r.Err())
+		}
+		if expect := fmt.Sprintf("line %v", i); string(r.Bytes()) != expect {
+			t.Fatalf("expected line %q, got %q", expect, string(r.Bytes()))
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package tail
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TraceWatcher is a Watcher that keeps a trace of its most recent
+// decisions, with Config.TraceSize.
+type TraceWatcher interface {
+	Watcher
+
+	// DumpTrace writes the trace to w, oldest first, with the time and
+	// description of one decision per line.
+	DumpTrace(w io.Writer) error
+}
+
+// trace records a decision in the trace if TraceSize is set, with mu held.
+// Args are alternating keys and values like with the Logger.
+func (p *pollWatcher) trace(msg string, args ...interface{}) {
+	if p.c.TraceSize <= 0 {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	p.traced.add(p.c.clock().Now(), b.String())
+}
+
+// debug records a decision in the trace and logs it to the Logger, with
+// mu held.
+func (p *pollWatcher) debug(msg string, args ...interface{}) {
+	p.trace(msg, args...)
+	p.c.debug(msg, args...)
+}
+
+// DumpTrace writes the trace of the polling Watcher's decisions, such as
+// what stat found for the open file and the path, how their identities
+// compared, and attempts to open the file. It's empty unless TraceSize
+// is set.
+func (p *pollWatcher) DumpTrace(w io.Writer) error {
+	p.mu.Lock()
+	entries := p.traced.list()
+	p.mu.Unlock()
+
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%v\n", e)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DumpTrace writes the trace of the Watcher's decisions like
+// TraceWatcher, for diagnosing why lines stopped being returned. Nothing
+// is written if the Watcher doesn't keep one. It is safe to call in
+// parallel to Next.
+func (l *LineReader) DumpTrace(w io.Writer) error {
+	if t, ok := l.r.(TraceWatcher); ok {
+		return t.DumpTrace(w)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineReaderDumpTrace(t *testing.T) {
+
+	h := NewWatcherHarness(t, "dump-trace")
+
+	r, err := NewLineReader(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		TraceSize: 100,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "one\n")
+	writer.Close()
+	readLine(t, r, "one")
+
+	h.Rotate()
+	writer = h.Create()
+	defer writer.Close()
+	writeString(t, writer, "two\n")
+	readLine(t, r, "two")
+
+	var b strings.Builder
+	if err := r.DumpTrace(&b); err != nil {
+		t.Fatal(err)
+	}
+	trace := b.String()
+
+	for _, expect := range []string{
+		"opened file name=" + h.Path(),
+		"stat open file size=4 position=4\n",
+		"same=false\n",
+		"rotation detected",
+	} {
+		if !strings.Contains(trace, expect) {
+			t.Fatalf("expected trace to contain %q, got:\n%v", expect, trace)
+		}
+	}
+}
+
+func TestDumpTraceDisabled(t *testing.T) {
+
+	h := NewWatcherHarness(t, "dump-trace-disabled")
+	w, err := NewPollingWatcher(Config{Path: h.Path(), Interval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	writer := h.Create()
+	defer writer.Close()
+	h.Wait(w, true, false, nil)
+
+	var b strings.Builder
+	if err := w.(TraceWatcher).DumpTrace(&b); err != nil || b.Len() != 0 {
+		t.Fatalf("expected an empty trace, got %q and %v", b.String(), err)
+	}
+
+	if _, err := NewPollingWatcher(Config{Path: h.Path(), TraceSize: -1}); err == nil {
+		t.Fatal("expected an error for a negative trace size")
+	}
+}
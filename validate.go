@@ -0,0 +1,88 @@
+package tail
+
+import "fmt"
+
+// Violation is an invariant found broken by Config.OnViolation.
+type Violation struct {
+	// Path is the configured path being read.
+	Path string
+
+	// Message describes what was wrong.
+	Message string
+
+	// Previous is the state the LineReader expected, and Current is
+	// what the Watcher returned.
+	Previous FileState
+	Current  FileState
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%v: %v (expected %v, got %v)", v.Path, v.Message, v.Previous, v.Current)
+}
+
+// validate checks s returned by the Watcher against prev, the status the
+// LineReader was reading, where buffered bytes were read from the file
+// but not yet returned.
+func (l *LineReader) validate(prev WaitStatus, buffered int, s WaitStatus) {
+	if l.c.OnViolation == nil || l.br == nil {
+		return
+	}
+
+	expected := prev.State
+	expected.Position += int64(buffered)
+
+	report := func(msg string) {
+		l.c.OnViolation(Violation{
+			Path:     l.c.Path,
+			Message:  msg,
+			Previous: expected,
+			Current:  s.State,
+		})
+	}
+
+	sameFile := expected.ID().Equal(s.State.ID())
+
+	if s.ReOpened {
+		// A new generation must be a different file, or reading the
+		// same one again would deliver lines twice.
+		if sameFile && !s.Truncated && s.State.Position < expected.Position {
+			report("reopened the same file at an earlier position")
+		}
+
+		if !sameFile && !expected.ID().IsZero() {
+			for _, old := range l.finished {
+				if earlierGeneration(old, s.State) {
+					report("rotated back to an earlier generation")
+					break
+				}
+			}
+
+			if len(l.finished) == historySize {
+				l.finished = l.finished[1:]
+			}
+			l.finished = append(l.finished, expected)
+		}
+		return
+	}
+
+	if !expected.ID().IsZero() && !s.State.ID().IsZero() && !sameFile {
+		report("file changed without being reopened")
+		return
+	}
+
+	if s.State.Position < expected.Position {
+		report("position went backwards within a file")
+	} else if s.State.Position > expected.Position {
+		report("position skipped ahead of what was read")
+	}
+}
+
+// earlierGeneration reports whether s is old, a file that was already
+// finished. Files only grow, so a smaller one is a new file that reused
+// its inode.
+func earlierGeneration(old, s FileState) bool {
+	if !old.ID().Equal(s.ID()) || s.Size < old.Size {
+		return false
+	}
+	return old.sameIdentity(s)
+}
@@ -0,0 +1,152 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedWatcher returns each status in turn, then closed.
+type scriptedWatcher struct {
+	statuses []WaitStatus
+}
+
+func (w *scriptedWatcher) Wait() (WaitStatus, bool, error) {
+	if len(w.statuses) == 0 {
+		return WaitStatus{}, true, nil
+	}
+	s := w.statuses[0]
+	w.statuses = w.statuses[1:]
+	return s, false, nil
+}
+
+func (w *scriptedWatcher) Close() error {
+	return nil
+}
+
+func TestLineReaderOnViolation(t *testing.T) {
+
+	var violations []Violation
+	c := Config{
+		Path:        "scripted",
+		OnViolation: func(v Violation) { violations = append(violations, v) },
+	}
+
+	w := &scriptedWatcher{statuses: []WaitStatus{
+		{
+			State:    FileState{Inode: 1, Size: 2},
+			Reader:   strings.NewReader("a\n"),
+			ReOpened: true,
+		},
+		// Claims more was read than the LineReader did.
+		{State: FileState{Inode: 1, Size: 5, Position: 4}},
+		// Reopens the same file from the start.
+		{
+			State:    FileState{Inode: 1, Size: 5},
+			Reader:   strings.NewReader(""),
+			ReOpened: true,
+		},
+	}}
+
+	r := NewLineReaderFromWatcher(w, c, nil)
+	readLine(t, r, "a")
+	if r.Next() {
+		t.Fatal("expected no more lines")
+	}
+
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %v", violations)
+	}
+
+	if v := violations[0]; v.Previous.Position != 2 || v.Current.Position != 4 {
+		t.Fatalf("expected a skip from 2 to 4, got %v", v)
+	}
+
+	if v := violations[1]; !strings.Contains(v.Message, "reopened") {
+		t.Fatalf("expected reopening the same file, got %v", v)
+	}
+}
+
+func TestLineReaderOnViolationGenerations(t *testing.T) {
+
+	var violations []Violation
+	c := Config{
+		Path:        "scripted",
+		OnViolation: func(v Violation) { violations = append(violations, v) },
+	}
+
+	w := &scriptedWatcher{statuses: []WaitStatus{
+		{
+			State:    FileState{Inode: 1, Size: 2},
+			Reader:   strings.NewReader("a\n"),
+			ReOpened: true,
+		},
+		{
+			State:    FileState{Inode: 2, Size: 2},
+			Reader:   strings.NewReader("b\n"),
+			Event:    Rotated,
+			ReOpened: true,
+		},
+		// A file with a reused inode is smaller than the one before.
+		{
+			State:    FileState{Inode: 1, Size: 1},
+			Reader:   strings.NewReader("c"),
+			Event:    Rotated,
+			ReOpened: true,
+		},
+		// Goes back to the second file once it's finished.
+		{
+			State:    FileState{Inode: 2, Size: 2, Position: 2},
+			Reader:   strings.NewReader(""),
+			Event:    Rotated,
+			ReOpened: true,
+		},
+	}}
+
+	r := NewLineReaderFromWatcher(w, c, nil)
+	readLine(t, r, "a")
+	readLine(t, r, "b")
+	if r.Next() {
+		t.Fatalf("expected no more lines, got %q", r.Bytes())
+	}
+
+	if len(violations) != 1 {
+		t.Fatalf("expected 1 violation, got %v", violations)
+	}
+
+	if v := violations[0]; !strings.Contains(v.Message, "earlier generation") || v.Current.Inode != 2 {
+		t.Fatalf("expected going back to the second file, got %v", v)
+	}
+}
+
+func TestLineReaderNoViolations(t *testing.T) {
+
+	h := NewWatcherHarness(t, "no-violations")
+
+	r, err := NewLineReader(Config{
+		Path:     h.Path(),
+		Interval: time.Millisecond * 10,
+		OnViolation: func(v Violation) {
+			t.Errorf("unexpected violation: %v", v)
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	writer := h.Create()
+	writeString(t, writer, "a\nb\n")
+	readLine(t, r, "a")
+	readLine(t, r, "b")
+
+	writeString(t, writer, "c\n")
+	readLine(t, r, "c")
+	writer.Close()
+
+	h.Rotate()
+	writer = h.Create()
+	writeString(t, writer, "d\n")
+	writer.Close()
+	readLine(t, r, "d")
+}
@@ -0,0 +1,314 @@
+package tail
+
+import (
+	"bytes"
+)
+
+// XMLRecordReader reads complete elements with a given name from an append
+// only XML log, using a LineReader. Only elements that aren't inside
+// another element of the same name are returned, and anything between
+// them, like a declaration or root element, is ignored. Elements can span
+// many lines, or share a line with others. Comments and CDATA sections
+// are skipped when looking for tags.
+type XMLRecordReader struct {
+	l    *LineReader
+	name []byte
+	max  int
+
+	// buf holds lines from the start of an incomplete element.
+	buf []byte
+	// pending are complete elements from the last line read.
+	pending [][]byte
+	record  []byte
+
+	// prev is the state before the last line read, which is used for
+	// records that aren't the last on their line.
+	prev  FileState
+	state FileState
+	err   error
+
+	// skipDepth is how deep in an element that was too large the
+	// reader is, while its remaining lines are skipped.
+	skipDepth int
+}
+
+// NewXMLRecordReader returns an XMLRecordReader returning elements named
+// name from l. If maxSize is greater than 0 and an element grows larger than
+// it, Next returns false and Err returns ErrRecordTooLarge. Calling Next
+// again skips the rest of that element and continues with the next one.
+func NewXMLRecordReader(l *LineReader, name string, maxSize int) *XMLRecordReader {
+	state := l.FileState()
+	return &XMLRecordReader{
+		l:     l,
+		name:  []byte(name),
+		max:   maxSize,
+		prev:  state,
+		state: state,
+	}
+}
+
+// Next blocks until a complete element is available and returns true, or
+// returns false if the LineReader stopped or an element was too large.
+func (r *XMLRecordReader) Next() bool {
+	if r.err == ErrRecordTooLarge {
+		r.err = nil
+		if !r.skip() {
+			return false
+		}
+		r.split()
+	}
+
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return false
+		}
+
+		r.prev = r.l.FileState()
+		if !r.l.Next() {
+			return false
+		}
+
+		if len(r.buf) > 0 {
+			r.buf = append(r.buf, '\n')
+		}
+		r.buf = append(r.buf, r.l.Bytes()...)
+		r.split()
+
+		if r.max > 0 && len(r.buf) > r.max {
+			r.err = ErrRecordTooLarge
+			// Only how deep it is, and a tag that isn't complete yet,
+			// are needed to find the end of it.
+			var rest []byte
+			_, r.skipDepth, rest = r.walk(r.buf, 0)
+			r.buf = append([]byte(nil), rest...)
+			return false
+		}
+	}
+
+	r.record = r.pending[0]
+	r.pending = r.pending[1:]
+
+	// Resuming in the middle of a line isn't possible, so only the last
+	// record of a line moves the state past it, and only if another
+	// element didn't start after it.
+	r.state = r.prev
+	if len(r.pending) == 0 && len(r.buf) == 0 {
+		r.state = r.l.FileState()
+	}
+	return true
+}
+
+// split moves the complete elements in buf to pending, keeping only the
+// start of an incomplete one.
+func (r *XMLRecordReader) split() {
+	records, rest := r.scan(r.buf)
+	for _, rec := range records {
+		r.pending = append(r.pending, append([]byte(nil), rec...))
+	}
+
+	if rest < 0 {
+		r.buf = r.buf[:0]
+	} else {
+		r.buf = append(r.buf[:0], r.buf[rest:]...)
+	}
+}
+
+// skip reads the lines left in an element that was too large, without
+// keeping them, leaving what's after its end in buf.
+func (r *XMLRecordReader) skip() bool {
+	for r.skipDepth > 0 {
+		r.prev = r.l.FileState()
+		if !r.l.Next() {
+			return false
+		}
+
+		if len(r.buf) > 0 {
+			r.buf = append(r.buf, '\n')
+		}
+		r.buf = append(r.buf, r.l.Bytes()...)
+
+		end, depth, rest := r.walk(r.buf, r.skipDepth)
+		if end >= 0 {
+			rest = rest[end:]
+		}
+		r.skipDepth = depth
+		r.buf = append(r.buf[:0], rest...)
+	}
+
+	if len(bytes.TrimSpace(r.buf)) == 0 {
+		r.buf = r.buf[:0]
+		r.state = r.l.FileState()
+	}
+	return true
+}
+
+// walk follows the elements in b starting depth deep, returning the offset
+// after the end of the one that was open, or -1 if it doesn't end in b.
+// If it doesn't, it also returns the depth at the end of b, and the part of
+// b with a tag that isn't complete yet, which is empty if there isn't one.
+func (r *XMLRecordReader) walk(b []byte, depth int) (end, remaining int, rest []byte) {
+	for i := 0; i < len(b); {
+		j := bytes.IndexByte(b[i:], '<')
+		if j < 0 {
+			break
+		}
+		i += j
+
+		n, open, closing, self := r.tag(b[i:])
+		if n < 0 {
+			return -1, depth, b[i:]
+		} else if n == 0 {
+			i++
+			continue
+		}
+
+		switch {
+		case open && !self:
+			depth++
+		case closing && depth > 0:
+			depth--
+		}
+
+		i += n
+		if depth == 0 && (closing || self) {
+			return i, 0, b
+		}
+	}
+	return -1, depth, b[:0]
+}
+
+// tag returns the length of the tag, comment or CDATA section at the
+// start of b, -1 if it's incomplete, or 0 if it's none of those. It also
+// reports if it's an opening, closing or self closing tag of the element.
+func (r *XMLRecordReader) tag(b []byte) (n int, open, closing, self bool) {
+	switch {
+	case bytes.HasPrefix(b, []byte("<!--")):
+		n = indexAfter(b, "-->")
+	case bytes.HasPrefix(b, []byte("<![CDATA[")):
+		n = indexAfter(b, "]]>")
+	case r.isTag(b[1:]):
+		open = true
+		n, self = tagEnd(b)
+	case len(b) > 1 && b[1] == '/' && r.isTag(b[2:]):
+		closing = true
+		n = indexAfter(b, ">")
+	}
+	return n, open, closing, self
+}
+
+// scan returns every complete element in b, and the offset of an
+// incomplete one, or -1 if there isn't one.
+func (r *XMLRecordReader) scan(b []byte) (records [][]byte, rest int) {
+	depth := 0
+	start := -1
+
+	for i := 0; i < len(b); {
+		j := bytes.IndexByte(b[i:], '<')
+		if j < 0 {
+			break
+		}
+		i += j
+
+		end, open, closing, self := r.tag(b[i:])
+		if end == 0 {
+			i++
+			continue
+		}
+
+		if end < 0 {
+			// The rest of this tag, comment or CDATA is in a later line.
+			if start < 0 && (open || closing) {
+				return records, i
+			}
+			return records, start
+		}
+
+		if open && depth == 0 {
+			start = i
+		}
+
+		switch {
+		case open && !self:
+			depth++
+		case closing && depth > 0:
+			depth--
+		}
+
+		i += end
+		if start >= 0 && depth == 0 {
+			records = append(records, b[start:i])
+			start = -1
+		}
+	}
+
+	return records, start
+}
+
+// isTag checks if b starts with the element name followed by the end of it.
+func (r *XMLRecordReader) isTag(b []byte) bool {
+	if !bytes.HasPrefix(b, r.name) || len(b) == len(r.name) {
+		return len(b) == len(r.name) && bytes.Equal(b, r.name)
+	}
+
+	switch b[len(r.name)] {
+	case ' ', '\t', '\r', '\n', '>', '/':
+		return true
+	}
+	return false
+}
+
+// tagEnd returns the offset after the > ending the start tag at the
+// beginning of b, skipping quoted attribute values, or -1 if it's
+// incomplete. It also reports if the tag is self closing.
+func tagEnd(b []byte) (int, bool) {
+	var quote byte
+	for i := 1; i < len(b); i++ {
+		switch c := b[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i + 1, b[i-1] == '/'
+		}
+	}
+	return -1, false
+}
+
+func indexAfter(b []byte, sep string) int {
+	i := bytes.Index(b, []byte(sep))
+	if i < 0 {
+		return -1
+	}
+	return i + len(sep)
+}
+
+// Bytes returns the last element, from the start of its opening tag to the
+// end of its closing tag, with lines joined by \n. It is only valid until
+// the next call to Next.
+func (r *XMLRecordReader) Bytes() []byte {
+	return r.record
+}
+
+// Err returns the error that caused Next to return false, either from
+// the LineReader or ErrRecordTooLarge.
+func (r *XMLRecordReader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.l.Err()
+}
+
+// FileState returns the state to resume from after the last element. If
+// other elements started after it on the same line, it's the start of that
+// line so they aren't lost, and it will be returned again when resuming.
+func (r *XMLRecordReader) FileState() FileState {
+	return r.state
+}
+
+// Close closes the underlying LineReader.
+func (r *XMLRecordReader) Close() error {
+	return r.l.Close()
+}
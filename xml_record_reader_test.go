@@ -0,0 +1,119 @@
+package tail
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestXMLRecordReader(t *testing.T) {
+
+	h := NewWatcherHarness(t, "xml-record-reader-test")
+
+	writer := h.Create()
+	writeString(t, writer, `<?xml version="1.0"?>
+<Events>
+<Event id="1"><Data>one</Data></Event>
+<Event id="2">
+  <!-- <Event> in a comment -->
+  <Event>nested</Event>
+  <Data a="x>y"><![CDATA[</Event>]]></Data>
+</Event>
+<Eventually/><Event id="3"/><Event
+  id="4">four</Event>
+`)
+	writer.Close()
+
+	l, err := NewLineReader(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		StopAtEOF: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewXMLRecordReader(l, "Event", 0)
+	defer r.Close()
+
+	var records []string
+	var positions []int64
+	for r.Next() {
+		records = append(records, string(r.Bytes()))
+		positions = append(positions, r.FileState().Position)
+	}
+
+	if r.Err() != io.EOF {
+		t.Fatalf("unexpected error: %v", r.Err())
+	}
+
+	expected := []string{
+		`<Event id="1"><Data>one</Data></Event>`,
+		"<Event id=\"2\">\n  <!-- <Event> in a comment -->\n  <Event>nested</Event>\n  <Data a=\"x>y\"><![CDATA[</Event>]]></Data>\n</Event>",
+		`<Event id="3"/>`,
+		"<Event\n  id=\"4\">four</Event>",
+	}
+	if !reflect.DeepEqual(expected, records) {
+		t.Fatalf("expected %q, got %q", expected, records)
+	}
+
+	// The third element shares a line with the start of the fourth, so
+	// resuming after it has to start at that line.
+	if positions[2] != 194 || positions[3] != 251 {
+		t.Fatalf("unexpected positions %v", positions)
+	}
+}
+
+func TestXMLRecordReaderTooLarge(t *testing.T) {
+
+	h := NewWatcherHarness(t, "xml-record-reader-too-large-test")
+
+	writer := h.Create()
+	writeString(t, writer, `<Event id="1">one</Event>
+<Event id="2">
+<Data>aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa</Data>
+</Event><Event id="3">three</Event>
+<Event id="4">four</Event>
+`)
+	writer.Close()
+
+	l, err := NewLineReader(Config{
+		Path:      h.Path(),
+		Interval:  time.Millisecond * 10,
+		StopAtEOF: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewXMLRecordReader(l, "Event", 30)
+	defer r.Close()
+
+	if !r.Next() || string(r.Bytes()) != `<Event id="1">one</Event>` {
+		t.Fatalf("expected the first element, got %q, %v", r.Bytes(), r.Err())
+	}
+
+	if r.Next() || r.Err() != ErrRecordTooLarge {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", r.Err())
+	}
+
+	// The rest of the large element is skipped, like a long line.
+	var records []string
+	for r.Next() {
+		records = append(records, string(r.Bytes()))
+	}
+
+	if r.Err() != io.EOF {
+		t.Fatalf("unexpected error: %v", r.Err())
+	}
+
+	expected := []string{`<Event id="3">three</Event>`, `<Event id="4">four</Event>`}
+	if !reflect.DeepEqual(expected, records) {
+		t.Fatalf("expected %q, got %q", expected, records)
+	}
+
+	if pos, size := r.FileState().Position, r.FileState().Size; pos != size {
+		t.Fatalf("expected position %v at the end, got %v", size, pos)
+	}
+}